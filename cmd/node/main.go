@@ -2,11 +2,32 @@ package main
 
 import (
 	"blockchainStorage/config"
+	"blockchainStorage/internal/attachment"
 	"blockchainStorage/internal/blockchain"
+	"blockchainStorage/internal/delivery"
+	"blockchainStorage/internal/identity"
+	"blockchainStorage/internal/inbox"
+	"blockchainStorage/internal/keystore"
+	"blockchainStorage/internal/mailbox"
+	"blockchainStorage/internal/mempool"
 	"blockchainStorage/internal/network"
+	"blockchainStorage/internal/presence"
+	"blockchainStorage/internal/receipt"
 	"blockchainStorage/internal/storage"
+	"blockchainStorage/internal/transaction"
+	"errors"
+	"fmt"
 	"log"
 	"net"
+	"os"
+	"time"
+)
+
+const (
+	// defaultAnchorInterval интервал закрепления сообщений, если он не задан в конфигурации
+	defaultAnchorInterval = time.Minute
+	// chunkPruneInterval интервал удаления фрагментов других узлов с истекшим сроком хранения
+	chunkPruneInterval = time.Hour
 )
 
 func main() {
@@ -28,13 +49,90 @@ func main() {
 	if err != nil {
 		log.Fatal("Failed to initialize blockchain:", err)
 	}
+	chain.BlockReward = cfg.BlockReward
+
+	// Пул неподтвержденных транзакций, из которого майнер собирает блоки
+	poolConfig := mempool.DefaultConfig()
+	poolConfig.StampDifficulty = cfg.StampDifficulty
+	pool := mempool.NewMempool(poolConfig)
+	pool.SetValidator(chain.ValidateTransaction)
+	chain.SetTxPool(pool)
+
+	// Квитанции получателей о доставке и прочтении отправленных сообщений
+	receipts := receipt.NewStore(dataStore.Namespace(receipt.Namespace), chain)
+
+	// Входящие сообщения локальных ключей, в том числе доставленные напрямую
+	messages := inbox.New(dataStore, dataStore.Namespace(inbox.Namespace))
+	err = messages.Watch(chain, func(err error) {
+		log.Println("Failed to scan block for messages:", err)
+	})
+	if err != nil {
+		log.Println("Failed to scan blockchain for messages:", err)
+	}
+
+	// Почтовый ящик хранит сообщения зарегистрированных получателей, пока они не в сети
+	// Запросы к почтовому ящику подписываются вместе с адресом узла
+	if cfg.Address == "" {
+		log.Println("Node address is not configured, mailbox requests will be rejected")
+	}
+	mail := mailbox.New(cfg.Address, dataStore, dataStore.Namespace(mailbox.Namespace))
+	mail.Watch(chain, func(err error) {
+		log.Println("Failed to store mail from block:", err)
+	})
+
+	// Фрагменты вложений других узлов принимаются со штампом или подписью
+	// зарегистрированного ключа и хранятся ограниченное время
+	keeper, err := attachment.NewKeeper(dataStore, dataStore.Namespace(attachment.KeeperNamespace),
+		attachment.DefaultPutPolicy(chain, cfg.StampDifficulty))
+	if err != nil {
+		log.Fatal("Failed to initialize chunk keeper:", err)
+	}
+	go keeper.Run(chunkPruneInterval, func(err error) {
+		log.Println("Failed to prune hosted chunks:", err)
+	}, nil)
 
 	// Создание и инициализация сети
 	n := network.Network{NodeList: cfg.Nodes}
 
+	// Присутствие и набор текста пользователей хранятся только в памяти узла
+	presenceTable := presence.NewTable(chain)
+
+	nd := &node{
+		network:         &n,
+		dataStore:       dataStore,
+		keeper:          keeper,
+		chain:           chain,
+		pool:            pool,
+		stampDifficulty: cfg.StampDifficulty,
+		receipts:        receipts,
+		messages:        messages,
+		mail:            mail,
+		presence:        presenceTable,
+		batcher:         delivery.NewBatcher(dataStore.Namespace(delivery.Namespace)),
+		admission:       &delivery.Admission{Keys: chain, StampDifficulty: cfg.StampDifficulty},
+	}
+
+	// Сообщения, доставленные узлу напрямую, закрепляются пакетами, подписанными ключом узла
+	nd.signer, err = loadNodeKey(cfg)
+	if err != nil {
+		log.Println("Anchoring of direct messages is disabled:", err)
+		nd.batcher = nil
+	} else {
+		interval := time.Duration(cfg.AnchorInterval) * time.Second
+		if interval <= 0 {
+			interval = defaultAnchorInterval
+		}
+
+		go nd.batcher.Run(interval, nd.submitAnchor, func(err error) {
+			log.Println("Failed to anchor direct messages:", err)
+		}, nil)
+	}
+
 	// Запуск сервера для прослушивания входящих соединений
 	go func() {
-		err := n.StartServer(cfg.Port, handleIncomingMessage)
+		err := n.StartServer(cfg.Port, func(msg *network.Message, conn net.Conn) {
+			nd.handleIncomingMessage(msg, conn)
+		})
 		if err != nil {
 			log.Fatal("Failed to start server:", err)
 		}
@@ -65,9 +163,130 @@ func main() {
 	select {}
 }
 
+// node подсистемы узла, обрабатывающие входящие сообщения
+type node struct {
+	network         *network.Network
+	dataStore       *storage.DataStore
+	keeper          *attachment.Keeper
+	chain           *blockchain.Blockchain
+	pool            *mempool.Mempool
+	stampDifficulty int
+	receipts        *receipt.Store
+	messages        *inbox.Inbox
+	mail            *mailbox.Mailbox
+	presence        *presence.Table
+	batcher         *delivery.Batcher
+	admission       *delivery.Admission
+	// signer ключ узла, nil и batcher nil, если закрепление сообщений отключено
+	signer *identity.Identity
+}
+
+// loadNodeKey расшифровывает ключ узла из хранилища ключей, заданного в конфигурации
+func loadNodeKey(cfg *config.Config) (*identity.Identity, error) {
+	if cfg.KeystorePath == "" || cfg.NodeKey == "" {
+		return nil, errors.New("node key is not configured")
+	}
+
+	ks, err := keystore.Open(cfg.KeystorePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return ks.UnlockIdentity(cfg.NodeKey, os.Getenv("NODE_KEY_PASSPHRASE"))
+}
+
+// submitAnchor подписывает транзакцию закрепления ключом узла и отправляет ее в пул
+func (nd *node) submitAnchor(tx *transaction.Transaction) error {
+	tx.Sender = nd.signer.Address()
+
+	// Номер следует за подтвержденными транзакциями узла и его транзакциями в пуле
+	sequence, err := nd.chain.NextSequence(tx.Sender)
+	if err != nil {
+		return fmt.Errorf("failed to get anchor sequence: %w", err)
+	}
+	for _, pending := range nd.pool.Pending(0) {
+		if pending.Sender == tx.Sender && pending.Sequence >= sequence {
+			sequence = pending.Sequence + 1
+		}
+	}
+	tx.Sequence = sequence
+
+	if nd.stampDifficulty > 0 {
+		err = blockchain.StampTransaction(tx, nd.stampDifficulty)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Sign(nd.signer.PrivateKey())
+	if err != nil {
+		return err
+	}
+
+	// Транзакция, принятая пулом, будет закреплена, даже если ее не удалось разослать
+	err = mempool.Submit(nd.pool, nd.network, tx)
+	if err != nil && nd.pool.Has(tx.ID) {
+		log.Println("Failed to relay anchor:", err)
+		return nil
+	}
+
+	return err
+}
+
 // Обработчик входящих сообщений
-func handleIncomingMessage(msg *network.Message, conn net.Conn) {
+func (nd *node) handleIncomingMessage(msg *network.Message, conn net.Conn) {
 	// Обработка входящего сообщения
+	switch msg.Command {
+	case mempool.CommandTx:
+		// Транзакция, принятая пулом, передается соседним узлам
+		err := mempool.HandleTransaction(nd.pool, nd.network, msg)
+		if err != nil {
+			log.Println("Failed to accept transaction:", err)
+		}
+	case attachment.CommandGetChunk:
+		// Узел раздает хранящиеся у него фрагменты вложений
+		err := attachment.HandleGetChunk(nd.dataStore, msg, conn)
+		if err != nil {
+			log.Println("Failed to serve chunk:", err)
+		}
+	case attachment.CommandPutChunk:
+		// Узел хранит копии фрагментов, загруженных другими узлами
+		err := attachment.HandlePutChunk(nd.keeper, msg, conn)
+		if err != nil {
+			log.Println("Failed to store chunk:", err)
+		}
+	case receipt.CommandReceipt:
+		err := receipt.HandleReceipt(nd.receipts, msg)
+		if err != nil {
+			log.Println("Failed to accept receipt:", err)
+		}
+	case delivery.CommandDeliver:
+		// Сообщение, доставленное напрямую, до подтверждения в блоке
+		err := delivery.HandleDeliver(delivery.Receivers{nd.messages, nd.mail}, nd.admission, nd.batcher, msg, conn)
+		if err != nil {
+			log.Println("Failed to accept direct message:", err)
+		}
+	case mailbox.CommandRegister:
+		err := mailbox.HandleRegister(nd.mail, msg, conn)
+		if err != nil {
+			log.Println("Failed to register mailbox recipient:", err)
+		}
+	case mailbox.CommandFetchMail:
+		err := mailbox.HandleFetchMail(nd.mail, msg, conn)
+		if err != nil {
+			log.Println("Failed to serve mail:", err)
+		}
+	case presence.CommandPresence:
+		err := presence.HandlePresence(nd.presence, nd.network, msg, conn)
+		if err != nil {
+			log.Println("Failed to accept presence signal:", err)
+		}
+	case presence.CommandPresenceList:
+		err := presence.HandlePresenceList(nd.presence, conn)
+		if err != nil {
+			log.Println("Failed to serve presence table:", err)
+		}
+	}
 
 	// Ваш код
 }
@@ -7,12 +7,21 @@ import (
 )
 
 type Config struct {
-	Port              int           `json:"port"`
+	Port int `json:"port"`
+	// Address адрес, по которому к узлу обращаются другие узлы и клиенты
+	Address           string        `json:"address"`
 	DataBasePath      string        `json:"dbPath"`
 	Nodes             []common.Node `json:"nodes"`
 	Difficulty        int           `json:"difficulty"`
 	BlockReward       int           `json:"blockReward"`
 	GenesisBlockNonce int64         `json:"genesisBlockNonce"`
+	StampDifficulty   int           `json:"stampDifficulty"`
+	// KeystorePath и NodeKey ключ узла в хранилище ключей, которым подписываются
+	// транзакции закрепления. Пароль ключа передается в переменной NODE_KEY_PASSPHRASE.
+	KeystorePath string `json:"keystorePath"`
+	NodeKey      string `json:"nodeKey"`
+	// AnchorInterval интервал закрепления сообщений, доставленных напрямую, в секундах
+	AnchorInterval int `json:"anchorInterval"`
 }
 
 func LoadConfig(filePath string) (*Config, error) {
@@ -0,0 +1,144 @@
+package address
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	// Version байт версии адресов пользователей, все адреса начинаются с "C"
+	Version byte = 0x1c
+	// PayloadLength длина хэша публичного ключа в адресе
+	PayloadLength  = 20
+	checksumLength = 4
+
+	alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+)
+
+var (
+	ErrInvalidAddress  = errors.New("invalid address")
+	ErrInvalidChecksum = errors.New("invalid address checksum")
+	ErrInvalidVersion  = errors.New("unsupported address version")
+)
+
+// FromPublicKey вычисляет адрес пользователя по его публичному ключу:
+// Base58Check(версия || SHA256(SHA256(ключ))[:20])
+func FromPublicKey(publicKey []byte) string {
+	return Encode(Version, hashPublicKey(publicKey))
+}
+
+// Encode кодирует версию и полезную нагрузку в Base58Check
+func Encode(version byte, payload []byte) string {
+	data := append([]byte{version}, payload...)
+	data = append(data, checksum(data)...)
+	return base58Encode(data)
+}
+
+// Decode декодирует адрес Base58Check и проверяет контрольную сумму
+func Decode(addr string) (byte, []byte, error) {
+	data, err := base58Decode(addr)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(data) < 1+checksumLength {
+		return 0, nil, fmt.Errorf("%w: %q is too short", ErrInvalidAddress, addr)
+	}
+
+	body := data[:len(data)-checksumLength]
+	if !bytes.Equal(checksum(body), data[len(data)-checksumLength:]) {
+		return 0, nil, fmt.Errorf("%w: %q", ErrInvalidChecksum, addr)
+	}
+
+	return body[0], body[1:], nil
+}
+
+// Validate проверяет формат, версию и контрольную сумму адреса
+func Validate(addr string) error {
+	version, payload, err := Decode(addr)
+	if err != nil {
+		return err
+	}
+
+	if version != Version {
+		return fmt.Errorf("%w: %d", ErrInvalidVersion, version)
+	}
+
+	if len(payload) != PayloadLength {
+		return fmt.Errorf("%w: %q has wrong length", ErrInvalidAddress, addr)
+	}
+
+	return nil
+}
+
+// MatchesPublicKey проверяет, что адрес получен из публичного ключа
+func MatchesPublicKey(addr string, publicKey []byte) bool {
+	return addr == FromPublicKey(publicKey)
+}
+
+func hashPublicKey(publicKey []byte) []byte {
+	first := sha256.Sum256(publicKey)
+	second := sha256.Sum256(first[:])
+	return second[:PayloadLength]
+}
+
+func checksum(data []byte) []byte {
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+	return second[:checksumLength]
+}
+
+func base58Encode(data []byte) string {
+	value := new(big.Int).SetBytes(data)
+	base := big.NewInt(int64(len(alphabet)))
+	mod := new(big.Int)
+
+	var encoded []byte
+	for value.Sign() > 0 {
+		value.DivMod(value, base, mod)
+		encoded = append(encoded, alphabet[mod.Int64()])
+	}
+
+	// Ведущие нулевые байты кодируются символом "1"
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		encoded = append(encoded, alphabet[0])
+	}
+
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+
+	return string(encoded)
+}
+
+func base58Decode(encoded string) ([]byte, error) {
+	if encoded == "" {
+		return nil, fmt.Errorf("%w: empty address", ErrInvalidAddress)
+	}
+
+	value := new(big.Int)
+	base := big.NewInt(int64(len(alphabet)))
+	for _, r := range encoded {
+		index := bytes.IndexByte([]byte(alphabet), byte(r))
+		if r > 127 || index < 0 {
+			return nil, fmt.Errorf("%w: unexpected character %q", ErrInvalidAddress, r)
+		}
+
+		value.Mul(value, base)
+		value.Add(value, big.NewInt(int64(index)))
+	}
+
+	decoded := value.Bytes()
+	leadingZeros := 0
+	for leadingZeros < len(encoded) && encoded[leadingZeros] == alphabet[0] {
+		leadingZeros++
+	}
+
+	return append(make([]byte, leadingZeros), decoded...), nil
+}
@@ -0,0 +1,77 @@
+package address
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFromPublicKey(t *testing.T) {
+	addr := FromPublicKey([]byte("public key"))
+
+	if !strings.HasPrefix(addr, "C") {
+		t.Errorf("expected address to start with C, got %s", addr)
+	}
+
+	err := Validate(addr)
+	if err != nil {
+		t.Fatalf("expected generated address to be valid: %v", err)
+	}
+
+	if !MatchesPublicKey(addr, []byte("public key")) {
+		t.Error("expected address to match its public key")
+	}
+	if MatchesPublicKey(addr, []byte("other key")) {
+		t.Error("expected address not to match another public key")
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	payload := []byte{0, 0, 1, 2, 3}
+	encoded := Encode(0, payload)
+
+	// Ведущие нулевые байты сохраняются
+	if !strings.HasPrefix(encoded, "111") {
+		t.Errorf("expected leading zeros to be encoded as 1, got %s", encoded)
+	}
+
+	version, decoded, err := Decode(encoded)
+	if err != nil {
+		t.Fatalf("failed to decode address: %v", err)
+	}
+	if version != 0 || string(decoded) != string(payload) {
+		t.Errorf("decoded address: got %d %v, expected 0 %v", version, decoded, payload)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	addr := FromPublicKey([]byte("public key"))
+
+	// Замена одного символа нарушает контрольную сумму
+	typo := []byte(addr)
+	if typo[10] == 'a' {
+		typo[10] = 'b'
+	} else {
+		typo[10] = 'a'
+	}
+
+	tests := []struct {
+		name     string
+		addr     string
+		expected error
+	}{
+		{"typo", string(typo), ErrInvalidChecksum},
+		{"empty", "", ErrInvalidAddress},
+		{"bad character", "C0OIl", ErrInvalidAddress},
+		{"file path", "recipient_1", ErrInvalidAddress},
+		{"wrong version", Encode(0x01, make([]byte, PayloadLength)), ErrInvalidVersion},
+		{"wrong length", Encode(Version, make([]byte, 10)), ErrInvalidAddress},
+	}
+
+	for _, test := range tests {
+		err := Validate(test.addr)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package attachment
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	// ChunkSize размер фрагмента файла до шифрования
+	ChunkSize = 256 * 1024
+	// MaxFileSize максимальный размер вложения
+	MaxFileSize = 64 << 20
+	// KeyLength длина ключа шифрования вложения
+	KeyLength = 32
+	// MaxChunkSize максимальный размер зашифрованного фрагмента: ChunkSize и тег GCM
+	MaxChunkSize = ChunkSize + 16
+
+	// manifestIndex номер nonce для шифрования манифеста, фрагменты нумеруются с нуля
+	manifestIndex = ^uint64(0)
+)
+
+var (
+	ErrFileTooLarge    = errors.New("attachment is too large")
+	ErrChunkNotFound   = errors.New("chunk not found")
+	ErrChunkCorrupted  = errors.New("chunk hash does not match its content")
+	ErrChunkTooLarge   = errors.New("chunk is too large")
+	ErrInvalidHash     = errors.New("invalid chunk hash")
+	ErrInvalidManifest = errors.New("invalid attachment manifest")
+)
+
+// ChunkStore хранилище зашифрованных фрагментов, адресуемых хэшем содержимого
+type ChunkStore interface {
+	SaveChunk(hash string, data []byte) error
+	ChunkFetcher
+}
+
+// ChunkFetcher источник фрагментов: локальное хранилище или другой узел сети
+type ChunkFetcher interface {
+	GetChunk(hash string) ([]byte, error)
+}
+
+// Manifest описание вложения: имя, размер и хэши зашифрованных фрагментов по порядку.
+// Манифест шифруется ключом вложения и хранится как обычный фрагмент.
+type Manifest struct {
+	Name   string
+	Size   int64
+	Chunks []string
+}
+
+// Reference ссылка на вложение: хэш манифеста и ключ шифрования. В транзакцию
+// открыто попадает только хэш манифеста, ключ передается в зашифрованном сообщении.
+type Reference struct {
+	Manifest string
+	Key      []byte
+}
+
+// Upload шифрует файл ключом, созданным для этого вложения, разбивает на
+// фрагменты и сохраняет их в store
+func Upload(store ChunkStore, name string, data []byte) (*Reference, error) {
+	if len(data) > MaxFileSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrFileTooLarge, len(data))
+	}
+
+	key := make([]byte, KeyLength)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate attachment key: %w", err)
+	}
+
+	aead, err := newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := Manifest{Name: name, Size: int64(len(data))}
+	for index := uint64(0); len(data) > 0; index++ {
+		size := ChunkSize
+		if len(data) < size {
+			size = len(data)
+		}
+
+		hash, err := saveChunk(store, aead.Seal(nil, nonce(aead, index), data[:size], nil))
+		if err != nil {
+			return nil, err
+		}
+
+		manifest.Chunks = append(manifest.Chunks, hash)
+		data = data[size:]
+	}
+
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	hash, err := saveChunk(store, aead.Seal(nil, nonce(aead, manifestIndex), manifestData, nil))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reference{Manifest: hash, Key: key}, nil
+}
+
+// Download загружает фрагменты вложения, проверяет их хэши и расшифровывает файл
+func Download(fetcher ChunkFetcher, ref *Reference) (*Manifest, []byte, error) {
+	aead, err := newCipher(ref.Key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	manifestData, err := fetchChunk(fetcher, aead, ref.Manifest, manifestIndex)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+
+	var manifest Manifest
+	err = json.Unmarshal(manifestData, &manifest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+	}
+
+	if manifest.Size < 0 || manifest.Size > MaxFileSize {
+		return nil, nil, fmt.Errorf("%w: size %d", ErrInvalidManifest, manifest.Size)
+	}
+
+	data := make([]byte, 0, manifest.Size)
+	for index, hash := range manifest.Chunks {
+		chunk, err := fetchChunk(fetcher, aead, hash, uint64(index))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to fetch chunk %d: %w", index, err)
+		}
+
+		data = append(data, chunk...)
+	}
+
+	if int64(len(data)) != manifest.Size {
+		return nil, nil, fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidManifest, manifest.Size, len(data))
+	}
+
+	return &manifest, data, nil
+}
+
+// Encode кодирует ссылку на вложение для передачи внутри зашифрованного сообщения
+func (ref *Reference) Encode() ([]byte, error) {
+	data, err := json.Marshal(ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal attachment reference: %w", err)
+	}
+
+	return data, nil
+}
+
+// DecodeReference декодирует ссылку на вложение из расшифрованного сообщения
+func DecodeReference(data []byte) (*Reference, error) {
+	var ref Reference
+	err := json.Unmarshal(data, &ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal attachment reference: %w", err)
+	}
+
+	err = ValidateHash(ref.Manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ref.Key) != KeyLength {
+		return nil, errors.New("invalid attachment key length")
+	}
+
+	return &ref, nil
+}
+
+// Hash вычисляет адрес фрагмента - SHA-256 его зашифрованного содержимого
+func Hash(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// ValidateHash проверяет формат адреса фрагмента
+func ValidateHash(hash string) error {
+	decoded, err := hex.DecodeString(hash)
+	if err != nil || len(decoded) != sha256.Size {
+		return fmt.Errorf("%w: %q", ErrInvalidHash, hash)
+	}
+
+	return nil
+}
+
+func saveChunk(store ChunkStore, data []byte) (string, error) {
+	// Больший фрагмент (например, манифест с очень длинным именем) узлы не примут
+	if len(data) > MaxChunkSize {
+		return "", fmt.Errorf("%w: %d bytes", ErrChunkTooLarge, len(data))
+	}
+
+	hash := Hash(data)
+
+	err := store.SaveChunk(hash, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to save chunk %s: %w", hash, err)
+	}
+
+	return hash, nil
+}
+
+// fetchChunk загружает фрагмент и проверяет, что его содержимое соответствует
+// адресу, поэтому фрагменты можно получать от любого узла без доверия к нему
+func fetchChunk(fetcher ChunkFetcher, aead cipher.AEAD, hash string, index uint64) ([]byte, error) {
+	err := ValidateHash(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := fetcher.GetChunk(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if Hash(data) != hash {
+		return nil, fmt.Errorf("%w: %s", ErrChunkCorrupted, hash)
+	}
+
+	plaintext, err := aead.Open(nil, nonce(aead, index), data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt chunk %s: %w", hash, err)
+	}
+
+	return plaintext, nil
+}
+
+// nonce номер фрагмента используется как nonce: ключ уникален для каждого вложения,
+// а фиксированный номер не позволяет переставить фрагменты местами
+func nonce(aead cipher.AEAD, index uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], index)
+	return nonce
+}
+
+func newCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeyLength {
+		return nil, fmt.Errorf("attachment key must be %d bytes", KeyLength)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	return aead, nil
+}
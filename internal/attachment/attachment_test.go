@@ -0,0 +1,252 @@
+package attachment
+
+import (
+	"blockchainStorage/common"
+	"blockchainStorage/internal/network"
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore хранилище фрагментов в памяти
+type memoryStore struct {
+	mu     sync.Mutex
+	chunks map[string][]byte
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{chunks: make(map[string][]byte)}
+}
+
+func (s *memoryStore) SaveChunk(hash string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chunks[hash] = append([]byte(nil), data...)
+	return nil
+}
+
+func (s *memoryStore) GetChunk(hash string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.chunks[hash]
+	if !ok {
+		return nil, ErrChunkNotFound
+	}
+	return data, nil
+}
+
+func (s *memoryStore) DeleteChunk(hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.chunks, hash)
+	return nil
+}
+
+func TestUploadDownload(t *testing.T) {
+	store := newMemoryStore()
+
+	// Файл из трех фрагментов, последний неполный
+	data := make([]byte, 2*ChunkSize+100)
+	_, _ = rand.Read(data)
+
+	ref, err := Upload(store, "screenshot.png", data)
+	if err != nil {
+		t.Fatalf("Ошибка загрузки вложения: %v", err)
+	}
+
+	// Три фрагмента файла и манифест
+	if len(store.chunks) != 4 {
+		t.Errorf("Ожидалось 4 фрагмента, получено %d", len(store.chunks))
+	}
+
+	// Ссылка передается в сообщении и восстанавливается получателем
+	encoded, err := ref.Encode()
+	if err != nil {
+		t.Fatalf("Ошибка кодирования ссылки: %v", err)
+	}
+	decoded, err := DecodeReference(encoded)
+	if err != nil {
+		t.Fatalf("Ошибка декодирования ссылки: %v", err)
+	}
+
+	manifest, downloaded, err := Download(store, decoded)
+	if err != nil {
+		t.Fatalf("Ошибка скачивания вложения: %v", err)
+	}
+	if manifest.Name != "screenshot.png" || len(manifest.Chunks) != 3 {
+		t.Errorf("Неверный манифест: %+v", manifest)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Error("Скачанный файл не совпадает с исходным")
+	}
+
+	// Фрагменты хранятся в зашифрованном виде
+	for _, chunk := range store.chunks {
+		if bytes.Contains(chunk, data[:64]) {
+			t.Error("Фрагмент содержит открытые данные")
+		}
+	}
+
+	// Подмененный фрагмент обнаруживается по хэшу
+	store.chunks[manifest.Chunks[1]] = []byte("tampered")
+	_, _, err = Download(store, ref)
+	if !errors.Is(err, ErrChunkCorrupted) {
+		t.Errorf("Ожидалась ошибка ErrChunkCorrupted, получено %v", err)
+	}
+
+	// Без ключа вложение не расшифровывается
+	_, _, err = Download(store, &Reference{Manifest: ref.Manifest, Key: make([]byte, KeyLength)})
+	if err == nil {
+		t.Error("Ожидалась ошибка расшифровки с неверным ключом")
+	}
+}
+
+func TestGetChunkOverNetwork(t *testing.T) {
+	store := newMemoryStore()
+	ref, err := Upload(store, "log.txt", []byte("node log"))
+	if err != nil {
+		t.Fatalf("Ошибка загрузки вложения: %v", err)
+	}
+
+	// Выбираем свободный порт для сервера
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Ошибка выбора порта: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+
+	server := &network.Network{}
+	go func() {
+		_ = server.StartServer(port, func(msg *network.Message, conn net.Conn) {
+			if msg.Command == CommandGetChunk {
+				_ = HandleGetChunk(store, msg, conn)
+			}
+		})
+	}()
+
+	client := &network.Network{NodeList: []common.Node{{Address: fmt.Sprintf("127.0.0.1:%d", port)}}}
+	fetcher := &RemoteFetcher{Network: client}
+
+	// Сервер запускается асинхронно
+	var data []byte
+	for attempt := 0; attempt < 50; attempt++ {
+		_, data, err = Download(fetcher, ref)
+		if err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Ошибка скачивания вложения по сети: %v", err)
+	}
+	if string(data) != "node log" {
+		t.Errorf("Ожидалось node log, получено %s", data)
+	}
+
+	_, err = fetcher.GetChunk(Hash([]byte("missing")))
+	if !errors.Is(err, ErrChunkNotFound) {
+		t.Errorf("Ожидалась ошибка ErrChunkNotFound, получено %v", err)
+	}
+}
+
+func TestReplicateOverNetwork(t *testing.T) {
+	// Выбираем свободный порт для сервера
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Ошибка выбора порта: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+
+	remote := newMemoryStore()
+	keeper, err := NewKeeper(remote, newMemoryRecords(), DefaultPutPolicy(nil, 1))
+	if err != nil {
+		t.Fatalf("Ошибка создания хранилища фрагментов узлов: %v", err)
+	}
+	server := &network.Network{}
+	go func() {
+		_ = server.StartServer(port, func(msg *network.Message, conn net.Conn) {
+			switch msg.Command {
+			case CommandGetChunk:
+				_ = HandleGetChunk(remote, msg, conn)
+			case CommandPutChunk:
+				_ = HandlePutChunk(keeper, msg, conn)
+			}
+		})
+	}()
+
+	client := &network.Network{NodeList: []common.Node{{Address: fmt.Sprintf("127.0.0.1:%d", port)}}}
+
+	probe, err := NewPutRequest([]byte("probe"), nil, 1)
+	if err != nil {
+		t.Fatalf("Ошибка создания запроса: %v", err)
+	}
+
+	// Сервер запускается асинхронно
+	for attempt := 0; attempt < 50; attempt++ {
+		err = PushChunk(client, client.NodeList[0].Address, probe)
+		if err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Ошибка передачи фрагмента: %v", err)
+	}
+
+	local := newMemoryStore()
+	replicator := &Replicator{Local: local, Network: client, MinReplicas: 1, StampDifficulty: 1}
+	ref, err := Upload(replicator, "log.txt", []byte("node log"))
+	if err != nil {
+		t.Fatalf("Ошибка загрузки вложения: %v", err)
+	}
+
+	// Загрузивший узел ушел из сети, вложение скачивается с другого узла
+	_, data, err := Download(&RemoteFetcher{Network: client}, ref)
+	if err != nil {
+		t.Fatalf("Ошибка скачивания реплицированного вложения: %v", err)
+	}
+	if string(data) != "node log" {
+		t.Errorf("Ожидалось node log, получено %s", data)
+	}
+
+	// Фрагмент без штампа узел не сохраняет
+	unstamped, err := NewPutRequest([]byte("unstamped"), nil, 0)
+	if err != nil {
+		t.Fatalf("Ошибка создания запроса: %v", err)
+	}
+	// Штамп случайно может оказаться подходящим
+	for VerifyChunkStamp(Hash(unstamped.Data), unstamped.Stamp, 1) {
+		unstamped.Stamp++
+	}
+	err = PushChunk(client, client.NodeList[0].Address, unstamped)
+	if err == nil {
+		t.Errorf("Ожидался отказ сохранить фрагмент без штампа")
+	}
+
+	// Фрагмент больше допустимого размера узел не сохраняет
+	large, err := NewPutRequest(make([]byte, MaxChunkSize+1), nil, 1)
+	if err != nil {
+		t.Fatalf("Ошибка создания запроса: %v", err)
+	}
+	err = PushChunk(client, client.NodeList[0].Address, large)
+	if err == nil {
+		t.Errorf("Ожидался отказ сохранить слишком большой фрагмент")
+	}
+	if _, err := remote.GetChunk(Hash(make([]byte, MaxChunkSize+1))); !errors.Is(err, ErrChunkNotFound) {
+		t.Errorf("Слишком большой фрагмент не должен сохраняться, получено %v", err)
+	}
+
+	// Без подтверждений от узлов репликация считается неудачной
+	offline := &Replicator{Local: local, Network: &network.Network{}, MinReplicas: 1}
+	_, err = Upload(offline, "log.txt", []byte("node log"))
+	if !errors.Is(err, ErrNotReplicated) {
+		t.Errorf("Ожидалась ошибка ErrNotReplicated, получено %v", err)
+	}
+}
@@ -0,0 +1,334 @@
+package attachment
+
+import (
+	"blockchainStorage/internal/address"
+	"blockchainStorage/internal/identity"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// KeeperNamespace префикс записей о фрагментах других узлов в storage.DataStore
+	KeeperNamespace = "hosted_"
+
+	// DefaultPeerQuota объем фрагментов, который узел хранит для одного узла-источника
+	DefaultPeerQuota = 4 * MaxFileSize
+	// DefaultRetention время хранения фрагмента, присланного другим узлом
+	DefaultRetention = 30 * 24 * time.Hour
+
+	// putDomain отделяет подписи и штампы фрагментов от других подписей
+	putDomain = "blockchainChat chunk"
+)
+
+var (
+	ErrPutNotAdmitted = errors.New("chunk has no valid stamp or registered key signature")
+	ErrQuotaExceeded  = errors.New("peer has exceeded its chunk storage quota")
+)
+
+// HostedStore хранилище фрагментов, из которого можно удалять фрагменты с
+// истекшим сроком хранения (см. storage.DataStore)
+type HostedStore interface {
+	ChunkStore
+	DeleteChunk(hash string) error
+}
+
+// RecordStore хранилище записей о принятых фрагментах (см. storage.Namespace).
+// Пакет storage зависит от блокчейна, поэтому интерфейс объявлен здесь.
+type RecordStore interface {
+	Put(key string, value interface{}) error
+	Get(key string, value interface{}) ([]byte, error)
+	Delete(key string) error
+	Keys() ([]string, error)
+}
+
+// KeyChecker реестр ключей (см. blockchain.Blockchain)
+type KeyChecker interface {
+	HasActiveKey(address string) (bool, error)
+}
+
+// PutRequest фрагмент, переданный узлу на хранение. Фрагмент ничего не стоит
+// отправителю, поэтому узел принимает его только со штампом или с подписью
+// ключа, зарегистрированного в блокчейне.
+type PutRequest struct {
+	Data []byte
+	// Stamp hashcash-штамп над хэшем фрагмента (см. StampChunk)
+	Stamp     int64  `json:",omitempty"`
+	PublicKey []byte `json:",omitempty"`
+	Signature []byte `json:",omitempty"`
+}
+
+// NewPutRequest создает запрос на хранение фрагмента. Запрос подписывается
+// ключом signer, если он задан, и получает штамп сложности difficulty, если
+// она больше нуля.
+func NewPutRequest(data []byte, signer *ecdsa.PrivateKey, difficulty int) (*PutRequest, error) {
+	hash := Hash(data)
+	request := &PutRequest{Data: data}
+
+	if difficulty > 0 {
+		request.Stamp = StampChunk(hash, difficulty)
+	}
+
+	if signer != nil {
+		signature, err := ecdsa.SignASN1(rand.Reader, signer, putSigningHash(hash))
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign chunk %s: %w", hash, err)
+		}
+
+		request.PublicKey = identity.MarshalPublicKey(&signer.PublicKey)
+		request.Signature = signature
+	}
+
+	return request, nil
+}
+
+// StampChunk подбирает hashcash-штамп заданной сложности для фрагмента с хэшем hash
+func StampChunk(hash string, difficulty int) int64 {
+	target := strings.Repeat("0", difficulty)
+	var nonce int64 = 0
+
+	for !strings.HasPrefix(chunkStampHash(hash, nonce), target) {
+		nonce++
+	}
+
+	return nonce
+}
+
+// VerifyChunkStamp проверяет, что штамп имеет требуемую сложность и вычислен
+// для фрагмента с хэшем hash
+func VerifyChunkStamp(hash string, stamp int64, difficulty int) bool {
+	return strings.HasPrefix(chunkStampHash(hash, stamp), strings.Repeat("0", difficulty))
+}
+
+func chunkStampHash(hash string, nonce int64) string {
+	sum := sha256.Sum256([]byte(putDomain + hash + strconv.FormatInt(nonce, 10)))
+	return hex.EncodeToString(sum[:])
+}
+
+func putSigningHash(hash string) []byte {
+	sum := sha256.Sum256([]byte(putDomain + hash))
+	return sum[:]
+}
+
+// PutPolicy условия приема фрагментов от других узлов
+type PutPolicy struct {
+	// Keys реестр ключей, nil если подпись ключом не учитывается
+	Keys KeyChecker
+	// StampDifficulty сложность штампа, 0 - штамп не принимается
+	StampDifficulty int
+	// PeerQuota объем фрагментов, хранимых для одного узла-источника
+	PeerQuota int64
+	// Retention время хранения фрагмента с момента последней передачи
+	Retention time.Duration
+}
+
+// DefaultPutPolicy возвращает условия приема с квотой и сроком хранения по умолчанию
+func DefaultPutPolicy(keys KeyChecker, stampDifficulty int) PutPolicy {
+	return PutPolicy{
+		Keys:            keys,
+		StampDifficulty: stampDifficulty,
+		PeerQuota:       DefaultPeerQuota,
+		Retention:       DefaultRetention,
+	}
+}
+
+// hostedChunk запись о фрагменте, принятом от другого узла
+type hostedChunk struct {
+	Peer      string
+	Size      int64
+	ExpiresAt int64
+}
+
+// Keeper хранит фрагменты, присланные другими узлами. Узел не может прочитать
+// зашифрованные манифесты и узнать, на какие фрагменты ссылаются сообщения,
+// поэтому фрагмент хранится Retention с момента последней передачи и затем
+// удаляется Prune. Загрузивший узел продлевает хранение, передавая фрагмент
+// повторно. Фрагменты, сохраненные узлом для своих вложений, Keeper не удаляет.
+type Keeper struct {
+	mu      sync.Mutex
+	store   HostedStore
+	records RecordStore
+	policy  PutPolicy
+	chunks  map[string]*hostedChunk
+	usage   map[string]int64
+	now     func() time.Time
+}
+
+// NewKeeper создает хранилище фрагментов других узлов и загружает записи о
+// ранее принятых фрагментах
+func NewKeeper(store HostedStore, records RecordStore, policy PutPolicy) (*Keeper, error) {
+	k := &Keeper{
+		store:   store,
+		records: records,
+		policy:  policy,
+		chunks:  make(map[string]*hostedChunk),
+		usage:   make(map[string]int64),
+		now:     time.Now,
+	}
+
+	hashes, err := records.Keys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list hosted chunks: %w", err)
+	}
+
+	for _, hash := range hashes {
+		var record hostedChunk
+		_, err = records.Get(hash, &record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load hosted chunk %s: %w", hash, err)
+		}
+
+		k.chunks[hash] = &record
+		k.usage[record.Peer] += record.Size
+	}
+
+	return k, nil
+}
+
+// Accept проверяет запрос узла peer и сохраняет фрагмент, возвращает его хэш
+func (k *Keeper) Accept(peer string, request *PutRequest) (string, error) {
+	if len(request.Data) == 0 || len(request.Data) > MaxChunkSize {
+		return "", fmt.Errorf("%w: %d bytes", ErrChunkTooLarge, len(request.Data))
+	}
+
+	hash := Hash(request.Data)
+	err := k.admit(hash, request)
+	if err != nil {
+		return hash, err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	expiresAt := k.now().Add(k.policy.Retention).UnixNano()
+	record, hosted := k.chunks[hash]
+	if hosted {
+		// Повторная передача продлевает хранение, квота не расходуется
+		record.ExpiresAt = expiresAt
+		return hash, k.saveRecord(hash, record)
+	}
+
+	_, err = k.store.GetChunk(hash)
+	if err == nil {
+		// Фрагмент уже хранится узлом для своего вложения
+		return hash, nil
+	}
+
+	size := int64(len(request.Data))
+	if k.usage[peer]+size > k.policy.PeerQuota {
+		return hash, fmt.Errorf("%w: %s", ErrQuotaExceeded, peer)
+	}
+
+	record = &hostedChunk{Peer: peer, Size: size, ExpiresAt: expiresAt}
+	err = k.saveRecord(hash, record)
+	if err != nil {
+		return hash, err
+	}
+
+	err = k.store.SaveChunk(hash, request.Data)
+	if err != nil {
+		_ = k.records.Delete(hash)
+		return hash, fmt.Errorf("failed to save chunk %s: %w", hash, err)
+	}
+
+	k.chunks[hash] = record
+	k.usage[peer] += size
+	return hash, nil
+}
+
+// admit проверяет штамп или подпись фрагмента ключом из реестра
+func (k *Keeper) admit(hash string, request *PutRequest) error {
+	if k.policy.StampDifficulty > 0 && VerifyChunkStamp(hash, request.Stamp, k.policy.StampDifficulty) {
+		return nil
+	}
+
+	if k.policy.Keys != nil && request.Signature != nil {
+		publicKey, err := identity.ParsePublicKey(request.PublicKey)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrPutNotAdmitted, err)
+		}
+
+		if !ecdsa.VerifyASN1(publicKey, putSigningHash(hash), request.Signature) {
+			return fmt.Errorf("%w: invalid signature", ErrPutNotAdmitted)
+		}
+
+		sender := address.FromPublicKey(request.PublicKey)
+		active, err := k.policy.Keys.HasActiveKey(sender)
+		if err != nil {
+			return fmt.Errorf("failed to look up sender keys: %w", err)
+		}
+
+		if active {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrPutNotAdmitted, hash)
+}
+
+// Prune удаляет фрагменты с истекшим сроком хранения и возвращает их количество
+func (k *Keeper) Prune() (int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := k.now().UnixNano()
+	pruned := 0
+	for hash, record := range k.chunks {
+		if record.ExpiresAt > now {
+			continue
+		}
+
+		err := k.store.DeleteChunk(hash)
+		if err != nil {
+			return pruned, fmt.Errorf("failed to delete chunk %s: %w", hash, err)
+		}
+
+		err = k.records.Delete(hash)
+		if err != nil {
+			return pruned, fmt.Errorf("failed to delete hosted chunk %s: %w", hash, err)
+		}
+
+		delete(k.chunks, hash)
+		k.usage[record.Peer] -= record.Size
+		if k.usage[record.Peer] <= 0 {
+			delete(k.usage, record.Peer)
+		}
+		pruned++
+	}
+
+	return pruned, nil
+}
+
+// Run удаляет фрагменты с истекшим сроком хранения каждые interval, пока не закрыт done
+func (k *Keeper) Run(interval time.Duration, onError func(err error), done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			_, err := k.Prune()
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+func (k *Keeper) saveRecord(hash string, record *hostedChunk) error {
+	err := k.records.Put(hash, record)
+	if err != nil {
+		return fmt.Errorf("failed to save hosted chunk %s: %w", hash, err)
+	}
+
+	return nil
+}
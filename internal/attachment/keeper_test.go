@@ -0,0 +1,235 @@
+package attachment
+
+import (
+	"blockchainStorage/internal/address"
+	"blockchainStorage/internal/identity"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryRecords хранилище записей в памяти
+type memoryRecords struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func newMemoryRecords() *memoryRecords {
+	return &memoryRecords{values: make(map[string][]byte)}
+}
+
+func (r *memoryRecords) Put(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values[key] = data
+	return nil
+}
+
+func (r *memoryRecords) Get(key string, value interface{}) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, ok := r.values[key]
+	if !ok {
+		return nil, errors.New("key not found")
+	}
+	return data, json.Unmarshal(data, value)
+}
+
+func (r *memoryRecords) Delete(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.values, key)
+	return nil
+}
+
+func (r *memoryRecords) Keys() ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var keys []string
+	for key := range r.values {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// registeredKeys реестр ключей с заданными адресами
+type registeredKeys map[string]bool
+
+func (keys registeredKeys) HasActiveKey(address string) (bool, error) {
+	return keys[address], nil
+}
+
+func TestKeeperAdmission(t *testing.T) {
+	registered, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Ошибка создания ключа: %v", err)
+	}
+	unknown, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Ошибка создания ключа: %v", err)
+	}
+
+	keys := registeredKeys{address.FromPublicKey(identity.MarshalPublicKey(&registered.PublicKey)): true}
+	store := newMemoryStore()
+	keeper, err := NewKeeper(store, newMemoryRecords(), DefaultPutPolicy(keys, 2))
+	if err != nil {
+		t.Fatalf("Ошибка создания хранилища фрагментов узлов: %v", err)
+	}
+
+	// Фрагмент без штампа и подписи отклоняется
+	request, err := NewPutRequest([]byte("anonymous"), nil, 0)
+	if err != nil {
+		t.Fatalf("Ошибка создания запроса: %v", err)
+	}
+	for VerifyChunkStamp(Hash(request.Data), request.Stamp, 2) {
+		request.Stamp++
+	}
+	_, err = keeper.Accept("10.0.0.1", request)
+	if !errors.Is(err, ErrPutNotAdmitted) {
+		t.Errorf("Ожидалась ошибка ErrPutNotAdmitted, получено %v", err)
+	}
+
+	// Подпись ключом, не зарегистрированным в блокчейне, не принимается
+	request, err = NewPutRequest([]byte("unknown key"), unknown, 0)
+	if err != nil {
+		t.Fatalf("Ошибка создания запроса: %v", err)
+	}
+	for VerifyChunkStamp(Hash(request.Data), request.Stamp, 2) {
+		request.Stamp++
+	}
+	_, err = keeper.Accept("10.0.0.1", request)
+	if !errors.Is(err, ErrPutNotAdmitted) {
+		t.Errorf("Ожидалась ошибка ErrPutNotAdmitted, получено %v", err)
+	}
+
+	// Подпись не переносится на другой фрагмент
+	request, err = NewPutRequest([]byte("signed"), registered, 0)
+	if err != nil {
+		t.Fatalf("Ошибка создания запроса: %v", err)
+	}
+	forged := *request
+	forged.Data = []byte("forged")
+	for VerifyChunkStamp(Hash(forged.Data), forged.Stamp, 2) {
+		forged.Stamp++
+	}
+	_, err = keeper.Accept("10.0.0.1", &forged)
+	if !errors.Is(err, ErrPutNotAdmitted) {
+		t.Errorf("Ожидалась ошибка ErrPutNotAdmitted, получено %v", err)
+	}
+
+	// Фрагменты с подписью зарегистрированного ключа или штампом сохраняются
+	hash, err := keeper.Accept("10.0.0.1", request)
+	if err != nil {
+		t.Fatalf("Ошибка сохранения подписанного фрагмента: %v", err)
+	}
+	if _, err := store.GetChunk(hash); err != nil {
+		t.Errorf("Подписанный фрагмент не сохранен: %v", err)
+	}
+
+	request, err = NewPutRequest([]byte("stamped"), nil, 2)
+	if err != nil {
+		t.Fatalf("Ошибка создания запроса: %v", err)
+	}
+	_, err = keeper.Accept("10.0.0.1", request)
+	if err != nil {
+		t.Errorf("Ошибка сохранения фрагмента со штампом: %v", err)
+	}
+}
+
+func TestKeeperQuotaAndRetention(t *testing.T) {
+	store := newMemoryStore()
+	records := newMemoryRecords()
+	policy := PutPolicy{StampDifficulty: 1, PeerQuota: 10, Retention: time.Hour}
+	keeper, err := NewKeeper(store, records, policy)
+	if err != nil {
+		t.Fatalf("Ошибка создания хранилища фрагментов узлов: %v", err)
+	}
+	now := time.Now()
+	keeper.now = func() time.Time { return now }
+
+	put := func(peer string, data string) (string, error) {
+		request, err := NewPutRequest([]byte(data), nil, 1)
+		if err != nil {
+			t.Fatalf("Ошибка создания запроса: %v", err)
+		}
+		return keeper.Accept(peer, request)
+	}
+
+	// Собственный фрагмент узла хранится без срока
+	local := []byte("local")
+	_ = store.SaveChunk(Hash(local), local)
+	_, err = put("10.0.0.1", "local")
+	if err != nil {
+		t.Fatalf("Ошибка повторной передачи собственного фрагмента: %v", err)
+	}
+
+	// Квота ограничивает объем фрагментов одного узла
+	first, err := put("10.0.0.1", "12345678")
+	if err != nil {
+		t.Fatalf("Ошибка сохранения фрагмента: %v", err)
+	}
+	_, err = put("10.0.0.1", "abcdefgh")
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Ожидалась ошибка ErrQuotaExceeded, получено %v", err)
+	}
+	_, err = put("10.0.0.2", "abcdefgh")
+	if err != nil {
+		t.Errorf("Квота другого узла не должна расходоваться: %v", err)
+	}
+
+	// Повторная передача продлевает хранение и не расходует квоту
+	now = now.Add(30 * time.Minute)
+	_, err = put("10.0.0.1", "12345678")
+	if err != nil {
+		t.Errorf("Ошибка повторной передачи фрагмента: %v", err)
+	}
+
+	// Записи о фрагментах восстанавливаются после перезапуска узла
+	keeper, err = NewKeeper(store, records, policy)
+	if err != nil {
+		t.Fatalf("Ошибка создания хранилища фрагментов узлов: %v", err)
+	}
+	keeper.now = func() time.Time { return now }
+	_, err = put("10.0.0.1", "ABCDEFGH")
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Ожидалась ошибка ErrQuotaExceeded после перезапуска, получено %v", err)
+	}
+
+	// По истечении срока удаляются только непродленные фрагменты других узлов
+	now = now.Add(45 * time.Minute)
+	pruned, err := keeper.Prune()
+	if err != nil || pruned != 1 {
+		t.Fatalf("Ожидалось удаление одного фрагмента, получено %d, %v", pruned, err)
+	}
+	if _, err := store.GetChunk(first); err != nil {
+		t.Errorf("Продленный фрагмент не должен удаляться: %v", err)
+	}
+	if _, err := store.GetChunk(Hash(local)); err != nil {
+		t.Errorf("Собственный фрагмент узла не должен удаляться: %v", err)
+	}
+
+	now = now.Add(time.Hour)
+	pruned, err = keeper.Prune()
+	if err != nil || pruned != 1 {
+		t.Fatalf("Ожидалось удаление одного фрагмента, получено %d, %v", pruned, err)
+	}
+	if _, err := store.GetChunk(first); !errors.Is(err, ErrChunkNotFound) {
+		t.Errorf("Фрагмент с истекшим сроком должен удаляться, получено %v", err)
+	}
+
+	// Удаление фрагментов освобождает квоту
+	_, err = put("10.0.0.1", "ABCDEFGH")
+	if err != nil {
+		t.Errorf("Квота должна освобождаться после удаления фрагментов: %v", err)
+	}
+}
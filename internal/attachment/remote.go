@@ -0,0 +1,187 @@
+package attachment
+
+import (
+	"blockchainStorage/internal/network"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+)
+
+const (
+	// CommandGetChunk запрос фрагмента у узла, Data содержит хэш фрагмента
+	CommandGetChunk = "getchunk"
+	// CommandChunk ответ с содержимым фрагмента
+	CommandChunk = "chunk"
+	// CommandChunkNotFound ответ узла, у которого нет запрошенного фрагмента
+	CommandChunkNotFound = "chunknotfound"
+	// CommandPutChunk передача фрагмента узлу на хранение, Data содержит PutRequest
+	CommandPutChunk = "putchunk"
+	// CommandChunkStored подтверждение сохранения, Data содержит хэш фрагмента
+	CommandChunkStored = "chunkstored"
+	// CommandChunkRejected отказ узла сохранить фрагмент
+	CommandChunkRejected = "chunkrejected"
+)
+
+var ErrNotReplicated = errors.New("chunk was not replicated to enough nodes")
+
+// HandleGetChunk отвечает на запрос getchunk фрагментом из локального хранилища
+func HandleGetChunk(store ChunkFetcher, msg *network.Message, conn net.Conn) error {
+	hash := string(msg.Data)
+	err := ValidateHash(hash)
+	if err != nil {
+		return network.Reply(conn, CommandChunkNotFound, msg.Data)
+	}
+
+	data, err := store.GetChunk(hash)
+	if err != nil {
+		// Ошибка хранилища не раскрывается запросившему узлу
+		replyErr := network.Reply(conn, CommandChunkNotFound, msg.Data)
+		if replyErr != nil {
+			return replyErr
+		}
+		return fmt.Errorf("failed to get chunk %s: %w", hash, err)
+	}
+
+	return network.Reply(conn, CommandChunk, data)
+}
+
+// RemoteFetcher загружает фрагменты с узлов сети, перебирая их по порядку.
+// Содержимое проверяется по хэшу в Download, поэтому узлам не нужно доверять.
+type RemoteFetcher struct {
+	Network *network.Network
+}
+
+// GetChunk запрашивает фрагмент у узлов сети
+func (f *RemoteFetcher) GetChunk(hash string) ([]byte, error) {
+	var lastErr error = ErrChunkNotFound
+	for _, node := range f.Network.NodeList {
+		response, err := f.Network.Request(node.Address, CommandGetChunk, []byte(hash))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if response.Command != CommandChunk || Hash(response.Data) != hash {
+			continue
+		}
+
+		return response.Data, nil
+	}
+
+	return nil, fmt.Errorf("chunk %s: %w", hash, lastErr)
+}
+
+// HandlePutChunk принимает фрагмент, присланный узлом, на хранение в keeper
+// (см. Keeper.Accept). Хэш вычисляется по содержимому, поэтому узел не может
+// подменить фрагмент.
+func HandlePutChunk(keeper *Keeper, msg *network.Message, conn net.Conn) error {
+	var request PutRequest
+	err := json.Unmarshal(msg.Data, &request)
+	if err != nil {
+		replyErr := network.Reply(conn, CommandChunkRejected, nil)
+		if replyErr != nil {
+			return replyErr
+		}
+		return fmt.Errorf("failed to parse put request: %w", err)
+	}
+
+	hash, err := keeper.Accept(peerHost(conn), &request)
+	if err != nil {
+		replyErr := network.Reply(conn, CommandChunkRejected, []byte(hash))
+		if replyErr != nil {
+			return replyErr
+		}
+		return err
+	}
+
+	return network.Reply(conn, CommandChunkStored, []byte(hash))
+}
+
+// peerHost возвращает адрес узла без порта: квота не должна обходиться
+// переподключением с другого порта
+func peerHost(conn net.Conn) string {
+	remote := conn.RemoteAddr().String()
+	host, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		return remote
+	}
+
+	return host
+}
+
+// PushChunk передает фрагмент узлу и ждет подтверждения сохранения
+func PushChunk(n *network.Network, address string, request *PutRequest) error {
+	hash := Hash(request.Data)
+	data, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal put request: %w", err)
+	}
+
+	response, err := n.Request(address, CommandPutChunk, data)
+	if err != nil {
+		return fmt.Errorf("failed to push chunk %s to %s: %w", hash, address, err)
+	}
+
+	if response.Command != CommandChunkStored || string(response.Data) != hash {
+		return fmt.Errorf("node %s rejected chunk %s", address, hash)
+	}
+
+	return nil
+}
+
+// Replicator хранилище фрагментов, которое сохраняет их локально и рассылает
+// узлам сети. Передается в Upload, чтобы вложение было доступно, пока
+// загрузивший узел не в сети.
+type Replicator struct {
+	Local   ChunkStore
+	Network *network.Network
+	// MinReplicas число узлов, которые должны подтвердить сохранение фрагмента
+	MinReplicas int
+	// Signer ключ, зарегистрированный в блокчейне, которым подписываются фрагменты
+	Signer *ecdsa.PrivateKey
+	// StampDifficulty сложность штампа фрагментов, 0 - штамп не вычисляется
+	StampDifficulty int
+}
+
+// SaveChunk сохраняет фрагмент локально и передает его всем узлам сети
+func (r *Replicator) SaveChunk(hash string, data []byte) error {
+	err := r.Local.SaveChunk(hash, data)
+	if err != nil {
+		return err
+	}
+
+	request, err := NewPutRequest(data, r.Signer, r.StampDifficulty)
+	if err != nil {
+		return err
+	}
+
+	stored := 0
+	var lastErr error
+	for _, node := range r.Network.NodeList {
+		err = PushChunk(r.Network, node.Address, request)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		stored++
+	}
+
+	if stored < r.MinReplicas {
+		return fmt.Errorf("%w: %d of %d (last error: %v)", ErrNotReplicated, stored, r.MinReplicas, lastErr)
+	}
+
+	return nil
+}
+
+// GetChunk возвращает фрагмент из локального хранилища, а при его отсутствии
+// запрашивает у узлов сети
+func (r *Replicator) GetChunk(hash string) ([]byte, error) {
+	data, err := r.Local.GetChunk(hash)
+	if err == nil {
+		return data, nil
+	}
+
+	return (&RemoteFetcher{Network: r.Network}).GetChunk(hash)
+}
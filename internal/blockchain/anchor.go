@@ -0,0 +1,67 @@
+package blockchain
+
+import (
+	"blockchainStorage/internal/transaction"
+	"errors"
+	"fmt"
+)
+
+const AnchorStatePrefix = "anchor_"
+
+var (
+	ErrAnchorNotFound  = errors.New("anchor not found")
+	ErrDuplicateAnchor = errors.New("anchor with this id already exists")
+)
+
+// AnchorRecord пакет сообщений, закрепленный в блокчейне отправителем или узлом,
+// принявшим сообщения
+type AnchorRecord struct {
+	transaction.Anchor
+	TxID   string
+	Sender string
+	Height int64
+}
+
+// LookupAnchor возвращает пакет сообщений, закрепленный транзакцией txID
+func (bc *Blockchain) LookupAnchor(txID string) (*AnchorRecord, error) {
+	var record AnchorRecord
+	exists, err := newStateBatch(bc.db).getValue(AnchorStatePrefix+txID, &record)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrAnchorNotFound, txID)
+	}
+
+	return &record, nil
+}
+
+// applyAnchor сохраняет корень пакета сообщений вместе с отправителем и индексом блока
+func applyAnchor(state *stateBatch, tx *transaction.Transaction, height int64) error {
+	anchor, err := tx.Anchor()
+	if err != nil {
+		return err
+	}
+
+	err = anchor.Validate()
+	if err != nil {
+		return err
+	}
+
+	var existing AnchorRecord
+	exists, err := state.getValue(AnchorStatePrefix+tx.ID, &existing)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: %s", ErrDuplicateAnchor, tx.ID)
+	}
+
+	return state.putValue(AnchorStatePrefix+tx.ID, &AnchorRecord{
+		Anchor: *anchor,
+		TxID:   tx.ID,
+		Sender: tx.Sender,
+		Height: height,
+	})
+}
@@ -0,0 +1,58 @@
+package blockchain
+
+import "blockchainStorage/internal/transaction"
+
+const BalanceStatePrefix = "bal_"
+
+// Balance возвращает баланс адреса с учетом подтвержденных блоков
+func (bc *Blockchain) Balance(address string) (int64, error) {
+	return getBalance(newStateBatch(bc.db), address)
+}
+
+func getBalance(state *stateBatch, address string) (int64, error) {
+	var balance int64
+	_, err := state.getValue(BalanceStatePrefix+address, &balance)
+	if err != nil {
+		return 0, err
+	}
+
+	return balance, nil
+}
+
+func addBalance(state *stateBatch, address string, amount int64) error {
+	if amount == 0 {
+		return nil
+	}
+
+	balance, err := getBalance(state, address)
+	if err != nil {
+		return err
+	}
+
+	return state.putValue(BalanceStatePrefix+address, balance+amount)
+}
+
+// newCoinbase создает транзакцию вознаграждения майнера: награда за блок и
+// комиссии включенных транзакций. Возвращает nil, если платить некому или нечего.
+func (bc *Blockchain) newCoinbase(state *stateBatch, minerAddress string, transactions []*transaction.Transaction) (*transaction.Transaction, error) {
+	if minerAddress == "" {
+		return nil, nil
+	}
+
+	amount := int64(bc.BlockReward)
+	for _, tx := range transactions {
+		amount += tx.Fee
+	}
+
+	if amount <= 0 {
+		return nil, nil
+	}
+
+	coinbase := transaction.NewCoinbaseTransaction(minerAddress, amount)
+	err := addBalance(state, minerAddress, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	return coinbase, nil
+}
@@ -1,10 +1,26 @@
 package blockchain
 
 import (
+	"blockchainStorage/internal/address"
+	"blockchainStorage/internal/transaction"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
 	"time"
 )
 
+// HeightStatePrefix индекс хэшей блоков основной цепочки по их высоте
+const HeightStatePrefix = "height_"
+
+var (
+	ErrGenesisDisconnect   = errors.New("cannot disconnect genesis block")
+	ErrInvalidMinerAddress = errors.New("invalid miner address")
+	ErrBlockNotFound       = errors.New("block not found")
+)
+
 type Block struct {
 	Index        int64
 	Timestamp    int64
@@ -14,15 +30,22 @@ type Block struct {
 	Hash         string
 	Difficulty   int
 	MinerAddress string
+	Transactions []*transaction.Transaction
 }
 
 type Blockchain struct {
-	Difficulty int
-	Tip        []byte
-	db         DbInterface
+	Difficulty  int
+	BlockReward int
+	Tip         []byte
+	db          DbInterface
+	pool        TxPool
+	handlers    []BlockHandler
 }
 
-func NewBlock(index int64, timestamp int64, data string, prevHash string, difficulty int, minerAddress string) *Block {
+// BlockHandler получает блоки, присоединенные к основной цепочке
+type BlockHandler func(block *Block)
+
+func NewBlock(index int64, timestamp int64, data string, prevHash string, difficulty int, minerAddress string, transactions []*transaction.Transaction) *Block {
 	block := &Block{
 		Index:        index,
 		Timestamp:    timestamp,
@@ -30,6 +53,7 @@ func NewBlock(index int64, timestamp int64, data string, prevHash string, diffic
 		PrevHash:     prevHash,
 		Difficulty:   difficulty,
 		MinerAddress: minerAddress,
+		Transactions: transactions,
 	}
 
 	pow := NewProofOfWork(block, difficulty)
@@ -69,6 +93,17 @@ func NewBlockchain(difficulty int, dbStorage DbInterface) (*Blockchain, error) {
 		return nil, err
 	}
 
+	genesisState := newStateBatch(dbStorage)
+	err = genesisState.putValue(heightStateKey(genesisBlock.Index), genesisBlock.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	err = genesisState.commit(dbStorage, genesisBlock.Hash)
+	if err != nil {
+		return nil, err
+	}
+
 	err = dbStorage.SaveTipToDB(genesisBlock.Hash)
 	if err != nil {
 		return nil, err
@@ -79,24 +114,58 @@ func NewBlockchain(difficulty int, dbStorage DbInterface) (*Blockchain, error) {
 	return blockchain, nil
 }
 
+// SetTxPool подключает пул неподтвержденных транзакций, из которого AddBlock
+// берет транзакции для нового блока
+func (bc *Blockchain) SetTxPool(pool TxPool) {
+	bc.pool = pool
+}
+
+// Subscribe подписывает handler на новые блоки основной цепочки. Обработчики
+// вызываются синхронно после сохранения блока, в порядке подписки.
+func (bc *Blockchain) Subscribe(handler BlockHandler) {
+	bc.handlers = append(bc.handlers, handler)
+}
+
 func (bc *Blockchain) AddBlock(data string, minerAddress string) error {
-	prevBlockData, err := bc.db.GetBlockFromDB(string(bc.Tip))
+	if minerAddress != "" {
+		err := address.Validate(minerAddress)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidMinerAddress, err)
+		}
+	}
+
+	prevBlock, err := bc.getBlock(string(bc.Tip))
 	if err != nil {
 		return err
 	}
 
-	var prevBlock Block
-	err = json.Unmarshal(prevBlockData, &prevBlock)
+	state := newStateBatch(bc.db)
+	transactions := bc.selectTransactions(state, prevBlock.Index+1)
+
+	coinbase, err := bc.newCoinbase(state, minerAddress, transactions)
 	if err != nil {
 		return err
 	}
+	if coinbase != nil {
+		transactions = append([]*transaction.Transaction{coinbase}, transactions...)
+	}
 
-	newBlock := NewBlock(prevBlock.Index+1, time.Now().UnixNano(), data, prevBlock.Hash, bc.Difficulty, minerAddress)
+	newBlock := NewBlock(prevBlock.Index+1, time.Now().UnixNano(), data, prevBlock.Hash, bc.Difficulty, minerAddress, transactions)
 	err = bc.db.SaveBlockToDB(newBlock)
 	if err != nil {
 		return err
 	}
 
+	err = state.putValue(heightStateKey(newBlock.Index), newBlock.Hash)
+	if err != nil {
+		return err
+	}
+
+	err = state.commit(bc.db, newBlock.Hash)
+	if err != nil {
+		return err
+	}
+
 	err = bc.db.SaveTipToDB(newBlock.Hash)
 	if err != nil {
 		return err
@@ -104,9 +173,140 @@ func (bc *Blockchain) AddBlock(data string, minerAddress string) error {
 
 	bc.Tip = []byte(newBlock.Hash)
 
+	if bc.pool != nil {
+		bc.pool.Remove(newBlock.TransactionIDs()...)
+	}
+
+	err = bc.pruneExpired(newBlock.Index)
+	if err != nil {
+		return fmt.Errorf("failed to prune expired messages: %w", err)
+	}
+
+	for _, handler := range bc.handlers {
+		handler(newBlock)
+	}
+
+	return nil
+}
+
+// DisconnectTip откатывает последний блок цепочки при реорганизации и
+// возвращает его транзакции в пул неподтвержденных транзакций
+func (bc *Blockchain) DisconnectTip() (*Block, error) {
+	tipBlock, err := bc.getBlock(string(bc.Tip))
+	if err != nil {
+		return nil, err
+	}
+
+	if tipBlock.PrevHash == "" {
+		return nil, ErrGenesisDisconnect
+	}
+
+	err = revertState(bc.db, tipBlock.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	err = bc.db.SaveTipToDB(tipBlock.PrevHash)
+	if err != nil {
+		return nil, err
+	}
+
+	bc.Tip = []byte(tipBlock.PrevHash)
+
+	if bc.pool != nil {
+		for _, tx := range tipBlock.Transactions {
+			if tx.IsCoinbase() {
+				continue
+			}
+
+			// Транзакция может не поместиться в пул, это не мешает откату блока
+			_ = bc.pool.Add(tx)
+		}
+	}
+
+	return tipBlock, nil
+}
+
+// nextHeight возвращает индекс следующего блока цепочки
+func (bc *Blockchain) nextHeight() (int64, error) {
+	tipBlock, err := bc.getBlock(string(bc.Tip))
+	if err != nil {
+		return 0, err
+	}
+
+	return tipBlock.Index + 1, nil
+}
+
+// BlockAtHeight возвращает блок основной цепочки с индексом height
+func (bc *Blockchain) BlockAtHeight(height int64) (*Block, error) {
+	var hash string
+	exists, err := newStateBatch(bc.db).getValue(heightStateKey(height), &hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, fmt.Errorf("%w: %d", ErrBlockNotFound, height)
+	}
+
+	return bc.getBlock(hash)
+}
+
+func heightStateKey(height int64) string {
+	return HeightStatePrefix + strconv.FormatInt(height, 10)
+}
+
+func (bc *Blockchain) getBlock(hash string) (*Block, error) {
+	blockData, err := bc.db.GetBlockFromDB(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	var block Block
+	err = json.Unmarshal(blockData, &block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &block, nil
+}
+
+// TransactionIDs возвращает идентификаторы транзакций блока
+func (block *Block) TransactionIDs() []string {
+	ids := make([]string, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		ids[i] = tx.ID
+	}
+
+	return ids
+}
+
+// transaction возвращает транзакцию блока с идентификатором id или nil
+func (block *Block) transaction(id string) *transaction.Transaction {
+	for _, tx := range block.Transactions {
+		if tx.ID == id {
+			return tx
+		}
+	}
+
 	return nil
 }
 
+// HashTransactions вычисляет хэш транзакций блока для доказательства работы
+func (block *Block) HashTransactions() string {
+	h := sha256.New()
+	for _, tx := range block.Transactions {
+		txHash, err := tx.Hash()
+		if err != nil {
+			continue
+		}
+
+		h.Write(txHash)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // Serialize сериализует блок в байтовый массив
 func (block *Block) Serialize() ([]byte, error) {
 	data := make(map[string]interface{})
@@ -118,6 +318,7 @@ func (block *Block) Serialize() ([]byte, error) {
 	data["Hash"] = block.Hash
 	data["Difficulty"] = block.Difficulty
 	data["MinerAddress"] = block.MinerAddress
+	data["Transactions"] = block.Transactions
 
 	return json.Marshal(data)
 }
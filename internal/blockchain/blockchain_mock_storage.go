@@ -9,6 +9,7 @@ type MockDbStorage struct {
 	blockchainExistsInDB bool
 	blockchainTip        []byte
 	blocks               map[string][]byte
+	state                map[string][]byte
 }
 
 func NewMockDbStorage() *MockDbStorage {
@@ -16,6 +17,7 @@ func NewMockDbStorage() *MockDbStorage {
 		blockchainExistsInDB: false,
 		blockchainTip:        nil,
 		blocks:               make(map[string][]byte),
+		state:                make(map[string][]byte),
 	}
 }
 
@@ -42,6 +44,10 @@ func (db *MockDbStorage) SaveBlockToDB(block *Block) error {
 	return nil
 }
 
+func (db *MockDbStorage) PruneBlockInDB(block *Block) error {
+	return db.SaveBlockToDB(block)
+}
+
 func (db *MockDbStorage) SaveTipToDB(tip string) error {
 	db.blockchainTip = []byte(tip)
 	return nil
@@ -55,3 +61,17 @@ func (db *MockDbStorage) GetBlockFromDB(hash string) ([]byte, error) {
 
 	return blockData, nil
 }
+
+func (db *MockDbStorage) GetStateFromDB(key string) ([]byte, error) {
+	return db.state[key], nil
+}
+
+func (db *MockDbStorage) SaveStateToDB(key string, value []byte) error {
+	db.state[key] = value
+	return nil
+}
+
+func (db *MockDbStorage) DeleteStateFromDB(key string) error {
+	delete(db.state, key)
+	return nil
+}
@@ -1,11 +1,22 @@
 package blockchain
 
 import (
+	"blockchainStorage/internal/address"
+	"blockchainStorage/internal/transaction"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"testing"
 	"time"
 )
 
+var (
+	testMinerAddress  = address.FromPublicKey([]byte("miner"))
+	otherMinerAddress = address.FromPublicKey([]byte("other miner"))
+)
+
 func TestNewBlockchain(t *testing.T) {
 	dbStorage := NewMockDbStorage() // Создаем экземпляр мокированного хранилища данных
 	difficulty := 3
@@ -46,7 +57,7 @@ func TestNewBlockchain(t *testing.T) {
 func TestAddBlock(t *testing.T) {
 	dbStorage := NewMockDbStorage() // Создаем экземпляр мокированного хранилища данных
 	difficulty := 3
-	minerAddress := "miner_address"
+	minerAddress := testMinerAddress
 
 	bc, err := NewBlockchain(difficulty, dbStorage)
 	if err != nil {
@@ -127,3 +138,331 @@ func TestSerialize(t *testing.T) {
 		}
 	}
 }
+
+// testPool простой пул транзакций для тестов блокчейна
+type testPool struct {
+	txs []*transaction.Transaction
+}
+
+func (p *testPool) Add(tx *transaction.Transaction) error {
+	p.txs = append(p.txs, tx)
+	return nil
+}
+
+func (p *testPool) Remove(ids ...string) {
+	for _, id := range ids {
+		for i, tx := range p.txs {
+			if tx.ID == id {
+				p.txs = append(p.txs[:i], p.txs[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func (p *testPool) Pending(limit int) []*transaction.Transaction {
+	if limit > 0 && len(p.txs) > limit {
+		return append([]*transaction.Transaction(nil), p.txs[:limit]...)
+	}
+	return append([]*transaction.Transaction(nil), p.txs...)
+}
+
+func TestAddBlockWithTxPool(t *testing.T) {
+	dbStorage := NewMockDbStorage()
+	bc, err := NewBlockchain(2, dbStorage)
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+
+	pool := &testPool{}
+	_ = pool.Add(newSignedTx(t, newTestKey(t), "tx1", 1))
+	_ = pool.Add(newSignedTx(t, newTestKey(t), "tx2", 1))
+	bc.SetTxPool(pool)
+
+	genesisHash := string(bc.Tip)
+	err = bc.AddBlock("Block Data", testMinerAddress)
+	if err != nil {
+		t.Fatalf("failed to add block to blockchain: %v", err)
+	}
+
+	block, err := bc.getBlock(string(bc.Tip))
+	if err != nil {
+		t.Fatalf("failed to get block from DB: %v", err)
+	}
+
+	// Проверяем, что транзакции из пула попали в блок и были удалены из пула
+	if len(block.Transactions) != 2 {
+		t.Fatalf("expected 2 transactions in block, got %d", len(block.Transactions))
+	}
+	if len(pool.txs) != 0 {
+		t.Errorf("expected confirmed transactions to be removed from pool, got %d", len(pool.txs))
+	}
+
+	// При откате блока транзакции возвращаются в пул
+	disconnected, err := bc.DisconnectTip()
+	if err != nil {
+		t.Fatalf("failed to disconnect tip: %v", err)
+	}
+
+	if disconnected.Hash != block.Hash {
+		t.Errorf("expected disconnected block %s, got %s", block.Hash, disconnected.Hash)
+	}
+	if string(bc.Tip) != genesisHash {
+		t.Errorf("expected tip to be genesis block %s, got %s", genesisHash, string(bc.Tip))
+	}
+	if len(pool.txs) != 2 {
+		t.Errorf("expected transactions to be returned to pool, got %d", len(pool.txs))
+	}
+
+	_, err = bc.DisconnectTip()
+	if err != ErrGenesisDisconnect {
+		t.Errorf("expected ErrGenesisDisconnect, got %v", err)
+	}
+}
+
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return privateKey
+}
+
+func newSignedTx(t *testing.T, privateKey *ecdsa.PrivateKey, id string, sequence uint64) *transaction.Transaction {
+	tx := &transaction.Transaction{ID: id, Sequence: sequence}
+	err := tx.Sign(privateKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	return tx
+}
+
+func TestTransactionSequence(t *testing.T) {
+	dbStorage := NewMockDbStorage()
+	bc, err := NewBlockchain(1, dbStorage)
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+
+	pool := &testPool{}
+	bc.SetTxPool(pool)
+	key := newTestKey(t)
+
+	// Транзакции отправителя поступают в пул не по порядку
+	second := newSignedTx(t, key, "tx2", 2)
+	first := newSignedTx(t, key, "tx1", 1)
+	_ = pool.Add(second)
+	_ = pool.Add(first)
+
+	err = bc.AddBlock("Block Data", testMinerAddress)
+	if err != nil {
+		t.Fatalf("failed to add block: %v", err)
+	}
+
+	block, err := bc.getBlock(string(bc.Tip))
+	if err != nil {
+		t.Fatalf("failed to get block from DB: %v", err)
+	}
+	if len(block.Transactions) != 2 || block.Transactions[0].ID != "tx1" || block.Transactions[1].ID != "tx2" {
+		t.Fatalf("expected transactions in sequence order, got %v", block.TransactionIDs())
+	}
+
+	next, err := bc.NextSequence(first.Sender)
+	if err != nil {
+		t.Fatalf("failed to get next sequence: %v", err)
+	}
+	if next != 3 {
+		t.Errorf("expected next sequence 3, got %d", next)
+	}
+
+	// Повторная отправка подтвержденной транзакции отклоняется
+	err = bc.ValidateTransaction(first)
+	if !errors.Is(err, ErrStaleSequence) {
+		t.Errorf("expected ErrStaleSequence, got %v", err)
+	}
+
+	_ = pool.Add(first)
+	err = bc.AddBlock("Block Data", testMinerAddress)
+	if err != nil {
+		t.Fatalf("failed to add block: %v", err)
+	}
+
+	block, err = bc.getBlock(string(bc.Tip))
+	if err != nil {
+		t.Fatalf("failed to get block from DB: %v", err)
+	}
+	if len(block.Transactions) != 0 {
+		t.Errorf("expected replayed transaction to be rejected, got %v", block.TransactionIDs())
+	}
+	if len(pool.txs) != 0 {
+		t.Errorf("expected replayed transaction to be removed from pool")
+	}
+
+	// Откат блоков восстанавливает последовательность отправителя
+	_, err = bc.DisconnectTip()
+	if err != nil {
+		t.Fatalf("failed to disconnect tip: %v", err)
+	}
+	_, err = bc.DisconnectTip()
+	if err != nil {
+		t.Fatalf("failed to disconnect tip: %v", err)
+	}
+
+	next, err = bc.NextSequence(first.Sender)
+	if err != nil {
+		t.Fatalf("failed to get next sequence: %v", err)
+	}
+	if next != 1 {
+		t.Errorf("expected next sequence 1 after reorg, got %d", next)
+	}
+
+	err = bc.ValidateTransaction(first)
+	if err != nil {
+		t.Errorf("expected transaction to be valid after reorg, got %v", err)
+	}
+}
+
+func TestMinerRewardAndFees(t *testing.T) {
+	dbStorage := NewMockDbStorage()
+	bc, err := NewBlockchain(1, dbStorage)
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+	bc.BlockReward = 5
+
+	pool := &testPool{}
+	bc.SetTxPool(pool)
+	key := newTestKey(t)
+	tx := newSignedTx(t, key, "tx1", 1)
+	sender := tx.Sender
+
+	// Отправитель без средств не может оплатить комиссию
+	tx.Fee = 2
+	err = tx.Sign(key)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if !errors.Is(bc.ValidateTransaction(tx), ErrInsufficientFunds) {
+		t.Errorf("expected ErrInsufficientFunds for sender without balance")
+	}
+
+	// Отправитель получает награду за блок как майнер
+	err = bc.AddBlock("Block Data", sender)
+	if err != nil {
+		t.Fatalf("failed to add block: %v", err)
+	}
+
+	block, err := bc.getBlock(string(bc.Tip))
+	if err != nil {
+		t.Fatalf("failed to get block from DB: %v", err)
+	}
+	if len(block.Transactions) != 1 || !block.Transactions[0].IsCoinbase() {
+		t.Fatalf("expected block with single coinbase transaction, got %v", block.TransactionIDs())
+	}
+
+	_ = pool.Add(tx)
+	err = bc.AddBlock("Block Data", otherMinerAddress)
+	if err != nil {
+		t.Fatalf("failed to add block: %v", err)
+	}
+
+	// Майнер получает награду и комиссию, отправитель оплачивает комиссию
+	assertBalance(t, bc, sender, 3)
+	assertBalance(t, bc, otherMinerAddress, 7)
+
+	_, err = bc.DisconnectTip()
+	if err != nil {
+		t.Fatalf("failed to disconnect tip: %v", err)
+	}
+
+	assertBalance(t, bc, sender, 5)
+	assertBalance(t, bc, otherMinerAddress, 0)
+	if len(pool.txs) != 1 || pool.txs[0].ID != tx.ID {
+		t.Errorf("expected only the message transaction to be returned to pool")
+	}
+}
+
+func assertBalance(t *testing.T, bc *Blockchain, address string, expected int64) {
+	balance, err := bc.Balance(address)
+	if err != nil {
+		t.Fatalf("failed to get balance: %v", err)
+	}
+	if balance != expected {
+		t.Errorf("balance of %s: got %d, expected %d", address, balance, expected)
+	}
+}
+
+func TestAddBlockInvalidMinerAddress(t *testing.T) {
+	bc, err := NewBlockchain(1, NewMockDbStorage())
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+
+	// Опечатка в адресе майнера обнаруживается по контрольной сумме
+	typo := []byte(testMinerAddress)
+	typo[5]++
+	err = bc.AddBlock("Block Data", string(typo))
+	if !errors.Is(err, ErrInvalidMinerAddress) {
+		t.Errorf("expected ErrInvalidMinerAddress, got %v", err)
+	}
+}
+
+func TestInvalidRecipientRejected(t *testing.T) {
+	bc, err := NewBlockchain(1, NewMockDbStorage())
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+
+	key := newTestKey(t)
+	tx := &transaction.Transaction{
+		ID:       "tx1",
+		Sequence: 1,
+		Outputs:  []transaction.MessageOutput{{Recipient: "recipient_1"}},
+	}
+	err = tx.Sign(key)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+
+	if !errors.Is(bc.ValidateTransaction(tx), address.ErrInvalidAddress) {
+		t.Errorf("expected transaction with malformed recipient to be rejected")
+	}
+
+	// Адрес и имя пользователя являются допустимыми получателями
+	tx.Outputs = []transaction.MessageOutput{{Recipient: testMinerAddress}, {Recipient: "@alice"}}
+	err = tx.Sign(key)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+
+	err = bc.ValidateTransaction(tx)
+	if err != nil {
+		t.Errorf("expected transaction to be valid, got %v", err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	bc, err := NewBlockchain(1, NewMockDbStorage())
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+
+	// Обработчики получают каждый новый блок в порядке подписки
+	var received []string
+	bc.Subscribe(func(block *Block) {
+		received = append(received, "first:"+block.Hash)
+	})
+	bc.Subscribe(func(block *Block) {
+		received = append(received, "second:"+block.Hash)
+	})
+
+	err = bc.AddBlock("block", testMinerAddress)
+	if err != nil {
+		t.Fatalf("failed to add block: %v", err)
+	}
+
+	expected := []string{"first:" + string(bc.Tip), "second:" + string(bc.Tip)}
+	if len(received) != len(expected) || received[0] != expected[0] || received[1] != expected[1] {
+		t.Errorf("expected handlers to receive %v, got %v", expected, received)
+	}
+}
@@ -0,0 +1,258 @@
+package blockchain
+
+import (
+	"blockchainStorage/internal/address"
+	"blockchainStorage/internal/transaction"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const (
+	ChannelStatePrefix     = "channel_"
+	ChannelPostStatePrefix = "chpost_"
+	ChannelKeyStatePrefix  = "chkey_"
+
+	MaxChannelTopicLength = 256
+	MaxChannelPostSize    = 64 * 1024
+)
+
+var (
+	ErrChannelExists      = errors.New("channel already exists")
+	ErrChannelNotFound    = errors.New("channel not found")
+	ErrNotChannelOwner    = errors.New("only the channel owner can do this")
+	ErrChannelNotPrivate  = errors.New("channel posts are not encrypted")
+	ErrInvalidChannelPost = errors.New("invalid channel post")
+	ErrChannelKeyNotFound = errors.New("channel key share not found")
+)
+
+// ChannelRecord состояние канала
+type ChannelRecord struct {
+	Name      string
+	Owner     string
+	Topic     string
+	Encrypted bool
+	Open      bool
+	CreatedAt int64
+	// PostCount количество публикаций канала
+	PostCount int64
+	// LastPostHeight индекс последнего блока с публикациями канала, 0 - публикаций нет
+	LastPostHeight int64
+}
+
+// channelPostIndex публикации канала в одном блоке. Записи индекса связаны
+// в список от новых блоков к старым через PrevHeight.
+type channelPostIndex struct {
+	TxIDs      []string
+	PrevHeight int64
+}
+
+// ChannelPostEntry публикация канала вместе с индексом блока, в который она вошла
+type ChannelPostEntry struct {
+	Height      int64
+	Transaction *transaction.Transaction
+}
+
+// LookupChannel возвращает состояние канала
+func (bc *Blockchain) LookupChannel(name string) (*ChannelRecord, error) {
+	return getChannel(newStateBatch(bc.db), name)
+}
+
+// ChannelKeyShare возвращает ключ закрытого канала, зашифрованный для подписчика
+func (bc *Blockchain) ChannelKeyShare(name string, subscriber string) (*transaction.GroupKeyShare, error) {
+	var share transaction.GroupKeyShare
+	exists, err := newStateBatch(bc.db).getValue(ChannelKeyStatePrefix+name+"_"+subscriber, &share)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, fmt.Errorf("%w: channel %s, subscriber %s", ErrChannelKeyNotFound, name, subscriber)
+	}
+
+	return &share, nil
+}
+
+// ChannelPosts возвращает до limit последних публикаций канала из блоков с
+// индексом не меньше fromHeight, в порядке от старых к новым
+func (bc *Blockchain) ChannelPosts(name string, fromHeight int64, limit int) ([]ChannelPostEntry, error) {
+	state := newStateBatch(bc.db)
+	record, err := getChannel(state, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var posts []ChannelPostEntry
+	for height := record.LastPostHeight; height > 0 && height >= fromHeight && len(posts) < limit; {
+		var index channelPostIndex
+		_, err = state.getValue(channelPostStateKey(name, height), &index)
+		if err != nil {
+			return nil, err
+		}
+
+		block, err := bc.BlockAtHeight(height)
+		if err != nil {
+			return nil, err
+		}
+
+		// Публикации блока добавляются в обратном порядке и разворачиваются в конце
+		for i := len(index.TxIDs) - 1; i >= 0 && len(posts) < limit; i-- {
+			tx := block.transaction(index.TxIDs[i])
+			if tx == nil {
+				return nil, fmt.Errorf("channel post %s not found in block %d", index.TxIDs[i], height)
+			}
+
+			posts = append(posts, ChannelPostEntry{Height: height, Transaction: tx})
+		}
+
+		height = index.PrevHeight
+	}
+
+	for i, j := 0, len(posts)-1; i < j; i, j = i+1, j-1 {
+		posts[i], posts[j] = posts[j], posts[i]
+	}
+
+	return posts, nil
+}
+
+func getChannel(state *stateBatch, name string) (*ChannelRecord, error) {
+	var record ChannelRecord
+	exists, err := state.getValue(ChannelStatePrefix+name, &record)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrChannelNotFound, name)
+	}
+
+	return &record, nil
+}
+
+func channelPostStateKey(name string, height int64) string {
+	return ChannelPostStatePrefix + name + "_" + strconv.FormatInt(height, 10)
+}
+
+// applyChannelAction создает канал, изменяет его тему или сохраняет ключи подписчиков
+func applyChannelAction(state *stateBatch, tx *transaction.Transaction, height int64) error {
+	action, err := tx.ChannelAction()
+	if err != nil {
+		return err
+	}
+
+	if len(action.Topic) > MaxChannelTopicLength {
+		return fmt.Errorf("channel topic is longer than %d bytes", MaxChannelTopicLength)
+	}
+
+	if tx.Type == transaction.TypeCreateChannel {
+		err = transaction.ValidateName(action.Channel)
+		if err != nil {
+			return err
+		}
+
+		_, err = getChannel(state, action.Channel)
+		if err == nil {
+			return fmt.Errorf("%w: %s", ErrChannelExists, action.Channel)
+		}
+		if !errors.Is(err, ErrChannelNotFound) {
+			return err
+		}
+
+		return state.putValue(ChannelStatePrefix+action.Channel, &ChannelRecord{
+			Name:      action.Channel,
+			Owner:     tx.Sender,
+			Topic:     action.Topic,
+			Encrypted: action.Encrypted,
+			Open:      action.Open,
+			CreatedAt: height,
+		})
+	}
+
+	record, err := getChannel(state, action.Channel)
+	if err != nil {
+		return err
+	}
+
+	owned, err := ownedBy(state, record.Owner, tx.Sender)
+	if err != nil {
+		return err
+	}
+
+	if !owned {
+		return ErrNotChannelOwner
+	}
+
+	if tx.Type == transaction.TypeSetChannelTopic {
+		record.Topic = action.Topic
+		return state.putValue(ChannelStatePrefix+record.Name, record)
+	}
+
+	if !record.Encrypted {
+		return fmt.Errorf("%w: %s", ErrChannelNotPrivate, record.Name)
+	}
+
+	for _, share := range action.KeyShares {
+		err = address.Validate(share.Member)
+		if err != nil {
+			return fmt.Errorf("invalid subscriber address %s: %w", share.Member, err)
+		}
+
+		err = state.putValue(ChannelKeyStatePrefix+record.Name+"_"+share.Member, share)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyChannelPost проверяет публикацию и добавляет ее в индекс публикаций канала
+func applyChannelPost(state *stateBatch, tx *transaction.Transaction, height int64) error {
+	post, err := tx.ChannelPost()
+	if err != nil {
+		return err
+	}
+
+	record, err := getChannel(state, post.Channel)
+	if err != nil {
+		return err
+	}
+
+	if !record.Open {
+		owned, err := ownedBy(state, record.Owner, tx.Sender)
+		if err != nil {
+			return err
+		}
+
+		if !owned {
+			return ErrNotChannelOwner
+		}
+	}
+
+	if post.Encrypted() != record.Encrypted || (post.Encrypted() && post.Content != nil) {
+		return fmt.Errorf("%w: encryption does not match channel %s", ErrInvalidChannelPost, record.Name)
+	}
+
+	if len(post.Content)+len(post.Ciphertext) > MaxChannelPostSize {
+		return fmt.Errorf("%w: post is larger than %d bytes", ErrInvalidChannelPost, MaxChannelPostSize)
+	}
+
+	var index channelPostIndex
+	exists, err := state.getValue(channelPostStateKey(record.Name, height), &index)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		index.PrevHeight = record.LastPostHeight
+	}
+
+	index.TxIDs = append(index.TxIDs, tx.ID)
+	err = state.putValue(channelPostStateKey(record.Name, height), index)
+	if err != nil {
+		return err
+	}
+
+	record.PostCount++
+	record.LastPostHeight = height
+	return state.putValue(ChannelStatePrefix+record.Name, record)
+}
@@ -0,0 +1,190 @@
+package blockchain
+
+import (
+	"blockchainStorage/internal/transaction"
+	"errors"
+	"testing"
+)
+
+func TestPublicChannel(t *testing.T) {
+	dbStorage := NewMockDbStorage()
+	bc, err := NewBlockchain(1, dbStorage)
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+
+	pool := &testPool{}
+	bc.SetTxPool(pool)
+	owner, reader := newTestKey(t), newTestKey(t)
+
+	create, err := transaction.NewChannelCreateTransaction("news", "daily news", false, false)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+	mineTransactions(t, bc, pool, signTx(t, create, owner, 1))
+
+	// Две публикации в блоке 2 и одна в блоке 4
+	first, _ := transaction.NewChannelPostTransaction("news", []byte("first"))
+	second, _ := transaction.NewChannelPostTransaction("news", []byte("second"))
+	mineTransactions(t, bc, pool, signTx(t, first, owner, 2), signTx(t, second, owner, 3))
+	mineTransactions(t, bc, pool)
+
+	third, _ := transaction.NewChannelPostTransaction("news", []byte("third"))
+	topic, _ := transaction.NewChannelTopicTransaction("news", "breaking news")
+	mineTransactions(t, bc, pool, signTx(t, third, owner, 4), signTx(t, topic, owner, 5))
+
+	record, err := bc.LookupChannel("news")
+	if err != nil {
+		t.Fatalf("failed to lookup channel: %v", err)
+	}
+	if record.Topic != "breaking news" || record.PostCount != 3 || record.LastPostHeight != 4 {
+		t.Errorf("unexpected channel record: %+v", record)
+	}
+
+	posts, err := bc.ChannelPosts("news", 0, 10)
+	if err != nil {
+		t.Fatalf("failed to get channel posts: %v", err)
+	}
+
+	expected := []string{"first", "second", "third"}
+	if len(posts) != len(expected) {
+		t.Fatalf("expected %d posts, got %d", len(expected), len(posts))
+	}
+	for i, entry := range posts {
+		post, err := entry.Transaction.ChannelPost()
+		if err != nil {
+			t.Fatalf("failed to parse post: %v", err)
+		}
+
+		content, err := post.Read(nil)
+		if err != nil || string(content) != expected[i] {
+			t.Errorf("post %d: got %q, %v, expected %s", i, content, err, expected[i])
+		}
+	}
+	if posts[0].Height != 2 || posts[2].Height != 4 {
+		t.Errorf("unexpected post heights: %d, %d", posts[0].Height, posts[2].Height)
+	}
+
+	// Выборка по высоте и ограничение количества
+	posts, err = bc.ChannelPosts("news", 3, 10)
+	if err != nil || len(posts) != 1 {
+		t.Errorf("expected 1 post since height 3, got %d, %v", len(posts), err)
+	}
+	posts, err = bc.ChannelPosts("news", 0, 2)
+	if err != nil || len(posts) != 2 || posts[1].Transaction.ID != third.ID {
+		t.Errorf("expected 2 latest posts, got %d, %v", len(posts), err)
+	}
+
+	// В закрытый для записи канал публикует только владелец
+	foreign, _ := transaction.NewChannelPostTransaction("news", []byte("spam"))
+	err = bc.ValidateTransaction(signTx(t, foreign, reader, 1))
+	if !errors.Is(err, ErrNotChannelOwner) {
+		t.Errorf("expected ErrNotChannelOwner, got %v", err)
+	}
+
+	duplicate, _ := transaction.NewChannelCreateTransaction("news", "", false, true)
+	err = bc.ValidateTransaction(signTx(t, duplicate, reader, 1))
+	if !errors.Is(err, ErrChannelExists) {
+		t.Errorf("expected ErrChannelExists, got %v", err)
+	}
+}
+
+func TestEncryptedChannel(t *testing.T) {
+	dbStorage := NewMockDbStorage()
+	bc, err := NewBlockchain(1, dbStorage)
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+
+	pool := &testPool{}
+	bc.SetTxPool(pool)
+	owner, subscriber := newTestKey(t), newTestKey(t)
+	addresses := registerIdentities(t, bc, pool, owner, subscriber)
+
+	channelKey, err := transaction.NewGroupKey()
+	if err != nil {
+		t.Fatalf("failed to create channel key: %v", err)
+	}
+
+	create, _ := transaction.NewChannelCreateTransaction("insiders", "", true, false)
+	share, err := transaction.NewChannelKeyTransaction("insiders", channelKey, []string{addresses[1]}, bc)
+	if err != nil {
+		t.Fatalf("failed to share channel key: %v", err)
+	}
+	mineTransactions(t, bc, pool, signTx(t, create, owner, 2), signTx(t, share, owner, 3))
+
+	// Открытая публикация в закрытом канале отклоняется
+	plain, _ := transaction.NewChannelPostTransaction("insiders", []byte("leak"))
+	err = bc.ValidateTransaction(signTx(t, plain, owner, 4))
+	if !errors.Is(err, ErrInvalidChannelPost) {
+		t.Errorf("expected ErrInvalidChannelPost, got %v", err)
+	}
+
+	post, err := transaction.NewEncryptedChannelPostTransaction("insiders", channelKey, []byte("secret"))
+	if err != nil {
+		t.Fatalf("failed to create post: %v", err)
+	}
+	mineTransactions(t, bc, pool, signTx(t, post, owner, 4))
+
+	keyShare, err := bc.ChannelKeyShare("insiders", addresses[1])
+	if err != nil {
+		t.Fatalf("failed to get channel key: %v", err)
+	}
+	key, err := transaction.DecryptGroupKey(*keyShare, subscriber)
+	if err != nil {
+		t.Fatalf("failed to decrypt channel key: %v", err)
+	}
+
+	posts, err := bc.ChannelPosts("insiders", 0, 10)
+	if err != nil || len(posts) != 1 {
+		t.Fatalf("expected 1 post, got %d, %v", len(posts), err)
+	}
+
+	payload, err := posts[0].Transaction.ChannelPost()
+	if err != nil {
+		t.Fatalf("failed to parse post: %v", err)
+	}
+
+	_, err = payload.Read(nil)
+	if !errors.Is(err, transaction.ErrChannelKeyRequired) {
+		t.Errorf("expected ErrChannelKeyRequired, got %v", err)
+	}
+
+	content, err := payload.Read(key)
+	if err != nil || string(content) != "secret" {
+		t.Errorf("failed to read encrypted post: %q, %v", content, err)
+	}
+}
+
+func TestChannelSurvivesKeyRotation(t *testing.T) {
+	dbStorage := NewMockDbStorage()
+	bc, err := NewBlockchain(1, dbStorage)
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+
+	pool := &testPool{}
+	bc.SetTxPool(pool)
+	owner := newTestKey(t)
+	ownerAddress := registerIdentities(t, bc, pool, owner)[0]
+
+	create, err := transaction.NewChannelCreateTransaction("news", "daily news", false, false)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+	mineTransactions(t, bc, pool, signTx(t, create, owner, 2))
+
+	// Новый ключ владельца публикует в канал и меняет тему
+	newOwner, _ := rotateSigningKey(t, bc, pool, owner, ownerAddress, 3)
+	post, _ := transaction.NewChannelPostTransaction("news", []byte("after rotation"))
+	topic, _ := transaction.NewChannelTopicTransaction("news", "rotated")
+	mineTransactions(t, bc, pool, signTx(t, post, newOwner, 1), signTx(t, topic, newOwner, 2))
+
+	record, err := bc.LookupChannel("news")
+	if err != nil {
+		t.Fatalf("failed to lookup channel: %v", err)
+	}
+	if record.Topic != "rotated" || record.PostCount != 1 {
+		t.Errorf("expected post and topic from rotated owner, got %+v", record)
+	}
+}
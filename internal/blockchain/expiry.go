@@ -0,0 +1,79 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const ExpiryStatePrefix = "expire_"
+
+var ErrMessageExpired = errors.New("message expires before it can be confirmed")
+
+// expiryStateKey индекс сообщений, истекающих в блоке height
+func expiryStateKey(height int64) string {
+	return ExpiryStatePrefix + strconv.FormatInt(height, 10)
+}
+
+// addExpiry добавляет сообщение в индекс сообщений, истекающих в блоке expiresAt
+func addExpiry(state *stateBatch, txID string, expiresAt int64) error {
+	var ids []string
+	_, err := state.getValue(expiryStateKey(expiresAt), &ids)
+	if err != nil {
+		return err
+	}
+
+	return state.putValue(expiryStateKey(expiresAt), append(ids, txID))
+}
+
+// pruneExpired удаляет из хранилища содержимое сообщений, срок которых истек к
+// блоку height. Блоки перезаписываются с тем же хэшем, заголовки сообщений и
+// индексы состояния остаются, поэтому цепочка и беседы продолжают проверяться.
+func (bc *Blockchain) pruneExpired(height int64) error {
+	state := newStateBatch(bc.db)
+
+	var ids []string
+	_, err := state.getValue(expiryStateKey(height), &ids)
+	if err != nil {
+		return err
+	}
+
+	// Сообщения одного блока очищаются одной перезаписью блока
+	blocks := make(map[int64]*Block)
+	var order []int64
+	for _, id := range ids {
+		record, err := getMessage(state, id)
+		if err != nil {
+			return err
+		}
+
+		block, loaded := blocks[record.Height]
+		if !loaded {
+			block, err = bc.BlockAtHeight(record.Height)
+			if err != nil {
+				return err
+			}
+			blocks[record.Height] = block
+			order = append(order, record.Height)
+		}
+
+		tx := block.transaction(id)
+		if tx == nil {
+			return fmt.Errorf("message %s not found in block %d", id, record.Height)
+		}
+
+		err = tx.Prune()
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, blockHeight := range order {
+		err = bc.db.PruneBlockInDB(blocks[blockHeight])
+		if err != nil {
+			return fmt.Errorf("failed to prune block %d: %w", blockHeight, err)
+		}
+	}
+
+	return nil
+}
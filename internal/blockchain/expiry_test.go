@@ -0,0 +1,67 @@
+package blockchain
+
+import (
+	"blockchainStorage/internal/transaction"
+	"errors"
+	"testing"
+)
+
+func TestExpiredMessagesArePruned(t *testing.T) {
+	dbStorage := NewMockDbStorage()
+	bc, err := NewBlockchain(1, dbStorage)
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+
+	pool := &testPool{}
+	bc.SetTxPool(pool)
+	alice := newTestKey(t)
+
+	// Сообщение не может истечь раньше, чем попадет в блок
+	stale := newThreadMessage(t, alice, "stale", "too late", transaction.MessageMeta{ExpiresAt: 1}, 1)
+	err = bc.ValidateTransaction(stale)
+	if !errors.Is(err, ErrMessageExpired) {
+		t.Errorf("expected ErrMessageExpired, got %v", err)
+	}
+
+	secret := newThreadMessage(t, alice, "secret", "disappearing", transaction.MessageMeta{ExpiresAt: 3}, 1)
+	mineTransactions(t, bc, pool, secret)
+	mineTransactions(t, bc, pool)
+
+	view, err := bc.ResolveMessage("secret")
+	if err != nil {
+		t.Fatalf("failed to resolve message: %v", err)
+	}
+	if view.Expired || string(view.Latest.Outputs[0].EncryptedData) != "disappearing" {
+		t.Errorf("expected message to be readable before expiry, got %+v", view)
+	}
+
+	// В блоке 3 содержимое сообщения удаляется из хранилища
+	mineTransactions(t, bc, pool)
+
+	view, err = bc.ResolveMessage("secret")
+	if err != nil {
+		t.Fatalf("failed to resolve message: %v", err)
+	}
+	if !view.Expired || view.Latest.Outputs[0].EncryptedData != nil {
+		t.Errorf("expected message content to be pruned, got %+v", view.Latest)
+	}
+	if view.Latest.Outputs[0].Recipient != testMinerAddress {
+		t.Errorf("expected message header to be kept, got recipient %q", view.Latest.Outputs[0].Recipient)
+	}
+
+	// Очищенный блок сохраняет хэш доказательства работы
+	block, err := bc.BlockAtHeight(view.Height)
+	if err != nil {
+		t.Fatalf("failed to get block: %v", err)
+	}
+	if hash := NewProofOfWork(block, block.Difficulty).calculateHash(block.Nonce); hash != block.Hash {
+		t.Errorf("expected pruned block hash %s, got %s", block.Hash, hash)
+	}
+
+	// Очищенную транзакцию нельзя подтвердить повторно
+	err = bc.ValidateTransaction(view.Latest)
+	if !errors.Is(err, ErrPrunedTransaction) {
+		t.Errorf("expected ErrPrunedTransaction, got %v", err)
+	}
+}
@@ -0,0 +1,468 @@
+package blockchain
+
+import (
+	"blockchainStorage/internal/address"
+	"blockchainStorage/internal/transaction"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+const (
+	GroupStatePrefix       = "group_"
+	GroupMemberStatePrefix = "gmember_"
+	GroupKeyStatePrefix    = "gkey_"
+
+	maxGroupNameLength = 64
+)
+
+var (
+	ErrGroupExists         = errors.New("group already exists")
+	ErrGroupNotFound       = errors.New("group not found")
+	ErrInvalidGroupID      = errors.New("invalid group id")
+	ErrNotGroupOwner       = errors.New("only the group owner can change membership")
+	ErrNotGroupMember      = errors.New("sender is not a group member")
+	ErrAlreadyGroupMember  = errors.New("address is already a group member")
+	ErrOwnerCannotLeave    = errors.New("group owner cannot leave or be removed")
+	ErrGroupEpochMismatch  = errors.New("unexpected group key epoch")
+	ErrGroupRekeyRequired  = errors.New("group key must be rotated after a member left")
+	ErrGroupKeySharesMatch = errors.New("group key shares do not match members")
+	ErrGroupKeyNotFound    = errors.New("group key share not found")
+
+	groupIDPattern = regexp.MustCompile(`^[A-Za-z0-9-]{1,64}$`)
+)
+
+// GroupRecord состояние группы: участники и текущая эпоха ключа группы
+type GroupRecord struct {
+	ID      string
+	Name    string
+	Owner   string
+	Members []string
+	// Epoch номер текущего ключа группы, увеличивается при каждой смене ключа
+	Epoch uint64
+	// RekeyRequired участник вышел из группы, сообщения запрещены до смены ключа
+	RekeyRequired bool
+	CreatedAt     int64
+	UpdatedAt     int64
+}
+
+// IsMember проверяет, состоит ли адрес в группе
+func (record *GroupRecord) IsMember(member string) bool {
+	return indexOf(record.Members, member) >= 0
+}
+
+// LookupGroup возвращает состояние группы
+func (bc *Blockchain) LookupGroup(groupID string) (*GroupRecord, error) {
+	return getGroup(newStateBatch(bc.db), groupID)
+}
+
+// GroupsOf возвращает идентификаторы групп, в которых состоит адрес
+func (bc *Blockchain) GroupsOf(member string) ([]string, error) {
+	var groups []string
+	_, err := newStateBatch(bc.db).getValue(GroupMemberStatePrefix+member, &groups)
+	if err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// GroupKeyShare возвращает ключ группы эпохи epoch, зашифрованный для участника member
+func (bc *Blockchain) GroupKeyShare(groupID string, epoch uint64, member string) (*transaction.GroupKeyShare, error) {
+	var share transaction.GroupKeyShare
+	exists, err := newStateBatch(bc.db).getValue(groupKeyStateKey(groupID, epoch, member), &share)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, fmt.Errorf("%w: group %s, epoch %d, member %s", ErrGroupKeyNotFound, groupID, epoch, member)
+	}
+
+	return &share, nil
+}
+
+// groupMember возвращает сохраненный адрес участника, права которого принадлежат
+// отправителю sender, или пустую строку, если sender не состоит в группе
+func groupMember(state *stateBatch, record *GroupRecord, sender string) (string, error) {
+	for _, member := range record.Members {
+		owned, err := ownedBy(state, member, sender)
+		if err != nil {
+			return "", err
+		}
+
+		if owned {
+			return member, nil
+		}
+	}
+
+	return "", nil
+}
+
+func getGroup(state *stateBatch, groupID string) (*GroupRecord, error) {
+	var record GroupRecord
+	exists, err := state.getValue(GroupStatePrefix+groupID, &record)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrGroupNotFound, groupID)
+	}
+
+	return &record, nil
+}
+
+func groupKeyStateKey(groupID string, epoch uint64, member string) string {
+	return GroupKeyStatePrefix + groupID + "_" + strconv.FormatUint(epoch, 10) + "_" + member
+}
+
+// applyGroupAction применяет изменение состава или ключа группы
+func applyGroupAction(state *stateBatch, tx *transaction.Transaction, height int64) error {
+	action, err := tx.GroupAction()
+	if err != nil {
+		return err
+	}
+
+	if !groupIDPattern.MatchString(action.GroupID) {
+		return fmt.Errorf("%w: %q", ErrInvalidGroupID, action.GroupID)
+	}
+
+	if tx.Type == transaction.TypeCreateGroup {
+		return createGroup(state, tx.Sender, action, height)
+	}
+
+	record, err := getGroup(state, action.GroupID)
+	if err != nil {
+		return err
+	}
+
+	switch tx.Type {
+	case transaction.TypeInviteMember:
+		err = inviteMembers(state, tx.Sender, record, action)
+	case transaction.TypeRemoveMember:
+		err = removeMembers(state, tx.Sender, record, action)
+	case transaction.TypeLeaveGroup:
+		err = leaveGroup(state, tx.Sender, record)
+	case transaction.TypeRekeyGroup:
+		err = rekeyGroup(state, tx.Sender, record, action)
+	}
+	if err != nil {
+		return err
+	}
+
+	record.UpdatedAt = height
+	return state.putValue(GroupStatePrefix+record.ID, record)
+}
+
+func createGroup(state *stateBatch, sender string, action *transaction.GroupAction, height int64) error {
+	_, err := getGroup(state, action.GroupID)
+	if err == nil {
+		return fmt.Errorf("%w: %s", ErrGroupExists, action.GroupID)
+	}
+	if !errors.Is(err, ErrGroupNotFound) {
+		return err
+	}
+
+	if len(action.Name) > maxGroupNameLength {
+		return fmt.Errorf("group name is longer than %d bytes", maxGroupNameLength)
+	}
+
+	if indexOf(action.Members, sender) < 0 {
+		return fmt.Errorf("%w: creator must be listed as a member", ErrNotGroupMember)
+	}
+
+	err = checkNewMembers(state, nil, action.Members)
+	if err != nil {
+		return err
+	}
+
+	if action.Epoch != 1 {
+		return fmt.Errorf("%w: expected 1, got %d", ErrGroupEpochMismatch, action.Epoch)
+	}
+
+	record := &GroupRecord{
+		ID:        action.GroupID,
+		Name:      action.Name,
+		Owner:     sender,
+		Epoch:     1,
+		CreatedAt: height,
+		UpdatedAt: height,
+	}
+
+	err = putKeyShares(state, record.ID, record.Epoch, action.KeyShares, action.Members)
+	if err != nil {
+		return err
+	}
+
+	err = addGroupMembers(state, record, action.Members)
+	if err != nil {
+		return err
+	}
+
+	return state.putValue(GroupStatePrefix+record.ID, record)
+}
+
+// inviteMembers добавляет участников и сохраняет для них текущий ключ группы
+func inviteMembers(state *stateBatch, sender string, record *GroupRecord, action *transaction.GroupAction) error {
+	owned, err := ownedBy(state, record.Owner, sender)
+	if err != nil {
+		return err
+	}
+
+	if !owned {
+		return ErrNotGroupOwner
+	}
+
+	if record.RekeyRequired {
+		return ErrGroupRekeyRequired
+	}
+
+	if action.Epoch != record.Epoch {
+		return fmt.Errorf("%w: expected %d, got %d", ErrGroupEpochMismatch, record.Epoch, action.Epoch)
+	}
+
+	err = checkNewMembers(state, record, action.Members)
+	if err != nil {
+		return err
+	}
+
+	err = putKeyShares(state, record.ID, record.Epoch, action.KeyShares, action.Members)
+	if err != nil {
+		return err
+	}
+
+	return addGroupMembers(state, record, action.Members)
+}
+
+// removeMembers исключает участников и переводит группу на новый ключ, который
+// получают только оставшиеся участники
+func removeMembers(state *stateBatch, sender string, record *GroupRecord, action *transaction.GroupAction) error {
+	owned, err := ownedBy(state, record.Owner, sender)
+	if err != nil {
+		return err
+	}
+
+	if !owned {
+		return ErrNotGroupOwner
+	}
+
+	if len(action.Members) == 0 {
+		return errors.New("no members to remove")
+	}
+
+	for _, member := range action.Members {
+		if member == record.Owner {
+			return ErrOwnerCannotLeave
+		}
+		if !record.IsMember(member) {
+			return fmt.Errorf("%s is not a member of group %s", member, record.ID)
+		}
+	}
+
+	for _, member := range action.Members {
+		err := removeGroupMember(state, record, member)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rotateGroupKey(state, record, action)
+}
+
+// leaveGroup исключает отправителя. Вышедший участник знает текущий ключ,
+// поэтому сообщения группы запрещены, пока кто-то из участников не сменит ключ.
+func leaveGroup(state *stateBatch, sender string, record *GroupRecord) error {
+	member, err := groupMember(state, record, sender)
+	if err != nil {
+		return err
+	}
+
+	if member == "" {
+		return ErrNotGroupMember
+	}
+
+	if member == record.Owner {
+		return ErrOwnerCannotLeave
+	}
+
+	record.RekeyRequired = true
+	return removeGroupMember(state, record, member)
+}
+
+func rekeyGroup(state *stateBatch, sender string, record *GroupRecord, action *transaction.GroupAction) error {
+	member, err := groupMember(state, record, sender)
+	if err != nil {
+		return err
+	}
+
+	if member == "" {
+		return ErrNotGroupMember
+	}
+
+	return rotateGroupKey(state, record, action)
+}
+
+// rotateGroupKey переводит группу на следующую эпоху ключа, выданного всем текущим участникам
+func rotateGroupKey(state *stateBatch, record *GroupRecord, action *transaction.GroupAction) error {
+	if action.Epoch != record.Epoch+1 {
+		return fmt.Errorf("%w: expected %d, got %d", ErrGroupEpochMismatch, record.Epoch+1, action.Epoch)
+	}
+
+	err := putKeyShares(state, record.ID, action.Epoch, action.KeyShares, record.Members)
+	if err != nil {
+		return err
+	}
+
+	record.Epoch = action.Epoch
+	record.RekeyRequired = false
+	return nil
+}
+
+// checkGroupMessage проверяет, что отправитель состоит в группе и использует текущий ключ
+func checkGroupMessage(state *stateBatch, tx *transaction.Transaction) error {
+	message, err := tx.GroupMessage()
+	if err != nil {
+		return err
+	}
+
+	record, err := getGroup(state, message.GroupID)
+	if err != nil {
+		return err
+	}
+
+	member, err := groupMember(state, record, tx.Sender)
+	if err != nil {
+		return err
+	}
+
+	if member == "" {
+		return ErrNotGroupMember
+	}
+
+	if record.RekeyRequired {
+		return ErrGroupRekeyRequired
+	}
+
+	if message.Epoch != record.Epoch {
+		return fmt.Errorf("%w: expected %d, got %d", ErrGroupEpochMismatch, record.Epoch, message.Epoch)
+	}
+
+	return nil
+}
+
+// checkNewMembers проверяет адреса добавляемых участников. Участник должен
+// опубликовать действующие ключи, иначе ему нельзя выдать ключ группы.
+func checkNewMembers(state *stateBatch, record *GroupRecord, members []string) error {
+	if len(members) == 0 {
+		return errors.New("no members to add")
+	}
+
+	seen := make(map[string]bool, len(members))
+	for _, member := range members {
+		err := address.Validate(member)
+		if err != nil {
+			return fmt.Errorf("invalid member address %s: %w", member, err)
+		}
+
+		if seen[member] || (record != nil && record.IsMember(member)) {
+			return fmt.Errorf("%w: %s", ErrAlreadyGroupMember, member)
+		}
+		seen[member] = true
+
+		keys, err := getKeyRecord(state, member)
+		if err != nil {
+			return err
+		}
+		if keys.Retired() {
+			return fmt.Errorf("%w: %s", ErrKeyRetired, member)
+		}
+	}
+
+	return nil
+}
+
+// putKeyShares сохраняет ключ эпохи epoch, проверяя, что он выдан ровно участникам members
+func putKeyShares(state *stateBatch, groupID string, epoch uint64, shares []transaction.GroupKeyShare, members []string) error {
+	if len(shares) != len(members) {
+		return fmt.Errorf("%w: %d shares for %d members", ErrGroupKeySharesMatch, len(shares), len(members))
+	}
+
+	seen := make(map[string]bool, len(shares))
+	for _, share := range shares {
+		if seen[share.Member] || indexOf(members, share.Member) < 0 {
+			return fmt.Errorf("%w: unexpected share for %s", ErrGroupKeySharesMatch, share.Member)
+		}
+		seen[share.Member] = true
+
+		err := state.putValue(groupKeyStateKey(groupID, epoch, share.Member), share)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addGroupMembers добавляет участников в группу и в индекс групп участника
+func addGroupMembers(state *stateBatch, record *GroupRecord, members []string) error {
+	for _, member := range members {
+		var groups []string
+		_, err := state.getValue(GroupMemberStatePrefix+member, &groups)
+		if err != nil {
+			return err
+		}
+
+		err = state.putValue(GroupMemberStatePrefix+member, append(groups, record.ID))
+		if err != nil {
+			return err
+		}
+	}
+
+	record.Members = append(record.Members, members...)
+	return nil
+}
+
+// removeGroupMember удаляет участника из группы и из индекса групп участника
+func removeGroupMember(state *stateBatch, record *GroupRecord, member string) error {
+	var groups []string
+	_, err := state.getValue(GroupMemberStatePrefix+member, &groups)
+	if err != nil {
+		return err
+	}
+
+	groups = removeString(groups, record.ID)
+	if len(groups) == 0 {
+		state.delete(GroupMemberStatePrefix + member)
+	} else {
+		err = state.putValue(GroupMemberStatePrefix+member, groups)
+		if err != nil {
+			return err
+		}
+	}
+
+	record.Members = removeString(record.Members, member)
+	return nil
+}
+
+func indexOf(values []string, value string) int {
+	for i := range values {
+		if values[i] == value {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func removeString(values []string, value string) []string {
+	i := indexOf(values, value)
+	if i < 0 {
+		return values
+	}
+
+	result := make([]string, 0, len(values)-1)
+	result = append(result, values[:i]...)
+	return append(result, values[i+1:]...)
+}
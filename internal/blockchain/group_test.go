@@ -0,0 +1,305 @@
+package blockchain
+
+import (
+	"blockchainStorage/internal/transaction"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"errors"
+	"testing"
+)
+
+// registerIdentities публикует ключи личности пользователей в одном блоке
+// и возвращает их адреса
+func registerIdentities(t *testing.T, bc *Blockchain, pool *testPool, keys ...*ecdsa.PrivateKey) []string {
+	var txs []*transaction.Transaction
+	var addresses []string
+	for _, key := range keys {
+		tx, err := transaction.NewKeyRegistrationTransaction(transaction.KeyRegistration{
+			SigningKey: elliptic.Marshal(elliptic.P256(), key.PublicKey.X, key.PublicKey.Y),
+		})
+		if err != nil {
+			t.Fatalf("failed to create key registration: %v", err)
+		}
+
+		txs = append(txs, signTx(t, tx, key, 1))
+		addresses = append(addresses, tx.Sender)
+	}
+
+	mineTransactions(t, bc, pool, txs...)
+	return addresses
+}
+
+// readGroupKey расшифровывает ключ группы, опубликованный для участника
+func readGroupKey(t *testing.T, bc *Blockchain, groupID string, epoch uint64, member string, key *ecdsa.PrivateKey) []byte {
+	share, err := bc.GroupKeyShare(groupID, epoch, member)
+	if err != nil {
+		t.Fatalf("failed to get group key share: %v", err)
+	}
+
+	groupKey, err := transaction.DecryptGroupKey(*share, key)
+	if err != nil {
+		t.Fatalf("failed to decrypt group key: %v", err)
+	}
+
+	return groupKey
+}
+
+func TestGroupMembership(t *testing.T) {
+	dbStorage := NewMockDbStorage()
+	bc, err := NewBlockchain(1, dbStorage)
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+
+	pool := &testPool{}
+	bc.SetTxPool(pool)
+
+	alice, bob, carol := newTestKey(t), newTestKey(t), newTestKey(t)
+	addresses := registerIdentities(t, bc, pool, alice, bob, carol)
+	aliceAddress, bobAddress, carolAddress := addresses[0], addresses[1], addresses[2]
+
+	// Алиса создает группу с Бобом
+	groupKey, err := transaction.NewGroupKey()
+	if err != nil {
+		t.Fatalf("failed to create group key: %v", err)
+	}
+
+	create, err := transaction.NewGroupCreateTransaction("team", []string{aliceAddress, bobAddress}, groupKey, bc)
+	if err != nil {
+		t.Fatalf("failed to create group: %v", err)
+	}
+	mineTransactions(t, bc, pool, signTx(t, create, alice, 2))
+
+	action, err := create.GroupAction()
+	if err != nil {
+		t.Fatalf("failed to parse group action: %v", err)
+	}
+	groupID := action.GroupID
+
+	record, err := bc.LookupGroup(groupID)
+	if err != nil {
+		t.Fatalf("failed to lookup group: %v", err)
+	}
+	if record.Owner != aliceAddress || len(record.Members) != 2 || record.Epoch != 1 {
+		t.Errorf("unexpected group record: %+v", record)
+	}
+
+	// Боб получает ключ группы из состояния и читает сообщение Алисы
+	bobKey := readGroupKey(t, bc, groupID, 1, bobAddress, bob)
+	message, err := transaction.NewGroupMessageTransaction(groupID, 1, groupKey, []byte("hello team"))
+	if err != nil {
+		t.Fatalf("failed to create group message: %v", err)
+	}
+	mineTransactions(t, bc, pool, signTx(t, message, alice, 3))
+
+	payload, err := message.GroupMessage()
+	if err != nil {
+		t.Fatalf("failed to parse group message: %v", err)
+	}
+	plaintext, err := payload.Decrypt(bobKey)
+	if err != nil || string(plaintext) != "hello team" {
+		t.Errorf("failed to read group message: %q, %v", plaintext, err)
+	}
+
+	// Сообщение от не участника отклоняется
+	outsider, err := transaction.NewGroupMessageTransaction(groupID, 1, groupKey, []byte("spam"))
+	if err != nil {
+		t.Fatalf("failed to create group message: %v", err)
+	}
+	err = bc.ValidateTransaction(signTx(t, outsider, carol, 2))
+	if !errors.Is(err, ErrNotGroupMember) {
+		t.Errorf("expected ErrNotGroupMember, got %v", err)
+	}
+
+	// Только владелец приглашает участников
+	invite, err := transaction.NewGroupInviteTransaction(groupID, 1, []string{carolAddress}, groupKey, bc)
+	if err != nil {
+		t.Fatalf("failed to create invite: %v", err)
+	}
+	err = bc.ValidateTransaction(signTx(t, invite, bob, 2))
+	if !errors.Is(err, ErrNotGroupOwner) {
+		t.Errorf("expected ErrNotGroupOwner, got %v", err)
+	}
+	mineTransactions(t, bc, pool, signTx(t, invite, alice, 4))
+	readGroupKey(t, bc, groupID, 1, carolAddress, carol)
+
+	// После исключения Боба ключ группы меняется, Боб не получает новый ключ
+	newKey, err := transaction.NewGroupKey()
+	if err != nil {
+		t.Fatalf("failed to create group key: %v", err)
+	}
+	remove, err := transaction.NewGroupRemoveTransaction(groupID, []string{bobAddress}, 2, []string{aliceAddress, carolAddress}, newKey, bc)
+	if err != nil {
+		t.Fatalf("failed to create remove transaction: %v", err)
+	}
+	mineTransactions(t, bc, pool, signTx(t, remove, alice, 5))
+
+	record, err = bc.LookupGroup(groupID)
+	if err != nil {
+		t.Fatalf("failed to lookup group: %v", err)
+	}
+	if record.IsMember(bobAddress) || record.Epoch != 2 {
+		t.Errorf("unexpected group record after removal: %+v", record)
+	}
+
+	_, err = bc.GroupKeyShare(groupID, 2, bobAddress)
+	if !errors.Is(err, ErrGroupKeyNotFound) {
+		t.Errorf("expected ErrGroupKeyNotFound, got %v", err)
+	}
+	if !bytes.Equal(readGroupKey(t, bc, groupID, 2, carolAddress, carol), newKey) {
+		t.Error("carol received a wrong group key")
+	}
+
+	groups, err := bc.GroupsOf(bobAddress)
+	if err != nil || len(groups) != 0 {
+		t.Errorf("expected bob to have no groups, got %v, %v", groups, err)
+	}
+	groups, err = bc.GroupsOf(carolAddress)
+	if err != nil || len(groups) != 1 || groups[0] != groupID {
+		t.Errorf("expected carol to be in group %s, got %v, %v", groupID, groups, err)
+	}
+
+	// Сообщение со старым ключом отклоняется
+	stale, err := transaction.NewGroupMessageTransaction(groupID, 1, groupKey, []byte("old key"))
+	if err != nil {
+		t.Fatalf("failed to create group message: %v", err)
+	}
+	err = bc.ValidateTransaction(signTx(t, stale, alice, 6))
+	if !errors.Is(err, ErrGroupEpochMismatch) {
+		t.Errorf("expected ErrGroupEpochMismatch, got %v", err)
+	}
+
+	// После выхода Кэрол сообщения запрещены до смены ключа
+	leave, err := transaction.NewGroupLeaveTransaction(groupID)
+	if err != nil {
+		t.Fatalf("failed to create leave transaction: %v", err)
+	}
+	mineTransactions(t, bc, pool, signTx(t, leave, carol, 2))
+
+	blocked, err := transaction.NewGroupMessageTransaction(groupID, 2, newKey, []byte("still here?"))
+	if err != nil {
+		t.Fatalf("failed to create group message: %v", err)
+	}
+	err = bc.ValidateTransaction(signTx(t, blocked, alice, 6))
+	if !errors.Is(err, ErrGroupRekeyRequired) {
+		t.Errorf("expected ErrGroupRekeyRequired, got %v", err)
+	}
+
+	rekeyKey, err := transaction.NewGroupKey()
+	if err != nil {
+		t.Fatalf("failed to create group key: %v", err)
+	}
+	rekey, err := transaction.NewGroupRekeyTransaction(groupID, 3, []string{aliceAddress}, rekeyKey, bc)
+	if err != nil {
+		t.Fatalf("failed to create rekey transaction: %v", err)
+	}
+	mineTransactions(t, bc, pool, signTx(t, rekey, alice, 6))
+
+	resumed, err := transaction.NewGroupMessageTransaction(groupID, 3, rekeyKey, []byte("fresh key"))
+	if err != nil {
+		t.Fatalf("failed to create group message: %v", err)
+	}
+	err = bc.ValidateTransaction(signTx(t, resumed, alice, 7))
+	if err != nil {
+		t.Errorf("expected message with new key to be valid: %v", err)
+	}
+}
+
+func TestGroupSurvivesKeyRotation(t *testing.T) {
+	dbStorage := NewMockDbStorage()
+	bc, err := NewBlockchain(1, dbStorage)
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+
+	pool := &testPool{}
+	bc.SetTxPool(pool)
+
+	alice, bob, carol := newTestKey(t), newTestKey(t), newTestKey(t)
+	addresses := registerIdentities(t, bc, pool, alice, bob, carol)
+	aliceAddress, bobAddress, carolAddress := addresses[0], addresses[1], addresses[2]
+
+	groupKey, err := transaction.NewGroupKey()
+	if err != nil {
+		t.Fatalf("failed to create group key: %v", err)
+	}
+	create, err := transaction.NewGroupCreateTransaction("team", []string{aliceAddress, bobAddress}, groupKey, bc)
+	if err != nil {
+		t.Fatalf("failed to create group: %v", err)
+	}
+	mineTransactions(t, bc, pool, signTx(t, create, alice, 2))
+
+	action, err := create.GroupAction()
+	if err != nil {
+		t.Fatalf("failed to parse group action: %v", err)
+	}
+	groupID := action.GroupID
+
+	// Владелец и участник заменяют ключи подписи
+	newAlice, _ := rotateSigningKey(t, bc, pool, alice, aliceAddress, 3)
+	newBob, _ := rotateSigningKey(t, bc, pool, bob, bobAddress, 2)
+
+	// Новый ключ владельца приглашает участников
+	invite, err := transaction.NewGroupInviteTransaction(groupID, 1, []string{carolAddress}, groupKey, bc)
+	if err != nil {
+		t.Fatalf("failed to create invite: %v", err)
+	}
+	mineTransactions(t, bc, pool, signTx(t, invite, newAlice, 1))
+
+	record, err := bc.LookupGroup(groupID)
+	if err != nil {
+		t.Fatalf("failed to lookup group: %v", err)
+	}
+	if !record.IsMember(carolAddress) {
+		t.Fatalf("expected invite from rotated owner to be applied, got %+v", record)
+	}
+
+	// Новый ключ участника пишет в группу
+	message, err := transaction.NewGroupMessageTransaction(groupID, 1, groupKey, []byte("new key"))
+	if err != nil {
+		t.Fatalf("failed to create group message: %v", err)
+	}
+	err = bc.ValidateTransaction(signTx(t, message, newBob, 1))
+	if err != nil {
+		t.Errorf("expected message from rotated member to be valid: %v", err)
+	}
+
+	// Новый ключ владельца исключает участников
+	removeKey, err := transaction.NewGroupKey()
+	if err != nil {
+		t.Fatalf("failed to create group key: %v", err)
+	}
+	remove, err := transaction.NewGroupRemoveTransaction(groupID, []string{carolAddress}, 2, []string{aliceAddress, bobAddress}, removeKey, bc)
+	if err != nil {
+		t.Fatalf("failed to create remove transaction: %v", err)
+	}
+	mineTransactions(t, bc, pool, signTx(t, remove, newAlice, 2))
+
+	record, err = bc.LookupGroup(groupID)
+	if err != nil {
+		t.Fatalf("failed to lookup group: %v", err)
+	}
+	if record.IsMember(carolAddress) || record.Epoch != 2 {
+		t.Fatalf("expected removal from rotated owner to be applied, got %+v", record)
+	}
+
+	// Новый ключ участника меняет ключ группы
+	rekeyKey, err := transaction.NewGroupKey()
+	if err != nil {
+		t.Fatalf("failed to create group key: %v", err)
+	}
+	rekey, err := transaction.NewGroupRekeyTransaction(groupID, 3, []string{aliceAddress, bobAddress}, rekeyKey, bc)
+	if err != nil {
+		t.Fatalf("failed to create rekey transaction: %v", err)
+	}
+	mineTransactions(t, bc, pool, signTx(t, rekey, newBob, 1))
+
+	record, err = bc.LookupGroup(groupID)
+	if err != nil {
+		t.Fatalf("failed to lookup group: %v", err)
+	}
+	if record.Epoch != 3 {
+		t.Errorf("expected rekey from rotated member to be applied, got %+v", record)
+	}
+}
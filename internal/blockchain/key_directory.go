@@ -0,0 +1,371 @@
+package blockchain
+
+import (
+	"blockchainStorage/internal/identity"
+	"blockchainStorage/internal/transaction"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+const (
+	KeyStatePrefix = "key_"
+	// maxRotationDepth ограничивает длину цепочки ротаций при поиске ключей
+	maxRotationDepth = 16
+)
+
+var (
+	ErrKeyNotRegistered = errors.New("no keys registered for recipient")
+	ErrKeyRevoked       = errors.New("recipient keys are revoked")
+	ErrKeyRetired       = errors.New("sender key is revoked or rotated")
+)
+
+// KeyVersion ключи пользователя, действовавшие в интервале блоков [From, Until)
+type KeyVersion struct {
+	EncryptionKey []byte
+	SigningKey    []byte
+	Prekey        []byte
+	From          int64
+	Until         int64
+}
+
+// KeyRecord ключи пользователя, опубликованные в блокчейне
+type KeyRecord struct {
+	Address       string
+	EncryptionKey []byte
+	SigningKey    []byte
+	Prekey        []byte
+	// Height индекс блока, начиная с которого действуют текущие ключи
+	Height int64
+	// RevokedAt индекс блока отзыва ключей, 0 если ключи действуют
+	RevokedAt int64
+	// RotatedTo адрес, на который перенесены ключи при замене ключа подписи
+	RotatedTo string
+	RotatedAt int64
+	// History предыдущие версии ключей
+	History []KeyVersion
+}
+
+// Retired проверяет, выведены ли ключи из использования
+func (record *KeyRecord) Retired() bool {
+	return record.RevokedAt != 0 || record.RotatedTo != ""
+}
+
+// KeysAt возвращает версию ключей, действовавшую в блоке height
+func (record *KeyRecord) KeysAt(height int64) (*KeyVersion, bool) {
+	if height >= record.Height {
+		end := record.RevokedAt
+		if end == 0 {
+			end = record.RotatedAt
+		}
+		if end != 0 && height >= end {
+			return nil, false
+		}
+
+		return &KeyVersion{
+			EncryptionKey: record.EncryptionKey,
+			SigningKey:    record.SigningKey,
+			Prekey:        record.Prekey,
+			From:          record.Height,
+			Until:         end,
+		}, true
+	}
+
+	for i := range record.History {
+		version := record.History[i]
+		if height >= version.From && height < version.Until {
+			return &version, true
+		}
+	}
+
+	return nil, false
+}
+
+// LookupKeys возвращает ключи, опубликованные пользователем с адресом address.
+// Вместо адреса можно указать имя пользователя в виде "@name".
+func (bc *Blockchain) LookupKeys(address string) (*KeyRecord, error) {
+	address, err := bc.ResolveRecipient(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return getKeyRecord(newStateBatch(bc.db), address)
+}
+
+// LookupActiveKeys возвращает действующие ключи получателя, следуя по цепочке
+// ротаций. Отозванные ключи не возвращаются, чтобы отправитель не шифровал ими.
+func (bc *Blockchain) LookupActiveKeys(recipient string) (*KeyRecord, error) {
+	record, err := bc.LookupKeys(recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	state := newStateBatch(bc.db)
+	for depth := 0; record.RotatedTo != ""; depth++ {
+		if depth >= maxRotationDepth {
+			return nil, fmt.Errorf("rotation chain of %s is too long", recipient)
+		}
+
+		record, err = getKeyRecord(state, record.RotatedTo)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if record.RevokedAt != 0 {
+		return nil, fmt.Errorf("%w: %s", ErrKeyRevoked, recipient)
+	}
+
+	return record, nil
+}
+
+// CurrentAddress возвращает адрес, к которому перешли права address после
+// замен ключа подписи
+func (bc *Blockchain) CurrentAddress(address string) (string, error) {
+	return currentAddress(newStateBatch(bc.db), address)
+}
+
+// HasActiveKey проверяет, что пользователь с адресом address опубликовал ключи,
+// которые не отозваны и не заменены
+func (bc *Blockchain) HasActiveKey(address string) (bool, error) {
+	record, exists, err := findKeyRecord(newStateBatch(bc.db), address)
+	if err != nil {
+		return false, err
+	}
+
+	return exists && !record.Retired(), nil
+}
+
+// ResolveEncryptionKey находит действующий ключ шифрования получателя в
+// состоянии блокчейна, реализует transaction.KeyResolver. Получателям без
+// устаревшего RSA ключа сообщения шифруются их ключом подписи.
+func (bc *Blockchain) ResolveEncryptionKey(recipient string) (crypto.PublicKey, error) {
+	record, err := bc.LookupActiveKeys(recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	if record.EncryptionKey == nil {
+		return identity.ParsePublicKey(record.SigningKey)
+	}
+
+	publicKey, err := x509.ParsePKCS1PublicKey(record.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+
+	return publicKey, nil
+}
+
+// ResolvePrekey находит X25519 ключ получателя для установления сессии
+func (bc *Blockchain) ResolvePrekey(recipient string) (*ecdh.PublicKey, error) {
+	record, err := bc.LookupActiveKeys(recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	if record.Prekey == nil {
+		return nil, fmt.Errorf("%w: %s has no prekey", ErrKeyNotRegistered, recipient)
+	}
+
+	return ecdh.X25519().NewPublicKey(record.Prekey)
+}
+
+func getKeyRecord(state *stateBatch, address string) (*KeyRecord, error) {
+	record, exists, err := findKeyRecord(state, address)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotRegistered, address)
+	}
+
+	return record, nil
+}
+
+func findKeyRecord(state *stateBatch, address string) (*KeyRecord, bool, error) {
+	var record KeyRecord
+	exists, err := state.getValue(KeyStatePrefix+address, &record)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &record, exists, nil
+}
+
+// currentAddress возвращает адрес, которым сейчас владеет пользователь address:
+// после замены ключа подписи права старого адреса переходят к новому
+func currentAddress(state *stateBatch, address string) (string, error) {
+	for depth := 0; ; depth++ {
+		record, exists, err := findKeyRecord(state, address)
+		if err != nil {
+			return "", err
+		}
+
+		if !exists || record.RotatedTo == "" {
+			return address, nil
+		}
+
+		if depth >= maxRotationDepth {
+			return "", fmt.Errorf("rotation chain of %s is too long", address)
+		}
+
+		address = record.RotatedTo
+	}
+}
+
+// ownedBy проверяет, перешли ли права сохраненного адреса owner к отправителю sender
+func ownedBy(state *stateBatch, owner string, sender string) (bool, error) {
+	current, err := currentAddress(state, owner)
+	if err != nil {
+		return false, err
+	}
+
+	return current == sender, nil
+}
+
+// checkSenderKey отклоняет транзакции, подписанные отозванным или замененным ключом
+func checkSenderKey(state *stateBatch, sender string) error {
+	record, exists, err := findKeyRecord(state, sender)
+	if err != nil {
+		return err
+	}
+
+	if exists && record.Retired() {
+		return fmt.Errorf("%w: %s", ErrKeyRetired, sender)
+	}
+
+	return nil
+}
+
+// setKeys устанавливает новые ключи адреса, сохраняя предыдущие в истории
+func setKeys(state *stateBatch, address string, version KeyVersion, height int64) error {
+	record, exists, err := findKeyRecord(state, address)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		record.History = append(record.History, KeyVersion{
+			EncryptionKey: record.EncryptionKey,
+			SigningKey:    record.SigningKey,
+			Prekey:        record.Prekey,
+			From:          record.Height,
+			Until:         height,
+		})
+	}
+
+	record.Address = address
+	record.EncryptionKey = version.EncryptionKey
+	record.SigningKey = version.SigningKey
+	record.Prekey = version.Prekey
+	record.Height = height
+
+	return state.putValue(KeyStatePrefix+address, record)
+}
+
+// applyKeyRegistration сохраняет опубликованные ключи отправителя в индексе
+func applyKeyRegistration(state *stateBatch, tx *transaction.Transaction, height int64) error {
+	registration, err := tx.KeyRegistration()
+	if err != nil {
+		return err
+	}
+
+	err = registration.Validate(tx.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	return setKeys(state, tx.Sender, KeyVersion{
+		EncryptionKey: registration.EncryptionKey,
+		SigningKey:    registration.SigningKey,
+		Prekey:        registration.Prekey,
+	}, height)
+}
+
+// applyKeyRotation заменяет ключи отправителя. При замене ключа подписи
+// ключи и баланс переносятся на адрес нового ключа, а старый адрес
+// перенаправляется на него. Имена и выходы сообщений старого адреса
+// принадлежат новому через перенаправление (см. currentAddress).
+func applyKeyRotation(state *stateBatch, tx *transaction.Transaction, height int64) error {
+	rotation, err := tx.KeyRotation()
+	if err != nil {
+		return err
+	}
+
+	err = rotation.Validate(tx.Sender, tx.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	if !rotation.ChangesSigningKey(tx.PublicKey) {
+		return setKeys(state, tx.Sender, KeyVersion{
+			EncryptionKey: rotation.EncryptionKey,
+			SigningKey:    tx.PublicKey,
+			Prekey:        rotation.Prekey,
+		}, height)
+	}
+
+	newAddress := transaction.SenderFromPublicKey(rotation.SigningKey)
+	_, exists, err := findKeyRecord(state, newAddress)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("keys are already registered for %s", newAddress)
+	}
+
+	oldRecord, exists, err := findKeyRecord(state, tx.Sender)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		oldRecord = &KeyRecord{Address: tx.Sender, SigningKey: tx.PublicKey, Height: height}
+	}
+
+	oldRecord.RotatedTo = newAddress
+	oldRecord.RotatedAt = height
+	err = state.putValue(KeyStatePrefix+tx.Sender, oldRecord)
+	if err != nil {
+		return err
+	}
+
+	err = setKeys(state, newAddress, KeyVersion{
+		EncryptionKey: rotation.EncryptionKey,
+		SigningKey:    rotation.SigningKey,
+		Prekey:        rotation.Prekey,
+	}, height)
+	if err != nil {
+		return err
+	}
+
+	// Старым ключом больше нельзя подписать перевод, поэтому баланс переносится сразу
+	balance, err := getBalance(state, tx.Sender)
+	if err != nil {
+		return err
+	}
+
+	err = addBalance(state, tx.Sender, -balance)
+	if err != nil {
+		return err
+	}
+
+	return addBalance(state, newAddress, balance)
+}
+
+// applyKeyRevocation отзывает ключи отправителя начиная с блока height
+func applyKeyRevocation(state *stateBatch, tx *transaction.Transaction, height int64) error {
+	record, exists, err := findKeyRecord(state, tx.Sender)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		record = &KeyRecord{Address: tx.Sender, SigningKey: tx.PublicKey, Height: height}
+	}
+
+	record.RevokedAt = height
+	return state.putValue(KeyStatePrefix+tx.Sender, record)
+}
@@ -0,0 +1,399 @@
+package blockchain
+
+import (
+	"blockchainStorage/internal/transaction"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"testing"
+)
+
+func TestKeyDirectory(t *testing.T) {
+	dbStorage := NewMockDbStorage()
+	bc, err := NewBlockchain(1, dbStorage)
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+
+	pool := &testPool{}
+	bc.SetTxPool(pool)
+
+	signingKey := newTestKey(t)
+	encryptionKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+
+	tx, err := transaction.NewKeyRegistrationTransaction(transaction.KeyRegistration{
+		EncryptionKey: x509.MarshalPKCS1PublicKey(&encryptionKey.PublicKey),
+		SigningKey:    elliptic.Marshal(elliptic.P256(), signingKey.PublicKey.X, signingKey.PublicKey.Y),
+	})
+	if err != nil {
+		t.Fatalf("failed to create key registration: %v", err)
+	}
+	tx.Sequence = 1
+	err = tx.Sign(signingKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+
+	err = bc.ValidateTransaction(tx)
+	if err != nil {
+		t.Fatalf("expected key registration to be valid: %v", err)
+	}
+
+	// До подтверждения ключи не найдены
+	_, err = bc.LookupKeys(tx.Sender)
+	if !errors.Is(err, ErrKeyNotRegistered) {
+		t.Errorf("expected ErrKeyNotRegistered, got %v", err)
+	}
+
+	_ = pool.Add(tx)
+	err = bc.AddBlock("Block Data", testMinerAddress)
+	if err != nil {
+		t.Fatalf("failed to add block: %v", err)
+	}
+
+	record, err := bc.LookupKeys(tx.Sender)
+	if err != nil {
+		t.Fatalf("failed to lookup keys: %v", err)
+	}
+	if record.Height != 1 {
+		t.Errorf("expected registration height 1, got %d", record.Height)
+	}
+
+	// Получатель сообщения находится по адресу через состояние блокчейна
+	message, err := transaction.NewTransactionWithResolver(nil, []transaction.MessageOutput{
+		{EncryptedData: []byte("hello"), Recipient: tx.Sender},
+	}, bc)
+	if err != nil {
+		t.Fatalf("failed to create transaction: %v", err)
+	}
+
+	plaintext, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, encryptionKey, message.Outputs[0].EncryptedData, nil)
+	if err != nil {
+		t.Fatalf("failed to decrypt message: %v", err)
+	}
+	if string(plaintext) != "hello" {
+		t.Errorf("decrypted message: got %s, expected hello", plaintext)
+	}
+
+	// Ключ подписи, не совпадающий с ключом транзакции, отклоняется
+	otherKey := newTestKey(t)
+	forged, err := transaction.NewKeyRegistrationTransaction(transaction.KeyRegistration{
+		EncryptionKey: x509.MarshalPKCS1PublicKey(&encryptionKey.PublicKey),
+		SigningKey:    elliptic.Marshal(elliptic.P256(), signingKey.PublicKey.X, signingKey.PublicKey.Y),
+	})
+	if err != nil {
+		t.Fatalf("failed to create key registration: %v", err)
+	}
+	forged.Sequence = 1
+	err = forged.Sign(otherKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+
+	err = bc.ValidateTransaction(forged)
+	if !errors.Is(err, transaction.ErrSigningKeyMismatch) {
+		t.Errorf("expected ErrSigningKeyMismatch, got %v", err)
+	}
+}
+
+func TestKeyDirectoryIdentityKey(t *testing.T) {
+	dbStorage := NewMockDbStorage()
+	bc, err := NewBlockchain(1, dbStorage)
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+
+	pool := &testPool{}
+	bc.SetTxPool(pool)
+
+	// Пользователь публикует только ключ личности, без RSA ключа
+	signingKey := newTestKey(t)
+	tx, err := transaction.NewKeyRegistrationTransaction(transaction.KeyRegistration{
+		SigningKey: elliptic.Marshal(elliptic.P256(), signingKey.PublicKey.X, signingKey.PublicKey.Y),
+	})
+	if err != nil {
+		t.Fatalf("failed to create key registration: %v", err)
+	}
+	signTx(t, tx, signingKey, 1)
+	mineTransactions(t, bc, pool, tx)
+
+	message, err := transaction.NewTransactionWithResolver(nil, []transaction.MessageOutput{
+		{EncryptedData: []byte("hello"), Recipient: tx.Sender},
+	}, bc)
+	if err != nil {
+		t.Fatalf("failed to create transaction: %v", err)
+	}
+	if message.Outputs[0].Scheme != transaction.SchemeECIES {
+		t.Fatalf("expected scheme %s, got %q", transaction.SchemeECIES, message.Outputs[0].Scheme)
+	}
+
+	plaintext, err := message.DecryptOutput(0, signingKey)
+	if err != nil {
+		t.Fatalf("failed to decrypt message: %v", err)
+	}
+	if string(plaintext) != "hello" {
+		t.Errorf("decrypted message: got %s, expected hello", plaintext)
+	}
+}
+
+func newEncryptionKey(t *testing.T) *rsa.PrivateKey {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	return privateKey
+}
+
+func signTx(t *testing.T, tx *transaction.Transaction, privateKey *ecdsa.PrivateKey, sequence uint64) *transaction.Transaction {
+	tx.Sequence = sequence
+	err := tx.Sign(privateKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	return tx
+}
+
+// rotateSigningKey заменяет ключ подписи пользователя и возвращает новый ключ и адрес
+func rotateSigningKey(t *testing.T, bc *Blockchain, pool *testPool, privateKey *ecdsa.PrivateKey, address string, sequence uint64) (*ecdsa.PrivateKey, string) {
+	newKey := newTestKey(t)
+	rotation, err := transaction.NewKeyRotationTransaction(address, transaction.KeyRotation{}, newKey)
+	if err != nil {
+		t.Fatalf("failed to create key rotation: %v", err)
+	}
+	mineTransactions(t, bc, pool, signTx(t, rotation, privateKey, sequence))
+
+	return newKey, transaction.SenderFromPublicKey(elliptic.Marshal(elliptic.P256(), newKey.PublicKey.X, newKey.PublicKey.Y))
+}
+
+func TestKeyRotationAndRevocation(t *testing.T) {
+	dbStorage := NewMockDbStorage()
+	bc, err := NewBlockchain(1, dbStorage)
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+
+	pool := &testPool{}
+	bc.SetTxPool(pool)
+
+	oldSigningKey := newTestKey(t)
+	oldEncryptionKey := newEncryptionKey(t)
+	registration, err := transaction.NewKeyRegistrationTransaction(transaction.KeyRegistration{
+		EncryptionKey: x509.MarshalPKCS1PublicKey(&oldEncryptionKey.PublicKey),
+		SigningKey:    elliptic.Marshal(elliptic.P256(), oldSigningKey.PublicKey.X, oldSigningKey.PublicKey.Y),
+	})
+	if err != nil {
+		t.Fatalf("failed to create key registration: %v", err)
+	}
+	signTx(t, registration, oldSigningKey, 1)
+	oldAddress := registration.Sender
+	mineTransactions(t, bc, pool, registration)
+
+	// Замена ключа шифрования без смены ключа подписи
+	newEncryptionKey := newEncryptionKey(t)
+	rotation, err := transaction.NewKeyRotationTransaction(oldAddress, transaction.KeyRotation{
+		EncryptionKey: x509.MarshalPKCS1PublicKey(&newEncryptionKey.PublicKey),
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to create key rotation: %v", err)
+	}
+	mineTransactions(t, bc, pool, signTx(t, rotation, oldSigningKey, 2))
+
+	record, err := bc.LookupKeys(oldAddress)
+	if err != nil {
+		t.Fatalf("failed to lookup keys: %v", err)
+	}
+	if record.Height != 2 || len(record.History) != 1 {
+		t.Fatalf("expected rotated keys effective from block 2 with history, got %+v", record)
+	}
+
+	// История хранит ключи, действовавшие на момент блока 1
+	version, ok := record.KeysAt(1)
+	if !ok || string(version.EncryptionKey) != string(x509.MarshalPKCS1PublicKey(&oldEncryptionKey.PublicKey)) {
+		t.Errorf("expected old encryption key to be effective at block 1")
+	}
+
+	// Замена ключа подписи переносит ключи на новый адрес
+	newSigningKey := newTestKey(t)
+	rotation, err = transaction.NewKeyRotationTransaction(oldAddress, transaction.KeyRotation{
+		EncryptionKey: x509.MarshalPKCS1PublicKey(&newEncryptionKey.PublicKey),
+	}, newSigningKey)
+	if err != nil {
+		t.Fatalf("failed to create key rotation: %v", err)
+	}
+	mineTransactions(t, bc, pool, signTx(t, rotation, oldSigningKey, 3))
+
+	active, err := bc.LookupActiveKeys(oldAddress)
+	if err != nil {
+		t.Fatalf("failed to lookup active keys: %v", err)
+	}
+	newAddress := transaction.SenderFromPublicKey(elliptic.Marshal(elliptic.P256(), newSigningKey.PublicKey.X, newSigningKey.PublicKey.Y))
+	if active.Address != newAddress {
+		t.Errorf("expected old address to resolve to %s, got %s", newAddress, active.Address)
+	}
+
+	// Старый ключ подписи больше не принимается
+	message := signTx(t, &transaction.Transaction{ID: "message"}, oldSigningKey, 4)
+	err = bc.ValidateTransaction(message)
+	if !errors.Is(err, ErrKeyRetired) {
+		t.Errorf("expected ErrKeyRetired, got %v", err)
+	}
+
+	// Действующим считается только ключ нового адреса
+	for address, expected := range map[string]bool{oldAddress: false, newAddress: true} {
+		active, err := bc.HasActiveKey(address)
+		if err != nil || active != expected {
+			t.Errorf("expected active key of %s to be %v, got %v, %v", address, expected, active, err)
+		}
+	}
+
+	// После отзыва отправители отказываются шифровать ключами получателя
+	revocation, err := transaction.NewKeyRevocationTransaction(transaction.KeyRevocation{Reason: "compromised"})
+	if err != nil {
+		t.Fatalf("failed to create key revocation: %v", err)
+	}
+	mineTransactions(t, bc, pool, signTx(t, revocation, newSigningKey, 1))
+
+	_, err = bc.ResolveEncryptionKey(oldAddress)
+	if !errors.Is(err, ErrKeyRevoked) {
+		t.Errorf("expected ErrKeyRevoked, got %v", err)
+	}
+
+	_, err = transaction.NewTransactionWithResolver(nil, []transaction.MessageOutput{
+		{EncryptedData: []byte("hello"), Recipient: newAddress},
+	}, bc)
+	if !errors.Is(err, ErrKeyRevoked) {
+		t.Errorf("expected encryption to revoked key to fail, got %v", err)
+	}
+}
+
+func TestKeyRotationKeepsAccount(t *testing.T) {
+	dbStorage := NewMockDbStorage()
+	bc, err := NewBlockchain(1, dbStorage)
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+	bc.BlockReward = 5
+
+	pool := &testPool{}
+	bc.SetTxPool(pool)
+	oldKey, bobKey := newTestKey(t), newTestKey(t)
+	addresses := registerIdentities(t, bc, pool, oldKey, bobKey)
+	oldAddress, bob := addresses[0], addresses[1]
+
+	// Пользователь получает награду за блок, регистрирует имя и получает сообщение
+	err = bc.AddBlock("Block Data", oldAddress)
+	if err != nil {
+		t.Fatalf("failed to add block: %v", err)
+	}
+
+	claim, err := transaction.NewNameClaimTransaction(transaction.NameClaim{Name: "alice", Duration: 10})
+	if err != nil {
+		t.Fatalf("failed to create name claim: %v", err)
+	}
+	hello := newReply(t, bobKey, "hello", "@alice", nil, 2)
+	mineTransactions(t, bc, pool, signTx(t, claim, oldKey, 2))
+	mineTransactions(t, bc, pool, hello)
+
+	funds, err := bc.Balance(oldAddress)
+	if err != nil {
+		t.Fatalf("failed to get balance: %v", err)
+	}
+	if funds != 5 {
+		t.Fatalf("expected funded account, got balance %d", funds)
+	}
+
+	// Замена ключа подписи
+	newKey := newTestKey(t)
+	rotation, err := transaction.NewKeyRotationTransaction(oldAddress, transaction.KeyRotation{}, newKey)
+	if err != nil {
+		t.Fatalf("failed to create key rotation: %v", err)
+	}
+	mineTransactions(t, bc, pool, signTx(t, rotation, oldKey, 3))
+	newAddress := transaction.SenderFromPublicKey(elliptic.Marshal(elliptic.P256(), newKey.PublicKey.X, newKey.PublicKey.Y))
+
+	// Баланс переносится на новый адрес
+	balance, err := bc.Balance(newAddress)
+	if err != nil {
+		t.Fatalf("failed to get balance: %v", err)
+	}
+	if balance != funds {
+		t.Errorf("expected balance %d on new address, got %d", funds, balance)
+	}
+	balance, err = bc.Balance(oldAddress)
+	if err != nil {
+		t.Fatalf("failed to get balance: %v", err)
+	}
+	if balance != 0 {
+		t.Errorf("expected empty balance on old address, got %d", balance)
+	}
+
+	// Имя указывает на новый адрес
+	owner, err := bc.ResolveRecipient("@alice")
+	if err != nil {
+		t.Fatalf("failed to resolve name: %v", err)
+	}
+	if owner != newAddress {
+		t.Errorf("expected name to resolve to %s, got %s", newAddress, owner)
+	}
+
+	current, err := bc.CurrentAddress(oldAddress)
+	if err != nil || current != newAddress {
+		t.Errorf("expected old address to move to %s, got %s, %v", newAddress, current, err)
+	}
+
+	// Новый ключ отвечает на сообщение старому адресу и продлевает имя
+	reply := newReply(t, newKey, "reply", bob, []transaction.MessageInput{{TransactionID: "hello", OutputIndex: 0}}, 1)
+	reply.Fee = 1
+	signTx(t, reply, newKey, 1)
+	renewal, err := transaction.NewNameClaimTransaction(transaction.NameClaim{Name: "alice", Duration: 100})
+	if err != nil {
+		t.Fatalf("failed to create name claim: %v", err)
+	}
+	signTx(t, renewal, newKey, 2)
+
+	for _, tx := range []*transaction.Transaction{reply, renewal} {
+		err = bc.ValidateTransaction(tx)
+		if err != nil {
+			t.Fatalf("expected transaction %s from new key to be valid, got %v", tx.ID, err)
+		}
+	}
+	mineTransactions(t, bc, pool, reply, renewal)
+
+	output, err := bc.LookupOutput("hello", 0)
+	if err != nil {
+		t.Fatalf("failed to lookup output: %v", err)
+	}
+	if output.SpentBy != "reply" {
+		t.Errorf("expected output to be spent by reply, got %+v", output)
+	}
+
+	name, err := bc.ResolveName("alice")
+	if err != nil {
+		t.Fatalf("failed to resolve name: %v", err)
+	}
+	if name.Owner != newAddress || name.ExpiresAt < 100 {
+		t.Errorf("expected name renewed by new address, got %+v", name)
+	}
+
+	// Откат ротации возвращает баланс старому адресу
+	for i := 0; i < 2; i++ {
+		_, err = bc.DisconnectTip()
+		if err != nil {
+			t.Fatalf("failed to disconnect tip: %v", err)
+		}
+	}
+	balance, err = bc.Balance(oldAddress)
+	if err != nil {
+		t.Fatalf("failed to get balance: %v", err)
+	}
+	if balance != funds {
+		t.Errorf("expected balance %d restored on old address, got %d", funds, balance)
+	}
+}
@@ -0,0 +1,129 @@
+package blockchain
+
+import (
+	"blockchainStorage/internal/transaction"
+	"errors"
+	"fmt"
+)
+
+const (
+	NameStatePrefix = "name_"
+	// MaxNameDuration максимальный срок регистрации имени в блоках
+	MaxNameDuration = 1 << 20
+)
+
+var (
+	ErrNameTaken        = errors.New("name is already registered")
+	ErrNameNotFound     = errors.New("name is not registered")
+	ErrNegativeDuration = errors.New("name registration duration is negative")
+	ErrDurationTooLong  = errors.New("name registration duration is too long")
+)
+
+// NameRecord имя пользователя, привязанное к ключу владельца
+type NameRecord struct {
+	Name      string
+	Owner     string
+	PublicKey []byte
+	// Height индекс блока первой регистрации имени текущим владельцем
+	Height int64
+	// ExpiresAt индекс блока, начиная с которого имя свободно, 0 - бессрочно
+	ExpiresAt int64
+}
+
+// Expired проверяет, истек ли срок регистрации имени к блоку height
+func (record *NameRecord) Expired(height int64) bool {
+	return record.ExpiresAt != 0 && height >= record.ExpiresAt
+}
+
+// ResolveName возвращает действующую регистрацию имени
+func (bc *Blockchain) ResolveName(name string) (*NameRecord, error) {
+	height, err := bc.nextHeight()
+	if err != nil {
+		return nil, err
+	}
+
+	return resolveName(newStateBatch(bc.db), name, height)
+}
+
+// ResolveRecipient преобразует получателя в адрес: имя вида "@name"
+// заменяется адресом владельца, адрес возвращается без изменений
+func (bc *Blockchain) ResolveRecipient(recipient string) (string, error) {
+	name, isHandle := transaction.ParseHandle(recipient)
+	if !isHandle {
+		return recipient, nil
+	}
+
+	record, err := bc.ResolveName(name)
+	if err != nil {
+		return "", err
+	}
+
+	return record.Owner, nil
+}
+
+func resolveName(state *stateBatch, name string, height int64) (*NameRecord, error) {
+	var record NameRecord
+	exists, err := state.getValue(NameStatePrefix+name, &record)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists || record.Expired(height) {
+		return nil, fmt.Errorf("%w: %s", ErrNameNotFound, name)
+	}
+
+	// Имя владельца, заменившего ключ подписи, принадлежит его новому адресу
+	record.Owner, err = currentAddress(state, record.Owner)
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// applyNameClaim регистрирует имя за отправителем. Свободное или истекшее имя
+// получает первый заявитель, владелец может продлить свое имя повторной заявкой.
+func applyNameClaim(state *stateBatch, tx *transaction.Transaction, height int64) error {
+	claim, err := tx.NameClaim()
+	if err != nil {
+		return err
+	}
+
+	err = transaction.ValidateName(claim.Name)
+	if err != nil {
+		return err
+	}
+
+	if claim.Duration < 0 {
+		return ErrNegativeDuration
+	}
+
+	if claim.Duration > MaxNameDuration {
+		return fmt.Errorf("%w: %d blocks", ErrDurationTooLong, claim.Duration)
+	}
+
+	record, err := resolveName(state, claim.Name, height)
+	if err != nil && !errors.Is(err, ErrNameNotFound) {
+		return err
+	}
+
+	if record != nil && record.Owner != tx.Sender {
+		return fmt.Errorf("%w: %s", ErrNameTaken, claim.Name)
+	}
+
+	if record == nil {
+		record = &NameRecord{
+			Name:   claim.Name,
+			Owner:  tx.Sender,
+			Height: height,
+		}
+	}
+
+	record.PublicKey = tx.PublicKey
+	record.ExpiresAt = 0
+	if claim.Duration > 0 {
+		record.ExpiresAt = height + claim.Duration
+	}
+
+	return state.putValue(NameStatePrefix+claim.Name, record)
+}
@@ -0,0 +1,130 @@
+package blockchain
+
+import (
+	"blockchainStorage/internal/transaction"
+	"crypto/ecdsa"
+	"errors"
+	"math"
+	"testing"
+)
+
+func newSignedNameClaim(t *testing.T, privateKey *ecdsa.PrivateKey, name string, duration int64, sequence uint64) *transaction.Transaction {
+	tx, err := transaction.NewNameClaimTransaction(transaction.NameClaim{Name: name, Duration: duration})
+	if err != nil {
+		t.Fatalf("failed to create name claim: %v", err)
+	}
+
+	tx.Sequence = sequence
+	err = tx.Sign(privateKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+
+	return tx
+}
+
+func mineTransactions(t *testing.T, bc *Blockchain, pool *testPool, txs ...*transaction.Transaction) {
+	for _, tx := range txs {
+		_ = pool.Add(tx)
+	}
+
+	err := bc.AddBlock("Block Data", testMinerAddress)
+	if err != nil {
+		t.Fatalf("failed to add block: %v", err)
+	}
+}
+
+func TestNameRegistry(t *testing.T) {
+	dbStorage := NewMockDbStorage()
+	bc, err := NewBlockchain(1, dbStorage)
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+
+	pool := &testPool{}
+	bc.SetTxPool(pool)
+	alice := newTestKey(t)
+	bob := newTestKey(t)
+
+	// Имя регистрируется на 2 блока, начиная с блока 1
+	aliceClaim := newSignedNameClaim(t, alice, "alice", 2, 1)
+	mineTransactions(t, bc, pool, aliceClaim)
+
+	record, err := bc.ResolveName("alice")
+	if err != nil {
+		t.Fatalf("failed to resolve name: %v", err)
+	}
+	if record.Owner != aliceClaim.Sender || record.ExpiresAt != 3 {
+		t.Errorf("unexpected name record: %+v", record)
+	}
+
+	address, err := bc.ResolveRecipient("@alice")
+	if err != nil || address != aliceClaim.Sender {
+		t.Errorf("expected @alice to resolve to %s, got %s, %v", aliceClaim.Sender, address, err)
+	}
+
+	// Повторная заявка на занятое имя отклоняется
+	bobClaim := newSignedNameClaim(t, bob, "alice", 0, 1)
+	err = bc.ValidateTransaction(bobClaim)
+	if !errors.Is(err, ErrNameTaken) {
+		t.Errorf("expected ErrNameTaken, got %v", err)
+	}
+
+	// Владелец продлевает регистрацию
+	renewal := newSignedNameClaim(t, alice, "alice", 2, 2)
+	mineTransactions(t, bc, pool, renewal)
+
+	record, err = bc.ResolveName("alice")
+	if err != nil {
+		t.Fatalf("failed to resolve name: %v", err)
+	}
+	if record.ExpiresAt != 4 || record.Height != 1 {
+		t.Errorf("expected renewed registration until block 4, got %+v", record)
+	}
+
+	// После истечения срока имя может занять другой пользователь
+	mineTransactions(t, bc, pool)
+	_, err = bc.ResolveName("alice")
+	if !errors.Is(err, ErrNameNotFound) {
+		t.Errorf("expected expired name to be unresolvable, got %v", err)
+	}
+
+	mineTransactions(t, bc, pool, bobClaim)
+	record, err = bc.ResolveName("alice")
+	if err != nil {
+		t.Fatalf("failed to resolve name: %v", err)
+	}
+	if record.Owner != bobClaim.Sender {
+		t.Errorf("expected name to belong to bob, got %s", record.Owner)
+	}
+
+	// Некорректные имена отклоняются
+	_, err = transaction.NewNameClaimTransaction(transaction.NameClaim{Name: "Bad Name"})
+	if !errors.Is(err, transaction.ErrInvalidName) {
+		t.Errorf("expected ErrInvalidName, got %v", err)
+	}
+}
+
+func TestNameClaimDurationLimit(t *testing.T) {
+	dbStorage := NewMockDbStorage()
+	bc, err := NewBlockchain(1, dbStorage)
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+
+	alice := newTestKey(t)
+
+	// Срок, переполняющий индекс блока, отклоняется
+	claim := newSignedNameClaim(t, alice, "alice", math.MaxInt64, 1)
+	err = bc.ValidateTransaction(claim)
+	if !errors.Is(err, ErrDurationTooLong) {
+		t.Errorf("expected ErrDurationTooLong, got %v", err)
+	}
+
+	// Максимальный срок допустим
+	claim = newSignedNameClaim(t, alice, "alice", MaxNameDuration, 1)
+	err = bc.ValidateTransaction(claim)
+	if err != nil {
+		t.Errorf("expected maximum duration to be accepted, got %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package blockchain
+
+import (
+	"blockchainStorage/internal/transaction"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const OutputStatePrefix = "utxo_"
+
+var (
+	ErrOutputNotFound   = errors.New("referenced output not found")
+	ErrOutputSpent      = errors.New("referenced output is already spent")
+	ErrNotOutputOwner   = errors.New("only the recipient of an output can spend it")
+	ErrUnexpectedInputs = errors.New("transaction type does not accept inputs")
+)
+
+// OutputRecord выход подтвержденного сообщения. Выход - это право получателя
+// ответить на сообщение: ответ ссылается на выход во входе транзакции и
+// расходует его, поэтому на каждое полученное сообщение можно ответить один раз.
+// Записи остаются в состоянии после расходования, чтобы повторная попытка
+// отличалась от ссылки на несуществующий выход.
+type OutputRecord struct {
+	TxID   string
+	Index  int
+	Sender string
+	// Owner адрес получателя. Имя вида "@name" заменяется адресом владельца
+	// на момент подтверждения сообщения.
+	Owner  string
+	Height int64
+	// SpentBy транзакция, израсходовавшая выход, пусто для неизрасходованного выхода
+	SpentBy string
+}
+
+// Spent проверяет, израсходован ли выход
+func (record *OutputRecord) Spent() bool {
+	return record.SpentBy != ""
+}
+
+// LookupOutput возвращает выход index подтвержденного сообщения txID
+func (bc *Blockchain) LookupOutput(txID string, index int) (*OutputRecord, error) {
+	return getOutput(newStateBatch(bc.db), txID, index)
+}
+
+// OutputOwner возвращает адрес получателя выхода index сообщения tx по
+// состоянию db. Для подтвержденного сообщения это владелец выхода, для еще не
+// подтвержденного имя вида "@name" заменяется текущим владельцем имени.
+func OutputOwner(db DbInterface, tx *transaction.Transaction, index int) (string, error) {
+	recipient := tx.Outputs[index].Recipient
+	name, isHandle := transaction.ParseHandle(recipient)
+	if !isHandle {
+		return recipient, nil
+	}
+
+	state := newStateBatch(db)
+	record, err := getOutput(state, tx.ID, index)
+	if err == nil {
+		return record.Owner, nil
+	}
+	if !errors.Is(err, ErrOutputNotFound) {
+		return "", err
+	}
+
+	// Высота неподтвержденного сообщения неизвестна, поэтому срок регистрации
+	// не проверяется: истекшее имя, которое никто не занял, остается за владельцем
+	nameRecord, err := resolveName(state, name, 0)
+	if err != nil {
+		return "", err
+	}
+
+	return nameRecord.Owner, nil
+}
+
+func outputStateKey(txID string, index int) string {
+	return OutputStatePrefix + txID + "_" + strconv.Itoa(index)
+}
+
+func getOutput(state *stateBatch, txID string, index int) (*OutputRecord, error) {
+	var record OutputRecord
+	exists, err := state.getValue(outputStateKey(txID, index), &record)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, fmt.Errorf("%w: %s:%d", ErrOutputNotFound, txID, index)
+	}
+
+	return &record, nil
+}
+
+// spendInputs расходует выходы, на которые ссылаются входы транзакции.
+// Расходовать выход может только его получатель (или его новый адрес после
+// замены ключа подписи) и только один раз, в том числе в пределах одного
+// блока и одной транзакции.
+func spendInputs(state *stateBatch, tx *transaction.Transaction) error {
+	for i, input := range tx.Inputs {
+		record, err := getOutput(state, input.TransactionID, input.OutputIndex)
+		if err != nil {
+			return fmt.Errorf("invalid input %d: %w", i, err)
+		}
+
+		owner, err := currentAddress(state, record.Owner)
+		if err != nil {
+			return err
+		}
+
+		if owner != tx.Sender {
+			return fmt.Errorf("invalid input %d: %w", i, ErrNotOutputOwner)
+		}
+
+		if record.Spent() {
+			return fmt.Errorf("invalid input %d: %w: %s:%d by %s", i, ErrOutputSpent, record.TxID, record.Index, record.SpentBy)
+		}
+
+		record.SpentBy = tx.ID
+		err = state.putValue(outputStateKey(record.TxID, record.Index), record)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addOutputs добавляет выходы сообщения в набор неизрасходованных выходов.
+// Выходы, адресованные незарегистрированному имени, не имеют владельца и не создаются.
+func addOutputs(state *stateBatch, tx *transaction.Transaction, height int64) error {
+	for i, output := range tx.Outputs {
+		owner := output.Recipient
+		if name, isHandle := transaction.ParseHandle(output.Recipient); isHandle {
+			record, err := resolveName(state, name, height)
+			if errors.Is(err, ErrNameNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			owner = record.Owner
+		}
+
+		err := state.putValue(outputStateKey(tx.ID, i), &OutputRecord{
+			TxID:   tx.ID,
+			Index:  i,
+			Sender: tx.Sender,
+			Owner:  owner,
+			Height: height,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package blockchain
+
+import (
+	"blockchainStorage/internal/transaction"
+	"crypto/ecdsa"
+	"errors"
+	"testing"
+)
+
+func newReply(t *testing.T, privateKey *ecdsa.PrivateKey, id string, recipient string, inputs []transaction.MessageInput, sequence uint64) *transaction.Transaction {
+	tx := &transaction.Transaction{
+		ID:     id,
+		Inputs: inputs,
+		Outputs: []transaction.MessageOutput{
+			{EncryptedData: []byte(id), Recipient: recipient},
+		},
+	}
+
+	return signTx(t, tx, privateKey, sequence)
+}
+
+func TestSpendMessageOutputs(t *testing.T) {
+	dbStorage := NewMockDbStorage()
+	bc, err := NewBlockchain(1, dbStorage)
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+
+	pool := &testPool{}
+	bc.SetTxPool(pool)
+	aliceKey, bobKey := newTestKey(t), newTestKey(t)
+	addresses := registerIdentities(t, bc, pool, aliceKey, bobKey)
+	alice, bob := addresses[0], addresses[1]
+
+	hello := newReply(t, aliceKey, "hello", bob, nil, 2)
+	mineTransactions(t, bc, pool, hello)
+
+	output, err := bc.LookupOutput("hello", 0)
+	if err != nil {
+		t.Fatalf("failed to lookup output: %v", err)
+	}
+	if output.Owner != bob || output.Sender != alice || output.Spent() {
+		t.Errorf("unexpected output record: %+v", output)
+	}
+
+	slot := []transaction.MessageInput{{TransactionID: "hello", OutputIndex: 0}}
+
+	// Только получатель может израсходовать выход
+	stolen := newReply(t, aliceKey, "stolen", bob, slot, 3)
+	err = bc.ValidateTransaction(stolen)
+	if !errors.Is(err, ErrNotOutputOwner) {
+		t.Errorf("expected ErrNotOutputOwner, got %v", err)
+	}
+
+	missing := newReply(t, bobKey, "missing", alice, []transaction.MessageInput{{TransactionID: "hello", OutputIndex: 1}}, 2)
+	err = bc.ValidateTransaction(missing)
+	if !errors.Is(err, ErrOutputNotFound) {
+		t.Errorf("expected ErrOutputNotFound, got %v", err)
+	}
+
+	// Выход нельзя израсходовать дважды в одной транзакции
+	twice := newReply(t, bobKey, "twice", alice, append(slot, slot...), 2)
+	err = bc.ValidateTransaction(twice)
+	if !errors.Is(err, ErrOutputSpent) {
+		t.Errorf("expected ErrOutputSpent, got %v", err)
+	}
+
+	// Входы допускаются только в сообщениях
+	claim, err := transaction.NewNameClaimTransaction(transaction.NameClaim{Name: "bob"})
+	if err != nil {
+		t.Fatalf("failed to create name claim: %v", err)
+	}
+	claim.Inputs = slot
+	err = bc.ValidateTransaction(signTx(t, claim, bobKey, 2))
+	if !errors.Is(err, ErrUnexpectedInputs) {
+		t.Errorf("expected ErrUnexpectedInputs, got %v", err)
+	}
+
+	reply := newReply(t, bobKey, "reply", alice, slot, 2)
+	mineTransactions(t, bc, pool, reply)
+
+	output, err = bc.LookupOutput("hello", 0)
+	if err != nil {
+		t.Fatalf("failed to lookup output: %v", err)
+	}
+	if output.SpentBy != "reply" {
+		t.Errorf("expected output to be spent by reply, got %q", output.SpentBy)
+	}
+
+	doubleSpend := newReply(t, bobKey, "again", alice, slot, 3)
+	err = bc.ValidateTransaction(doubleSpend)
+	if !errors.Is(err, ErrOutputSpent) {
+		t.Errorf("expected ErrOutputSpent, got %v", err)
+	}
+
+	// Откат блока возвращает выход в набор неизрасходованных
+	_, err = bc.DisconnectTip()
+	if err != nil {
+		t.Fatalf("failed to disconnect tip: %v", err)
+	}
+
+	output, err = bc.LookupOutput("hello", 0)
+	if err != nil {
+		t.Fatalf("failed to lookup output: %v", err)
+	}
+	if output.Spent() {
+		t.Errorf("expected output to be unspent after reorg, spent by %q", output.SpentBy)
+	}
+
+	_, err = bc.LookupOutput("reply", 0)
+	if !errors.Is(err, ErrOutputNotFound) {
+		t.Errorf("expected reply output to be removed after reorg, got %v", err)
+	}
+}
@@ -1,22 +1,33 @@
 package blockchain
 
 import (
+	"blockchainStorage/internal/transaction"
 	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"strconv"
 )
 
+var ErrStampWithoutSender = errors.New("transaction sender must be set before stamping")
+
 type ProofOfWork struct {
 	block      *Block
 	difficulty int
+	txHash     string
 }
 
 func NewProofOfWork(block *Block, difficulty int) *ProofOfWork {
-	return &ProofOfWork{
+	pow := &ProofOfWork{
 		block:      block,
 		difficulty: difficulty,
 	}
+
+	if len(block.Transactions) > 0 {
+		pow.txHash = block.HashTransactions()
+	}
+
+	return pow
 }
 
 func (pow *ProofOfWork) Run() (string, int64) {
@@ -43,10 +54,60 @@ func (pow *ProofOfWork) calculateHash(nonce int64) string {
 		pow.block.Data +
 		pow.block.PrevHash +
 		strconv.FormatInt(nonce, 10) +
-		pow.block.MinerAddress
+		pow.block.MinerAddress +
+		pow.txHash
 
+	return hashRecord(record)
+}
+
+func hashRecord(record string) string {
 	h := sha256.New()
 	h.Write([]byte(record))
 	hash := hex.EncodeToString(h.Sum(nil))
 	return hash
 }
+
+// StampTransaction подбирает hashcash-штамп для содержимого транзакции с
+// заданной сложностью (см. transaction.Transaction.StampHash). Отправитель,
+// последовательность и содержимое задаются до вычисления штампа, а подпись
+// после него, так как штамп входит в подпись.
+func StampTransaction(tx *transaction.Transaction, difficulty int) error {
+	if tx.Sender == "" {
+		return ErrStampWithoutSender
+	}
+
+	content, err := tx.StampHash()
+	if err != nil {
+		return err
+	}
+
+	target := string(bytes.Repeat([]byte("0"), difficulty))
+	var nonce int64 = 0
+
+	for !isValidHash(calculateStampHash(content, nonce), target) {
+		nonce++
+	}
+
+	tx.Stamp = nonce
+	return nil
+}
+
+// VerifyTransactionStamp проверяет, что штамп транзакции имеет требуемую
+// сложность и вычислен для ее содержимого
+func VerifyTransactionStamp(tx *transaction.Transaction, difficulty int) bool {
+	if tx.Sender == "" {
+		return false
+	}
+
+	content, err := tx.StampHash()
+	if err != nil {
+		return false
+	}
+
+	target := string(bytes.Repeat([]byte("0"), difficulty))
+	return isValidHash(calculateStampHash(content, tx.Stamp), target)
+}
+
+func calculateStampHash(content []byte, nonce int64) string {
+	return hashRecord(hex.EncodeToString(content) + strconv.FormatInt(nonce, 10))
+}
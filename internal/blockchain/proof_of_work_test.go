@@ -1,6 +1,11 @@
 package blockchain
 
 import (
+	"blockchainStorage/internal/transaction"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
 	"strings"
 	"testing"
 )
@@ -32,3 +37,66 @@ func TestProofOfWork(t *testing.T) {
 		t.Errorf("Incorrect nonce. Expected: %d, got: %d", expectedNonce, nonce)
 	}
 }
+
+func TestTransactionStamp(t *testing.T) {
+	tx := &transaction.Transaction{ID: "transaction_id", Sender: "alice", Sequence: 1, Payload: []byte("payload")}
+	difficulty := 3
+
+	// Без штампа транзакция не проходит проверку
+	if VerifyTransactionStamp(tx, difficulty) {
+		t.Fatal("expected transaction without stamp to fail verification")
+	}
+
+	err := StampTransaction(tx, difficulty)
+	if err != nil {
+		t.Fatalf("failed to stamp transaction: %v", err)
+	}
+	if !VerifyTransactionStamp(tx, difficulty) {
+		t.Errorf("expected stamped transaction to pass verification, stamp %d", tx.Stamp)
+	}
+
+	// Штамп привязан к содержимому: его нельзя перенести на другую транзакцию
+	// с тем же идентификатором
+	other := *tx
+	other.Payload = []byte("other payload")
+	if VerifyTransactionStamp(&other, difficulty) {
+		t.Error("expected stamp to be bound to transaction payload")
+	}
+
+	other = *tx
+	other.Sequence = 2
+	if VerifyTransactionStamp(&other, difficulty) {
+		t.Error("expected stamp to be bound to sender sequence")
+	}
+
+	other = *tx
+	other.Sender = "bob"
+	if VerifyTransactionStamp(&other, difficulty) {
+		t.Error("expected stamp to be bound to sender")
+	}
+
+	// Подпись после штампа не делает его недействительным
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	signed := &transaction.Transaction{ID: "signed_id", Sequence: 1}
+	signed.Sender = transaction.SenderFromPublicKey(elliptic.Marshal(elliptic.P256(), key.X, key.Y))
+	err = StampTransaction(signed, difficulty)
+	if err != nil {
+		t.Fatalf("failed to stamp transaction: %v", err)
+	}
+	err = signed.Sign(key)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if !VerifyTransactionStamp(signed, difficulty) {
+		t.Error("expected stamp to survive signing")
+	}
+
+	// Штамп вычисляется только для транзакции с отправителем
+	err = StampTransaction(&transaction.Transaction{ID: "anonymous"}, difficulty)
+	if !errors.Is(err, ErrStampWithoutSender) {
+		t.Errorf("expected ErrStampWithoutSender, got %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package blockchain
+
+import (
+	"blockchainStorage/internal/transaction"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const ReceiptStatePrefix = "rcpt_"
+
+var (
+	ErrReceiptNotFound   = errors.New("receipt not found")
+	ErrReceiptNotNewer   = errors.New("receipt does not advance message status")
+	ErrNotReceiptSubject = errors.New("only the recipient of a message can acknowledge it")
+)
+
+// ReceiptRecord последняя закрепленная в блокчейне квитанция о выходе сообщения
+type ReceiptRecord struct {
+	transaction.Receipt
+	Recipient string
+	Height    int64
+	// AnchorTx транзакция, закрепившая квитанцию
+	AnchorTx string
+}
+
+// LookupReceipt возвращает закрепленную квитанцию о выходе index сообщения txID
+func (bc *Blockchain) LookupReceipt(txID string, index int) (*ReceiptRecord, error) {
+	var record ReceiptRecord
+	exists, err := newStateBatch(bc.db).getValue(receiptStateKey(txID, index), &record)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, fmt.Errorf("%w: %s:%d", ErrReceiptNotFound, txID, index)
+	}
+
+	return &record, nil
+}
+
+func receiptStateKey(txID string, index int) string {
+	return ReceiptStatePrefix + txID + "_" + strconv.Itoa(index)
+}
+
+// applyReceipt закрепляет квитанцию получателя. Квитанцию может отправить только
+// владелец выхода, и каждая следующая квитанция должна повышать статус.
+func applyReceipt(state *stateBatch, tx *transaction.Transaction, height int64) error {
+	receipt, err := tx.Receipt()
+	if err != nil {
+		return err
+	}
+
+	err = receipt.Validate()
+	if err != nil {
+		return err
+	}
+
+	output, err := getOutput(state, receipt.TxID, receipt.OutputIndex)
+	if err != nil {
+		return err
+	}
+
+	owner, err := currentAddress(state, output.Owner)
+	if err != nil {
+		return err
+	}
+
+	if owner != tx.Sender {
+		return fmt.Errorf("%w: %s:%d", ErrNotReceiptSubject, receipt.TxID, receipt.OutputIndex)
+	}
+
+	var previous ReceiptRecord
+	exists, err := state.getValue(receiptStateKey(receipt.TxID, receipt.OutputIndex), &previous)
+	if err != nil {
+		return err
+	}
+
+	if exists && !receipt.Supersedes(&previous.Receipt) {
+		return fmt.Errorf("%w: already %s", ErrReceiptNotNewer, previous.Status)
+	}
+
+	return state.putValue(receiptStateKey(receipt.TxID, receipt.OutputIndex), &ReceiptRecord{
+		Receipt:   *receipt,
+		Recipient: tx.Sender,
+		Height:    height,
+		AnchorTx:  tx.ID,
+	})
+}
@@ -0,0 +1,71 @@
+package blockchain
+
+import (
+	"blockchainStorage/internal/transaction"
+	"crypto/ecdsa"
+	"errors"
+	"testing"
+)
+
+func newReceiptTx(t *testing.T, privateKey *ecdsa.PrivateKey, txID string, status string, sequence uint64) *transaction.Transaction {
+	tx, err := transaction.NewReceiptTransaction(transaction.Receipt{TxID: txID, Status: status})
+	if err != nil {
+		t.Fatalf("failed to create receipt transaction: %v", err)
+	}
+
+	return signTx(t, tx, privateKey, sequence)
+}
+
+func TestAnchorReceipts(t *testing.T) {
+	dbStorage := NewMockDbStorage()
+	bc, err := NewBlockchain(1, dbStorage)
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+
+	pool := &testPool{}
+	bc.SetTxPool(pool)
+	aliceKey, bobKey := newTestKey(t), newTestKey(t)
+	addresses := registerIdentities(t, bc, pool, aliceKey, bobKey)
+
+	hello := newReply(t, aliceKey, "hello", addresses[1], nil, 2)
+	mineTransactions(t, bc, pool, hello)
+
+	// Квитанцию может закрепить только получатель сообщения
+	err = bc.ValidateTransaction(newReceiptTx(t, aliceKey, "hello", transaction.ReceiptRead, 3))
+	if !errors.Is(err, ErrNotReceiptSubject) {
+		t.Errorf("expected ErrNotReceiptSubject, got %v", err)
+	}
+
+	err = bc.ValidateTransaction(newReceiptTx(t, bobKey, "unknown", transaction.ReceiptRead, 2))
+	if !errors.Is(err, ErrOutputNotFound) {
+		t.Errorf("expected ErrOutputNotFound, got %v", err)
+	}
+
+	delivered := newReceiptTx(t, bobKey, "hello", transaction.ReceiptDelivered, 2)
+	mineTransactions(t, bc, pool, delivered)
+
+	record, err := bc.LookupReceipt("hello", 0)
+	if err != nil {
+		t.Fatalf("failed to lookup receipt: %v", err)
+	}
+	if record.Status != transaction.ReceiptDelivered || record.Recipient != addresses[1] || record.AnchorTx != delivered.ID {
+		t.Errorf("unexpected receipt record: %+v", record)
+	}
+
+	// Повторная квитанция должна повышать статус
+	err = bc.ValidateTransaction(newReceiptTx(t, bobKey, "hello", transaction.ReceiptDelivered, 3))
+	if !errors.Is(err, ErrReceiptNotNewer) {
+		t.Errorf("expected ErrReceiptNotNewer, got %v", err)
+	}
+
+	mineTransactions(t, bc, pool, newReceiptTx(t, bobKey, "hello", transaction.ReceiptRead, 3))
+
+	record, err = bc.LookupReceipt("hello", 0)
+	if err != nil {
+		t.Fatalf("failed to lookup receipt: %v", err)
+	}
+	if record.Status != transaction.ReceiptRead {
+		t.Errorf("expected read receipt, got %s", record.Status)
+	}
+}
@@ -0,0 +1,188 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+const (
+	SequenceStatePrefix = "seq_"
+	UndoStatePrefix     = "undo_"
+)
+
+// stateChange прежнее значение ключа состояния, необходимое для отката блока
+type stateChange struct {
+	Key    string
+	Value  []byte
+	Exists bool
+}
+
+// stateSource источник значений состояния: БД или родительский набор изменений
+type stateSource interface {
+	GetStateFromDB(key string) ([]byte, error)
+}
+
+// stateBatch накапливает изменения состояния цепочки при подключении блока.
+// Изменения записываются в БД только при commit вместе с журналом отката.
+type stateBatch struct {
+	source  stateSource
+	pending map[string][]byte
+	deleted map[string]bool
+}
+
+func newStateBatch(source stateSource) *stateBatch {
+	return &stateBatch{
+		source:  source,
+		pending: make(map[string][]byte),
+		deleted: make(map[string]bool),
+	}
+}
+
+// GetStateFromDB позволяет вложенному набору изменений читать родительский
+func (b *stateBatch) GetStateFromDB(key string) ([]byte, error) {
+	return b.get(key)
+}
+
+func (b *stateBatch) get(key string) ([]byte, error) {
+	if b.deleted[key] {
+		return nil, nil
+	}
+
+	if value, exists := b.pending[key]; exists {
+		return value, nil
+	}
+
+	return b.source.GetStateFromDB(key)
+}
+
+func (b *stateBatch) put(key string, value []byte) {
+	delete(b.deleted, key)
+	b.pending[key] = value
+}
+
+func (b *stateBatch) delete(key string) {
+	delete(b.pending, key)
+	b.deleted[key] = true
+}
+
+// getValue читает значение состояния в value и сообщает, существует ли ключ
+func (b *stateBatch) getValue(key string, value interface{}) (bool, error) {
+	data, err := b.get(key)
+	if err != nil {
+		return false, err
+	}
+
+	if data == nil {
+		return false, nil
+	}
+
+	err = json.Unmarshal(data, value)
+	if err != nil {
+		return false, fmt.Errorf("failed to unmarshal state %s: %w", key, err)
+	}
+
+	return true, nil
+}
+
+func (b *stateBatch) putValue(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state %s: %w", key, err)
+	}
+
+	b.put(key, data)
+	return nil
+}
+
+// fork создает вложенный набор изменений, который можно применить или отбросить целиком
+func (b *stateBatch) fork() *stateBatch {
+	return newStateBatch(b)
+}
+
+// merge переносит изменения вложенного набора в родительский
+func (b *stateBatch) merge(child *stateBatch) {
+	for key := range child.deleted {
+		b.delete(key)
+	}
+
+	for key, value := range child.pending {
+		b.put(key, value)
+	}
+}
+
+// commit записывает изменения в БД и сохраняет журнал отката для блока blockHash
+func (b *stateBatch) commit(db DbInterface, blockHash string) error {
+	keys := make([]string, 0, len(b.pending)+len(b.deleted))
+	for key := range b.pending {
+		keys = append(keys, key)
+	}
+	for key := range b.deleted {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	undo := make([]stateChange, 0, len(keys))
+	for _, key := range keys {
+		prev, err := db.GetStateFromDB(key)
+		if err != nil {
+			return err
+		}
+
+		undo = append(undo, stateChange{Key: key, Value: prev, Exists: prev != nil})
+	}
+
+	undoData, err := json.Marshal(undo)
+	if err != nil {
+		return fmt.Errorf("failed to marshal undo data: %w", err)
+	}
+
+	err = db.SaveStateToDB(UndoStatePrefix+blockHash, undoData)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if b.deleted[key] {
+			err = db.DeleteStateFromDB(key)
+		} else {
+			err = db.SaveStateToDB(key, b.pending[key])
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// revertState восстанавливает состояние цепочки до подключения блока blockHash
+func revertState(db DbInterface, blockHash string) error {
+	undoData, err := db.GetStateFromDB(UndoStatePrefix + blockHash)
+	if err != nil {
+		return err
+	}
+
+	if undoData == nil {
+		return nil
+	}
+
+	var undo []stateChange
+	err = json.Unmarshal(undoData, &undo)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal undo data: %w", err)
+	}
+
+	for _, change := range undo {
+		if change.Exists {
+			err = db.SaveStateToDB(change.Key, change.Value)
+		} else {
+			err = db.DeleteStateFromDB(change.Key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return db.DeleteStateFromDB(UndoStatePrefix + blockHash)
+}
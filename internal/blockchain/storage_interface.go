@@ -6,4 +6,11 @@ type DbInterface interface {
 	SaveBlockToDB(block *Block) error
 	SaveTipToDB(tipHash string) error
 	GetBlockFromDB(blockHash string) ([]byte, error)
+	// PruneBlockInDB заменяет сохраненный блок очищенной версией с тем же хэшем.
+	// Прежнее содержимое блока не должно оставаться в хранилище.
+	PruneBlockInDB(block *Block) error
+	// GetStateFromDB возвращает значение состояния цепочки или nil, если ключ отсутствует
+	GetStateFromDB(key string) ([]byte, error)
+	SaveStateToDB(key string, value []byte) error
+	DeleteStateFromDB(key string) error
 }
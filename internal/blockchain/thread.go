@@ -0,0 +1,323 @@
+package blockchain
+
+import (
+	"blockchainStorage/internal/transaction"
+	"errors"
+	"fmt"
+)
+
+const (
+	MessageStatePrefix = "msg_"
+	// maxThreadDepth ограничивает вложенность ответов при построении беседы
+	maxThreadDepth = 64
+)
+
+var (
+	ErrDuplicateMessage = errors.New("message with this id already exists")
+	ErrMessageNotFound  = errors.New("referenced message not found")
+	ErrNotMessageSender = errors.New("only the original sender can edit or delete a message")
+	ErrMessageDeleted   = errors.New("message is deleted")
+	ErrInvalidReference = errors.New("invalid message reference")
+)
+
+// MessageRecord индекс сообщения: где оно подтверждено и какие сообщения на него ссылаются
+type MessageRecord struct {
+	ID     string
+	Type   string
+	Sender string
+	Height int64
+	// ReplyTo сообщение, на которое отвечает это сообщение
+	ReplyTo string
+	// Replies ответы на сообщение в порядке подтверждения
+	Replies []string
+	// Edits новые версии сообщения в порядке подтверждения
+	Edits []string
+	// EditOf исходное сообщение, если это сообщение является его правкой
+	EditOf string
+	// DeletedBy транзакция удаления сообщения
+	DeletedBy string
+	// ExpiresAt индекс блока, в котором удаляется содержимое сообщения, 0 - бессрочно
+	ExpiresAt int64 `json:",omitempty"`
+}
+
+// MessageView сообщение беседы с учетом правок и удаления
+type MessageView struct {
+	ID      string
+	Sender  string
+	Height  int64
+	ReplyTo string
+	// Original исходная транзакция сообщения
+	Original *transaction.Transaction
+	// Latest последняя версия сообщения, nil если сообщение удалено
+	Latest  *transaction.Transaction
+	Edited  bool
+	Deleted bool
+	// Expired содержимое сообщения удалено по истечении срока хранения
+	Expired bool
+	Depth   int
+}
+
+// LookupMessage возвращает индекс подтвержденного сообщения
+func (bc *Blockchain) LookupMessage(id string) (*MessageRecord, error) {
+	return getMessage(newStateBatch(bc.db), id)
+}
+
+// ResolveMessage возвращает последнюю версию сообщения с учетом правок и удаления
+func (bc *Blockchain) ResolveMessage(id string) (*MessageView, error) {
+	record, err := bc.LookupMessage(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return bc.messageView(record, 0)
+}
+
+// Conversation возвращает сообщение rootID и все ответы на него. Ответы следуют
+// за сообщением, на которое отвечают, в порядке подтверждения; Depth - уровень вложенности.
+func (bc *Blockchain) Conversation(rootID string) ([]*MessageView, error) {
+	state := newStateBatch(bc.db)
+	root, err := getMessage(state, rootID)
+	if err != nil {
+		return nil, err
+	}
+
+	var views []*MessageView
+	var walk func(record *MessageRecord, depth int) error
+	walk = func(record *MessageRecord, depth int) error {
+		view, err := bc.messageView(record, depth)
+		if err != nil {
+			return err
+		}
+		views = append(views, view)
+
+		if depth >= maxThreadDepth {
+			return nil
+		}
+
+		for _, replyID := range record.Replies {
+			reply, err := getMessage(state, replyID)
+			if err != nil {
+				return err
+			}
+
+			err = walk(reply, depth+1)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	err = walk(root, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return views, nil
+}
+
+func (bc *Blockchain) messageView(record *MessageRecord, depth int) (*MessageView, error) {
+	original, err := bc.confirmedTransaction(record.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &MessageView{
+		ID:       record.ID,
+		Sender:   record.Sender,
+		Height:   record.Height,
+		ReplyTo:  record.ReplyTo,
+		Original: original,
+		Latest:   original,
+		Edited:   len(record.Edits) > 0,
+		Deleted:  record.DeletedBy != "",
+		Expired:  original.Pruned(),
+		Depth:    depth,
+	}
+
+	if view.Deleted {
+		view.Latest = nil
+		return view, nil
+	}
+
+	if view.Edited {
+		view.Latest, err = bc.confirmedTransaction(record.Edits[len(record.Edits)-1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return view, nil
+}
+
+// confirmedTransaction находит подтвержденное сообщение по индексу сообщений
+func (bc *Blockchain) confirmedTransaction(id string) (*transaction.Transaction, error) {
+	record, err := bc.LookupMessage(id)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := bc.BlockAtHeight(record.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := block.transaction(id)
+	if tx == nil {
+		return nil, fmt.Errorf("message %s not found in block %d", id, record.Height)
+	}
+
+	return tx, nil
+}
+
+func getMessage(state *stateBatch, id string) (*MessageRecord, error) {
+	record, exists, err := findMessage(state, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, id)
+	}
+
+	return record, nil
+}
+
+func findMessage(state *stateBatch, id string) (*MessageRecord, bool, error) {
+	var record MessageRecord
+	exists, err := state.getValue(MessageStatePrefix+id, &record)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &record, exists, nil
+}
+
+// indexMessage проверяет ссылки сообщения на предыдущие сообщения и добавляет
+// его в индекс. Править и удалять сообщение может только его отправитель.
+func indexMessage(state *stateBatch, tx *transaction.Transaction, height int64) error {
+	meta, err := tx.MessageMeta()
+	if err != nil {
+		return err
+	}
+
+	err = meta.Validate()
+	if err != nil {
+		return err
+	}
+
+	_, exists, err := findMessage(state, tx.ID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: %s", ErrDuplicateMessage, tx.ID)
+	}
+
+	if meta.Expired(height) {
+		return fmt.Errorf("%w: expires at %d, block %d", ErrMessageExpired, meta.ExpiresAt, height)
+	}
+
+	record := &MessageRecord{
+		ID:        tx.ID,
+		Type:      tx.Type,
+		Sender:    tx.Sender,
+		Height:    height,
+		ReplyTo:   meta.ReplyTo,
+		EditOf:    meta.Edits,
+		ExpiresAt: meta.ExpiresAt,
+	}
+
+	if meta.ExpiresAt != 0 {
+		err = addExpiry(state, tx.ID, meta.ExpiresAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	if meta.ReplyTo != "" {
+		err = updateReference(state, meta.ReplyTo, func(target *MessageRecord) error {
+			target.Replies = append(target.Replies, tx.ID)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if meta.Edits != "" {
+		err = updateReference(state, meta.Edits, func(target *MessageRecord) error {
+			err := checkOwnMessage(state, tx, target)
+			if err != nil {
+				return err
+			}
+
+			target.Edits = append(target.Edits, tx.ID)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if meta.Deletes != "" {
+		err = updateReference(state, meta.Deletes, func(target *MessageRecord) error {
+			err := checkOwnMessage(state, tx, target)
+			if err != nil {
+				return err
+			}
+
+			target.DeletedBy = tx.ID
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return state.putValue(MessageStatePrefix+tx.ID, record)
+}
+
+// updateReference изменяет индекс сообщения id, на которое ссылается новое сообщение
+func updateReference(state *stateBatch, id string, update func(target *MessageRecord) error) error {
+	target, err := getMessage(state, id)
+	if err != nil {
+		return err
+	}
+
+	err = update(target)
+	if err != nil {
+		return err
+	}
+
+	return state.putValue(MessageStatePrefix+id, target)
+}
+
+// checkOwnMessage проверяет, что отправитель правит или удаляет свое действующее
+// исходное сообщение того же типа
+func checkOwnMessage(state *stateBatch, tx *transaction.Transaction, target *MessageRecord) error {
+	owned, err := ownedBy(state, target.Sender, tx.Sender)
+	if err != nil {
+		return err
+	}
+
+	if !owned {
+		return fmt.Errorf("%w: %s", ErrNotMessageSender, target.ID)
+	}
+
+	if target.DeletedBy != "" {
+		return fmt.Errorf("%w: %s", ErrMessageDeleted, target.ID)
+	}
+
+	if target.Type != tx.Type {
+		return fmt.Errorf("%w: %s has a different message type", ErrInvalidReference, target.ID)
+	}
+
+	// Правки и удаления ссылаются на исходное сообщение, а не на его версию
+	if target.EditOf != "" {
+		return fmt.Errorf("%w: %s is an edit of %s", ErrInvalidReference, target.ID, target.EditOf)
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package blockchain
+
+import (
+	"blockchainStorage/internal/transaction"
+	"crypto/ecdsa"
+	"errors"
+	"testing"
+)
+
+func newThreadMessage(t *testing.T, privateKey *ecdsa.PrivateKey, id string, text string, meta transaction.MessageMeta, sequence uint64) *transaction.Transaction {
+	tx := &transaction.Transaction{
+		ID: id,
+		Outputs: []transaction.MessageOutput{
+			{EncryptedData: []byte(text), Recipient: testMinerAddress},
+		},
+	}
+
+	err := tx.SetMessageMeta(meta)
+	if err != nil {
+		t.Fatalf("failed to set message meta: %v", err)
+	}
+
+	return signTx(t, tx, privateKey, sequence)
+}
+
+func TestConversationRepliesEditsAndDeletes(t *testing.T) {
+	dbStorage := NewMockDbStorage()
+	bc, err := NewBlockchain(1, dbStorage)
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+
+	pool := &testPool{}
+	bc.SetTxPool(pool)
+	alice, bob := newTestKey(t), newTestKey(t)
+
+	root := newThreadMessage(t, alice, "root", "hello", transaction.MessageMeta{}, 1)
+	mineTransactions(t, bc, pool, root)
+
+	reply := newThreadMessage(t, bob, "reply", "hi", transaction.MessageMeta{ReplyTo: "root"}, 1)
+	nested := newThreadMessage(t, alice, "nested", "how are you?", transaction.MessageMeta{ReplyTo: "reply"}, 2)
+	mineTransactions(t, bc, pool, reply, nested)
+
+	// Правка и удаление чужого сообщения отклоняются
+	forgedEdit := newThreadMessage(t, bob, "forged", "hacked", transaction.MessageMeta{Edits: "root"}, 2)
+	err = bc.ValidateTransaction(forgedEdit)
+	if !errors.Is(err, ErrNotMessageSender) {
+		t.Errorf("expected ErrNotMessageSender, got %v", err)
+	}
+
+	forgedDelete := newThreadMessage(t, bob, "forged", "", transaction.MessageMeta{Deletes: "root"}, 2)
+	err = bc.ValidateTransaction(forgedDelete)
+	if !errors.Is(err, ErrNotMessageSender) {
+		t.Errorf("expected ErrNotMessageSender, got %v", err)
+	}
+
+	missing := newThreadMessage(t, bob, "missing", "?", transaction.MessageMeta{ReplyTo: "unknown"}, 2)
+	err = bc.ValidateTransaction(missing)
+	if !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("expected ErrMessageNotFound, got %v", err)
+	}
+
+	// Алиса дважды правит исходное сообщение, Боб удаляет свой ответ
+	edit1 := newThreadMessage(t, alice, "edit1", "hello!", transaction.MessageMeta{Edits: "root"}, 3)
+	edit2 := newThreadMessage(t, alice, "edit2", "hello everyone", transaction.MessageMeta{Edits: "root"}, 4)
+	deletion := newThreadMessage(t, bob, "delete", "", transaction.MessageMeta{Deletes: "reply"}, 2)
+	mineTransactions(t, bc, pool, edit1, edit2, deletion)
+
+	// Правка правки отклоняется: правки ссылаются на исходное сообщение
+	editOfEdit := newThreadMessage(t, alice, "edit3", "again", transaction.MessageMeta{Edits: "edit1"}, 5)
+	err = bc.ValidateTransaction(editOfEdit)
+	if !errors.Is(err, ErrInvalidReference) {
+		t.Errorf("expected ErrInvalidReference, got %v", err)
+	}
+
+	// Удаленное сообщение нельзя править
+	editDeleted := newThreadMessage(t, bob, "edit4", "back", transaction.MessageMeta{Edits: "reply"}, 3)
+	err = bc.ValidateTransaction(editDeleted)
+	if !errors.Is(err, ErrMessageDeleted) {
+		t.Errorf("expected ErrMessageDeleted, got %v", err)
+	}
+
+	views, err := bc.Conversation("root")
+	if err != nil {
+		t.Fatalf("failed to build conversation: %v", err)
+	}
+
+	if len(views) != 3 {
+		t.Fatalf("expected 3 messages in conversation, got %d", len(views))
+	}
+
+	if views[0].ID != "root" || !views[0].Edited || views[0].Latest.ID != "edit2" {
+		t.Errorf("expected root to resolve to edit2, got %+v", views[0])
+	}
+	if string(views[0].Latest.Outputs[0].EncryptedData) != "hello everyone" {
+		t.Errorf("unexpected latest content: %s", views[0].Latest.Outputs[0].EncryptedData)
+	}
+
+	if views[1].ID != "reply" || !views[1].Deleted || views[1].Latest != nil || views[1].Depth != 1 {
+		t.Errorf("expected deleted reply, got %+v", views[1])
+	}
+
+	if views[2].ID != "nested" || views[2].Depth != 2 || views[2].ReplyTo != "reply" {
+		t.Errorf("expected nested reply, got %+v", views[2])
+	}
+
+	// Повторное использование идентификатора сообщения отклоняется
+	duplicate := newThreadMessage(t, bob, "root", "again", transaction.MessageMeta{}, 3)
+	err = bc.ValidateTransaction(duplicate)
+	if !errors.Is(err, ErrDuplicateMessage) {
+		t.Errorf("expected ErrDuplicateMessage, got %v", err)
+	}
+}
+
+func TestEditAfterKeyRotation(t *testing.T) {
+	dbStorage := NewMockDbStorage()
+	bc, err := NewBlockchain(1, dbStorage)
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+
+	pool := &testPool{}
+	bc.SetTxPool(pool)
+	alice := newTestKey(t)
+	aliceAddress := registerIdentities(t, bc, pool, alice)[0]
+
+	first := newThreadMessage(t, alice, "first", "hello", transaction.MessageMeta{}, 2)
+	second := newThreadMessage(t, alice, "second", "typo", transaction.MessageMeta{}, 3)
+	mineTransactions(t, bc, pool, first, second)
+
+	// Новый ключ правит и удаляет сообщения, отправленные до замены ключа
+	newAlice, _ := rotateSigningKey(t, bc, pool, alice, aliceAddress, 4)
+	edit := newThreadMessage(t, newAlice, "edit", "hello!", transaction.MessageMeta{Edits: "first"}, 1)
+	deletion := newThreadMessage(t, newAlice, "delete", "", transaction.MessageMeta{Deletes: "second"}, 2)
+	mineTransactions(t, bc, pool, edit, deletion)
+
+	record, err := bc.LookupMessage("first")
+	if err != nil {
+		t.Fatalf("failed to lookup message: %v", err)
+	}
+	if len(record.Edits) != 1 || record.Edits[0] != "edit" {
+		t.Errorf("expected edit from rotated key, got %+v", record)
+	}
+
+	record, err = bc.LookupMessage("second")
+	if err != nil {
+		t.Fatalf("failed to lookup message: %v", err)
+	}
+	if record.DeletedBy != "delete" {
+		t.Errorf("expected deletion from rotated key, got %+v", record)
+	}
+}
@@ -0,0 +1,13 @@
+package blockchain
+
+import "blockchainStorage/internal/transaction"
+
+// MaxBlockTransactions максимальное количество транзакций из пула в одном блоке
+const MaxBlockTransactions = 500
+
+// TxPool источник неподтвержденных транзакций для майнинга (см. mempool.Mempool)
+type TxPool interface {
+	Add(tx *transaction.Transaction) error
+	Remove(ids ...string)
+	Pending(limit int) []*transaction.Transaction
+}
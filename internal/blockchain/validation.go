@@ -0,0 +1,239 @@
+package blockchain
+
+import (
+	"blockchainStorage/internal/transaction"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrStaleSequence транзакция повторяет уже подтвержденную последовательность отправителя
+	ErrStaleSequence = errors.New("transaction sequence already used")
+	// ErrFutureSequence транзакция опережает ожидаемую последовательность отправителя
+	ErrFutureSequence = errors.New("transaction sequence is ahead of expected")
+
+	ErrNegativeFee        = errors.New("transaction fee is negative")
+	ErrInsufficientFunds  = errors.New("insufficient balance to pay fee")
+	ErrUnexpectedCoinbase = errors.New("coinbase transaction is not allowed here")
+	ErrPrunedTransaction  = errors.New("pruned transaction cannot be confirmed")
+
+	ErrUnknownTransactionType = errors.New("unknown transaction type")
+)
+
+// NextSequence возвращает номер последовательности, ожидаемый от отправителя
+func (bc *Blockchain) NextSequence(sender string) (uint64, error) {
+	return nextSequence(newStateBatch(bc.db), sender)
+}
+
+// ValidateTransaction проверяет транзакцию перед добавлением в пул.
+// Транзакции с будущим номером последовательности допускаются, так как
+// отправитель может поставить в очередь несколько сообщений подряд.
+func (bc *Blockchain) ValidateTransaction(tx *transaction.Transaction) error {
+	height, err := bc.nextHeight()
+	if err != nil {
+		return err
+	}
+
+	return bc.checkTransaction(newStateBatch(bc.db), tx, height, false)
+}
+
+// applyTransaction проверяет транзакцию относительно состояния цепочки и
+// применяет ее изменения к state для блока с индексом height
+func (bc *Blockchain) applyTransaction(state *stateBatch, tx *transaction.Transaction, height int64) error {
+	return bc.checkTransaction(state, tx, height, true)
+}
+
+// checkTransaction выполняет общие проверки транзакции и изменения состояния
+// для ее типа. При exactSequence=false допускается будущий номер последовательности.
+func (bc *Blockchain) checkTransaction(state *stateBatch, tx *transaction.Transaction, height int64, exactSequence bool) error {
+	if tx.IsCoinbase() {
+		return ErrUnexpectedCoinbase
+	}
+
+	// Хэш очищенной транзакции не зависит от ее содержимого
+	if tx.Pruned() {
+		return ErrPrunedTransaction
+	}
+
+	err := tx.VerifySignature()
+	if err != nil {
+		return err
+	}
+
+	err = checkSenderKey(state, tx.Sender)
+	if err != nil {
+		return err
+	}
+
+	next, err := nextSequence(state, tx.Sender)
+	if err != nil {
+		return err
+	}
+
+	if tx.Sequence < next {
+		return fmt.Errorf("%w: expected %d, got %d", ErrStaleSequence, next, tx.Sequence)
+	}
+	if exactSequence && tx.Sequence > next {
+		return fmt.Errorf("%w: expected %d, got %d", ErrFutureSequence, next, tx.Sequence)
+	}
+
+	err = checkFee(state, tx)
+	if err != nil {
+		return err
+	}
+
+	err = addBalance(state, tx.Sender, -tx.Fee)
+	if err != nil {
+		return err
+	}
+
+	err = state.putValue(SequenceStatePrefix+tx.Sender, tx.Sequence)
+	if err != nil {
+		return err
+	}
+
+	if len(tx.Inputs) > 0 && tx.Type != transaction.TypeMessage {
+		return fmt.Errorf("%w: %s", ErrUnexpectedInputs, tx.Type)
+	}
+
+	switch tx.Type {
+	case transaction.TypeMessage:
+		err = checkRecipients(tx)
+		if err != nil {
+			return err
+		}
+
+		err = spendInputs(state, tx)
+		if err != nil {
+			return err
+		}
+
+		err = indexMessage(state, tx, height)
+		if err != nil {
+			return err
+		}
+		return addOutputs(state, tx, height)
+	case transaction.TypeRegisterKey:
+		return applyKeyRegistration(state, tx, height)
+	case transaction.TypeClaimName:
+		return applyNameClaim(state, tx, height)
+	case transaction.TypeRotateKey:
+		return applyKeyRotation(state, tx, height)
+	case transaction.TypeRevokeKey:
+		return applyKeyRevocation(state, tx, height)
+	case transaction.TypeCreateGroup, transaction.TypeInviteMember, transaction.TypeRemoveMember,
+		transaction.TypeLeaveGroup, transaction.TypeRekeyGroup:
+		return applyGroupAction(state, tx, height)
+	case transaction.TypeGroupMessage:
+		err = checkGroupMessage(state, tx)
+		if err != nil {
+			return err
+		}
+		return indexMessage(state, tx, height)
+	case transaction.TypeCreateChannel, transaction.TypeSetChannelTopic, transaction.TypeShareChannelKey:
+		return applyChannelAction(state, tx, height)
+	case transaction.TypeChannelPost:
+		return applyChannelPost(state, tx, height)
+	case transaction.TypeReceipt:
+		return applyReceipt(state, tx, height)
+	case transaction.TypeAnchor:
+		return applyAnchor(state, tx, height)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownTransactionType, tx.Type)
+	}
+}
+
+// checkRecipients отклоняет сообщения с некорректными адресами получателей,
+// чтобы опечатка в адресе не отправляла сообщение в никуда
+func checkRecipients(tx *transaction.Transaction) error {
+	for i, output := range tx.Outputs {
+		err := transaction.ValidateRecipient(output.Recipient)
+		if err != nil {
+			return fmt.Errorf("invalid recipient of output %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// checkFee проверяет, что отправитель может оплатить комиссию транзакции
+func checkFee(state *stateBatch, tx *transaction.Transaction) error {
+	if tx.Fee < 0 {
+		return ErrNegativeFee
+	}
+
+	if tx.Fee == 0 {
+		return nil
+	}
+
+	balance, err := getBalance(state, tx.Sender)
+	if err != nil {
+		return err
+	}
+
+	if balance < tx.Fee {
+		return fmt.Errorf("%w: balance %d, fee %d", ErrInsufficientFunds, balance, tx.Fee)
+	}
+
+	return nil
+}
+
+// nextSequence возвращает следующий номер последовательности отправителя.
+// Первая транзакция отправителя имеет номер 1.
+func nextSequence(state *stateBatch, sender string) (uint64, error) {
+	var sequence uint64
+	_, err := state.getValue(SequenceStatePrefix+sender, &sequence)
+	if err != nil {
+		return 0, err
+	}
+
+	return sequence + 1, nil
+}
+
+// selectTransactions выбирает из пула транзакции, допустимые для нового блока,
+// и применяет их к state. Пул выдает транзакции в порядке приоритета, поэтому
+// при ограниченном размере блока в него попадают сообщения с большей комиссией.
+// Транзакции, которые уже никогда не станут допустимыми, удаляются из пула.
+func (bc *Blockchain) selectTransactions(state *stateBatch, height int64) []*transaction.Transaction {
+	if bc.pool == nil {
+		return nil
+	}
+
+	var selected []*transaction.Transaction
+	var rejected []string
+
+	// Транзакции одного отправителя могут прийти из пула не по порядку,
+	// поэтому отложенные транзакции проверяются повторно, пока есть прогресс
+	remaining := bc.pool.Pending(MaxBlockTransactions)
+	for len(remaining) > 0 && len(selected) < MaxBlockTransactions {
+		var deferred []*transaction.Transaction
+		for _, tx := range remaining {
+			if len(selected) >= MaxBlockTransactions {
+				break
+			}
+
+			txState := state.fork()
+			err := bc.applyTransaction(txState, tx, height)
+			if errors.Is(err, ErrFutureSequence) {
+				deferred = append(deferred, tx)
+				continue
+			}
+			if err != nil {
+				rejected = append(rejected, tx.ID)
+				continue
+			}
+
+			state.merge(txState)
+			selected = append(selected, tx)
+		}
+
+		if len(deferred) == len(remaining) {
+			break
+		}
+		remaining = deferred
+	}
+
+	bc.pool.Remove(rejected...)
+
+	return selected
+}
@@ -0,0 +1,312 @@
+package contacts
+
+import (
+	"blockchainStorage/internal/address"
+	"blockchainStorage/internal/inbox"
+	"blockchainStorage/internal/storage"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Namespace префикс контактов в storage.DataStore (см. DataStore.Namespace)
+const Namespace = "contacts_"
+
+var (
+	ErrContactNotFound = errors.New("contact not found")
+	ErrKeyMismatch     = errors.New("public key does not match contact address")
+)
+
+// MessageSource источник входящих сообщений (см. inbox.Inbox)
+type MessageSource interface {
+	Messages() ([]*inbox.Message, error)
+}
+
+// Contact локальная запись о собеседнике. Не участвует в консенсусе и не
+// покидает узел.
+type Contact struct {
+	Address  string
+	Nickname string `json:",omitempty"`
+	// Fingerprint отпечаток открытого ключа, сверенного с собеседником лично
+	Fingerprint string `json:",omitempty"`
+	Blocked     bool
+	// LastMessage идентификатор последнего сообщения переписки (см. inbox.Message.ID)
+	LastMessage  string `json:",omitempty"`
+	LastActivity int64
+	AddedAt      int64
+}
+
+// Conversation переписка с собеседником по данным inbox и книги контактов
+type Conversation struct {
+	Peer string
+	// Contact запись о собеседнике, nil если его нет в книге контактов
+	Contact      *Contact
+	LastMessage  string
+	LastActivity int64
+	Messages     int
+	Unread       int
+}
+
+// Book книга контактов, хранящая записи по адресу собеседника
+type Book struct {
+	mu sync.Mutex
+	kv storage.KeyLister
+}
+
+func New(kv storage.KeyLister) *Book {
+	return &Book{kv: kv}
+}
+
+// Fingerprint вычисляет отпечаток открытого ключа для сверки вне сети:
+// SHA-256 ключа группами по четыре шестнадцатеричных символа
+func Fingerprint(publicKey []byte) string {
+	hash := sha256.Sum256(publicKey)
+	encoded := strings.ToUpper(hex.EncodeToString(hash[:]))
+
+	groups := make([]string, 0, len(encoded)/4)
+	for i := 0; i < len(encoded); i += 4 {
+		groups = append(groups, encoded[i:i+4])
+	}
+
+	return strings.Join(groups, " ")
+}
+
+// Get возвращает контакт по адресу
+func (b *Book) Get(addr string) (*Contact, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.load(addr)
+}
+
+// List возвращает все контакты в порядке адресов
+func (b *Book) List() ([]*Contact, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	keys, err := b.kv.Keys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list contacts: %w", err)
+	}
+
+	contacts := make([]*Contact, 0, len(keys))
+	for _, key := range keys {
+		contact, err := b.load(key)
+		if err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, contact)
+	}
+
+	return contacts, nil
+}
+
+// SetNickname задает имя контакта, добавляя его в книгу при необходимости
+func (b *Book) SetNickname(addr string, nickname string) error {
+	return b.update(addr, func(contact *Contact) {
+		contact.Nickname = nickname
+	})
+}
+
+// Verify сохраняет отпечаток ключа, сверенного с собеседником. Ключ должен
+// соответствовать адресу контакта.
+func (b *Book) Verify(addr string, publicKey []byte) error {
+	if !address.MatchesPublicKey(addr, publicKey) {
+		return fmt.Errorf("%w: %s", ErrKeyMismatch, addr)
+	}
+
+	return b.update(addr, func(contact *Contact) {
+		contact.Fingerprint = Fingerprint(publicKey)
+	})
+}
+
+// Verified проверяет, что publicKey совпадает со сверенным ключом контакта
+func (b *Book) Verified(addr string, publicKey []byte) (bool, error) {
+	contact, err := b.Get(addr)
+	if errors.Is(err, ErrContactNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return contact.Fingerprint != "" && contact.Fingerprint == Fingerprint(publicKey), nil
+}
+
+// Block блокирует собеседника: его переписка не показывается в Conversations
+func (b *Book) Block(addr string) error {
+	return b.update(addr, func(contact *Contact) {
+		contact.Blocked = true
+	})
+}
+
+// Unblock снимает блокировку собеседника
+func (b *Book) Unblock(addr string) error {
+	return b.update(addr, func(contact *Contact) {
+		contact.Blocked = false
+	})
+}
+
+// Blocked проверяет, заблокирован ли собеседник
+func (b *Book) Blocked(addr string) (bool, error) {
+	contact, err := b.Get(addr)
+	if errors.Is(err, ErrContactNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return contact.Blocked, nil
+}
+
+// Touch обновляет указатель на последнее сообщение переписки с контактом,
+// например после отправки ему сообщения. Более старые сообщения и адреса вне
+// книги контактов пропускаются.
+func (b *Book) Touch(addr string, messageID string, timestamp int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	contact, err := b.load(addr)
+	if errors.Is(err, ErrContactNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if timestamp <= contact.LastActivity {
+		return nil
+	}
+
+	contact.LastMessage = messageID
+	contact.LastActivity = timestamp
+	return b.save(contact)
+}
+
+// Remove удаляет контакт из книги
+func (b *Book) Remove(addr string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	err := b.kv.Delete(addr)
+	if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		return fmt.Errorf("failed to delete contact %s: %w", addr, err)
+	}
+
+	return nil
+}
+
+// Conversations группирует входящие сообщения по отправителю, дополняет их
+// последними сообщениями контактов и возвращает переписки, начиная с самой
+// недавней. Переписки с заблокированными собеседниками пропускаются.
+func (b *Book) Conversations(source MessageSource) ([]*Conversation, error) {
+	messages, err := source.Messages()
+	if err != nil {
+		return nil, err
+	}
+
+	contacts, err := b.List()
+	if err != nil {
+		return nil, err
+	}
+
+	conversations := make(map[string]*Conversation)
+	for _, contact := range contacts {
+		conversations[contact.Address] = &Conversation{
+			Peer:         contact.Address,
+			Contact:      contact,
+			LastMessage:  contact.LastMessage,
+			LastActivity: contact.LastActivity,
+		}
+	}
+
+	for _, message := range messages {
+		if message.Sender == "" {
+			continue
+		}
+
+		conversation, exists := conversations[message.Sender]
+		if !exists {
+			conversation = &Conversation{Peer: message.Sender}
+			conversations[message.Sender] = conversation
+		}
+
+		conversation.Messages++
+		if !message.Read {
+			conversation.Unread++
+		}
+
+		if message.Timestamp > conversation.LastActivity {
+			conversation.LastMessage = message.ID
+			conversation.LastActivity = message.Timestamp
+		}
+	}
+
+	result := make([]*Conversation, 0, len(conversations))
+	for _, conversation := range conversations {
+		if conversation.LastMessage == "" {
+			continue
+		}
+		if conversation.Contact != nil && conversation.Contact.Blocked {
+			continue
+		}
+		result = append(result, conversation)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].LastActivity != result[j].LastActivity {
+			return result[i].LastActivity > result[j].LastActivity
+		}
+		return result[i].Peer < result[j].Peer
+	})
+
+	return result, nil
+}
+
+// update изменяет контакт, добавляя его в книгу, если его еще нет
+func (b *Book) update(addr string, change func(contact *Contact)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	contact, err := b.load(addr)
+	if errors.Is(err, ErrContactNotFound) {
+		err = address.Validate(addr)
+		if err != nil {
+			return err
+		}
+		contact = &Contact{Address: addr, AddedAt: time.Now().UnixNano()}
+	} else if err != nil {
+		return err
+	}
+
+	change(contact)
+	return b.save(contact)
+}
+
+func (b *Book) load(addr string) (*Contact, error) {
+	var contact Contact
+	_, err := b.kv.Get(addr, &contact)
+	if err != nil {
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrContactNotFound, addr)
+		}
+		return nil, fmt.Errorf("failed to load contact %s: %w", addr, err)
+	}
+
+	return &contact, nil
+}
+
+func (b *Book) save(contact *Contact) error {
+	err := b.kv.Put(contact.Address, contact)
+	if err != nil {
+		return fmt.Errorf("failed to save contact %s: %w", contact.Address, err)
+	}
+
+	return nil
+}
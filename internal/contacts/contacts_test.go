@@ -0,0 +1,121 @@
+package contacts
+
+import (
+	"blockchainStorage/internal/identity"
+	"blockchainStorage/internal/inbox"
+	"blockchainStorage/internal/storage"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// messageList входящие сообщения без сканирования блоков
+type messageList []*inbox.Message
+
+func (l messageList) Messages() ([]*inbox.Message, error) {
+	return l, nil
+}
+
+func TestBook_Contacts(t *testing.T) {
+	alice, err := identity.Generate()
+	require.NoError(t, err)
+	bob, err := identity.Generate()
+	require.NoError(t, err)
+
+	book := New(storage.NewMemoryStore())
+
+	_, err = book.Get(alice.Address())
+	assert.ErrorIs(t, err, ErrContactNotFound)
+	assert.Error(t, book.SetNickname("not an address", "nobody"))
+
+	require.NoError(t, book.SetNickname(alice.Address(), "Alice"))
+	contact, err := book.Get(alice.Address())
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", contact.Nickname)
+	assert.NotZero(t, contact.AddedAt)
+
+	// Сверяется только ключ, из которого получен адрес контакта
+	assert.ErrorIs(t, book.Verify(alice.Address(), bob.PublicKey()), ErrKeyMismatch)
+	require.NoError(t, book.Verify(alice.Address(), alice.PublicKey()))
+
+	verified, err := book.Verified(alice.Address(), alice.PublicKey())
+	require.NoError(t, err)
+	assert.True(t, verified)
+	verified, err = book.Verified(bob.Address(), bob.PublicKey())
+	require.NoError(t, err)
+	assert.False(t, verified)
+
+	contact, err = book.Get(alice.Address())
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", contact.Nickname, "verification keeps other fields")
+	assert.Equal(t, Fingerprint(alice.PublicKey()), contact.Fingerprint)
+
+	require.NoError(t, book.Block(bob.Address()))
+	blocked, err := book.Blocked(bob.Address())
+	require.NoError(t, err)
+	assert.True(t, blocked)
+
+	require.NoError(t, book.Unblock(bob.Address()))
+	blocked, err = book.Blocked(bob.Address())
+	require.NoError(t, err)
+	assert.False(t, blocked)
+
+	contacts, err := book.List()
+	require.NoError(t, err)
+	assert.Len(t, contacts, 2)
+
+	require.NoError(t, book.Remove(bob.Address()))
+	contacts, err = book.List()
+	require.NoError(t, err)
+	require.Len(t, contacts, 1)
+	assert.Equal(t, alice.Address(), contacts[0].Address)
+}
+
+func TestBook_Conversations(t *testing.T) {
+	alice, err := identity.Generate()
+	require.NoError(t, err)
+	bob, err := identity.Generate()
+	require.NoError(t, err)
+	carol, err := identity.Generate()
+	require.NoError(t, err)
+	mallory, err := identity.Generate()
+	require.NoError(t, err)
+
+	book := New(storage.NewMemoryStore())
+	require.NoError(t, book.SetNickname(alice.Address(), "Alice"))
+	require.NoError(t, book.SetNickname(carol.Address(), "Carol"))
+	require.NoError(t, book.Block(mallory.Address()))
+
+	messages := messageList{
+		{ID: "a:0", Sender: alice.Address(), Timestamp: 10, Read: true},
+		{ID: "b:0", Sender: bob.Address(), Timestamp: 20},
+		{ID: "a:1", Sender: alice.Address(), Timestamp: 30},
+		{ID: "m:0", Sender: mallory.Address(), Timestamp: 40},
+	}
+
+	// Отправленное carol сообщение учитывается через указатель контакта
+	require.NoError(t, book.Touch(carol.Address(), "c:0", 25))
+	require.NoError(t, book.Touch(carol.Address(), "old", 5))
+	require.NoError(t, book.Touch(bob.Address(), "ignored", 50))
+
+	conversations, err := book.Conversations(messages)
+	require.NoError(t, err)
+	require.Len(t, conversations, 3)
+
+	assert.Equal(t, alice.Address(), conversations[0].Peer)
+	assert.Equal(t, "a:1", conversations[0].LastMessage)
+	assert.Equal(t, 2, conversations[0].Messages)
+	assert.Equal(t, 1, conversations[0].Unread)
+	require.NotNil(t, conversations[0].Contact)
+	assert.Equal(t, "Alice", conversations[0].Contact.Nickname)
+
+	assert.Equal(t, carol.Address(), conversations[1].Peer)
+	assert.Equal(t, "c:0", conversations[1].LastMessage)
+	assert.Equal(t, 0, conversations[1].Messages)
+
+	// Собеседник вне книги контактов показывается без записи
+	assert.Equal(t, bob.Address(), conversations[2].Peer)
+	assert.Nil(t, conversations[2].Contact)
+	assert.Equal(t, "b:0", conversations[2].LastMessage)
+}
@@ -0,0 +1,257 @@
+package delivery
+
+import (
+	"blockchainStorage/internal/blockchain"
+	"blockchainStorage/internal/storage"
+	"blockchainStorage/internal/transaction"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// Namespace префикс пакетов в storage.DataStore (см. DataStore.Namespace)
+	Namespace = "delivery_"
+
+	// PendingKey хэши доставленных сообщений, еще не вошедших в пакет
+	PendingKey = "pending"
+	// BatchPrefix листья пакета по идентификатору транзакции закрепления
+	BatchPrefix = "batch_"
+	// LeafPrefix положение сообщения в пакете по хэшу транзакции
+	LeafPrefix = "leaf_"
+)
+
+var (
+	ErrNotAnchored    = errors.New("message is not anchored yet")
+	ErrAnchorMismatch = errors.New("message is not part of the anchored batch")
+)
+
+// AnchorLookup источник закрепленных пакетов (см. blockchain.Blockchain)
+type AnchorLookup interface {
+	LookupAnchor(txID string) (*blockchain.AnchorRecord, error)
+}
+
+// Proof доказательство того, что сообщение входит в закрепленный пакет
+type Proof struct {
+	AnchorTx string
+	Index    int
+	Count    int
+	Path     [][]byte
+}
+
+type leafPosition struct {
+	AnchorTx string
+	Index    int
+}
+
+// Batcher накапливает хэши сообщений, доставленных напрямую, и объединяет их в
+// пакеты, корень которых закрепляется в блокчейне транзакцией transaction.TypeAnchor
+type Batcher struct {
+	mu      sync.Mutex
+	flushMu sync.Mutex
+	kv      storage.KeyValueStore
+}
+
+func NewBatcher(kv storage.KeyValueStore) *Batcher {
+	return &Batcher{kv: kv}
+}
+
+// Add добавляет сообщение в следующий пакет. Порядок добавления становится
+// порядком листьев дерева. Повторно доставленное сообщение не добавляется.
+func (b *Batcher) Add(tx *transaction.Transaction) error {
+	leaf, err := tx.Hash()
+	if err != nil {
+		return fmt.Errorf("failed to hash transaction %s: %w", tx.ID, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pending, err := b.pending()
+	if err != nil {
+		return err
+	}
+
+	for _, queued := range pending {
+		if bytes.Equal(queued, leaf) {
+			return nil
+		}
+	}
+
+	var position leafPosition
+	_, err = b.kv.Get(LeafPrefix+hex.EncodeToString(leaf), &position)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, storage.ErrKeyNotFound) {
+		return fmt.Errorf("failed to load batch index: %w", err)
+	}
+
+	err = b.kv.Put(PendingKey, append(pending, leaf))
+	if err != nil {
+		return fmt.Errorf("failed to save pending messages: %w", err)
+	}
+
+	return nil
+}
+
+// Flush объединяет накопленные сообщения в пакет, передает транзакцию
+// закрепления его корня в submit и возвращает ее, nil если сообщений нет.
+// submit подписывает транзакцию и отправляет ее в пул. Пока submit не
+// выполнится успешно, сообщения остаются в очереди и войдут в следующий пакет.
+func (b *Batcher) Flush(submit func(tx *transaction.Transaction) error) (*transaction.Transaction, error) {
+	// Пакеты формируются по одному, а Add не ждет отправки транзакции
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	leaves, err := b.pending()
+	b.mu.Unlock()
+	if err != nil || len(leaves) == 0 {
+		return nil, err
+	}
+
+	tx, err := transaction.NewAnchorTransaction(transaction.Anchor{
+		Root:  MerkleRoot(leaves),
+		Count: len(leaves),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = submit(tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit anchor %s: %w", tx.ID, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	err = b.kv.Put(BatchPrefix+tx.ID, leaves)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save batch: %w", err)
+	}
+
+	for i, leaf := range leaves {
+		err = b.kv.Put(LeafPrefix+hex.EncodeToString(leaf), leafPosition{AnchorTx: tx.ID, Index: i})
+		if err != nil {
+			return nil, fmt.Errorf("failed to save batch index: %w", err)
+		}
+	}
+
+	// Сообщения, добавленные во время отправки, остаются в очереди
+	pending, err := b.pending()
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.kv.Put(PendingKey, pending[len(leaves):])
+	if err != nil {
+		return nil, fmt.Errorf("failed to save pending messages: %w", err)
+	}
+
+	return tx, nil
+}
+
+// Run закрепляет накопленные сообщения каждые interval, пока не закрыт done.
+// submit подписывает транзакцию закрепления и отправляет ее в пул.
+func (b *Batcher) Run(interval time.Duration, submit func(tx *transaction.Transaction) error, onError func(err error), done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			_, err := b.Flush(submit)
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Proof возвращает доказательство вхождения сообщения в пакет
+func (b *Batcher) Proof(tx *transaction.Transaction) (*Proof, error) {
+	leaf, err := tx.Hash()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash transaction %s: %w", tx.ID, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var position leafPosition
+	_, err = b.kv.Get(LeafPrefix+hex.EncodeToString(leaf), &position)
+	if err != nil {
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrNotAnchored, tx.ID)
+		}
+		return nil, fmt.Errorf("failed to load batch index: %w", err)
+	}
+
+	var leaves [][]byte
+	_, err = b.kv.Get(BatchPrefix+position.AnchorTx, &leaves)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load batch %s: %w", position.AnchorTx, err)
+	}
+
+	path, err := MerkleProof(leaves, position.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Proof{
+		AnchorTx: position.AnchorTx,
+		Index:    position.Index,
+		Count:    len(leaves),
+		Path:     path,
+	}, nil
+}
+
+// VerifyProof проверяет, что сообщение входит в пакет, закрепленный в блокчейне
+// его отправителем или одним из узлов anchorers, и возвращает запись закрепления
+// с индексом блока. Узлы закрепляют принятые ими сообщения своим ключом (см.
+// HandleDeliver), поэтому проверяющий перечисляет узлы, которым доверяет,
+// например узел, доставивший ему сообщение.
+func VerifyProof(anchors AnchorLookup, tx *transaction.Transaction, proof *Proof, anchorers ...string) (*blockchain.AnchorRecord, error) {
+	anchor, err := anchors.LookupAnchor(proof.AnchorTx)
+	if err != nil {
+		return nil, err
+	}
+
+	trusted := anchor.Sender == tx.Sender
+	for _, anchorer := range anchorers {
+		trusted = trusted || anchor.Sender == anchorer
+	}
+
+	if !trusted || anchor.Count != proof.Count {
+		return nil, fmt.Errorf("%w: %s", ErrAnchorMismatch, proof.AnchorTx)
+	}
+
+	leaf, err := tx.Hash()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash transaction %s: %w", tx.ID, err)
+	}
+
+	err = VerifyMerkleProof(anchor.Root, leaf, proof.Index, proof.Count, proof.Path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAnchorMismatch, err)
+	}
+
+	return anchor, nil
+}
+
+func (b *Batcher) pending() ([][]byte, error) {
+	var pending [][]byte
+	_, err := b.kv.Get(PendingKey, &pending)
+	if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		return nil, fmt.Errorf("failed to load pending messages: %w", err)
+	}
+
+	return pending, nil
+}
@@ -0,0 +1,203 @@
+package delivery
+
+import (
+	"blockchainStorage/internal/blockchain"
+	"blockchainStorage/internal/network"
+	"blockchainStorage/internal/transaction"
+	"errors"
+	"fmt"
+	"net"
+)
+
+const (
+	// CommandDeliver прямая доставка сообщения, Data содержит подписанную транзакцию
+	CommandDeliver = "deliver"
+	// CommandDelivered ответ узла, доставившего сообщение локальным получателям
+	CommandDelivered = "delivered"
+	// CommandNotLocal ответ узла, на котором нет получателей сообщения
+	CommandNotLocal = "notlocal"
+	// CommandRejected ответ узла, отклонившего сообщение
+	CommandRejected = "rejected"
+)
+
+var (
+	ErrNotDelivered    = errors.New("message was not delivered to any node")
+	ErrInvalidEnvelope = errors.New("invalid direct message")
+	ErrNotAdmitted     = errors.New("direct message has no stamp and its sender has no registered key")
+)
+
+// KeyLookup реестр ключей пользователей (см. blockchain.Blockchain)
+type KeyLookup interface {
+	LookupKeys(address string) (*blockchain.KeyRecord, error)
+}
+
+// Admission условия приема сообщений, еще не подтвержденных блоком. Такие
+// сообщения ничего не стоят отправителю, поэтому узел принимает их только от
+// отправителей с ключом в блокчейне или со штампом, иначе ключи, созданные на
+// один раз, заполнили бы очереди получателей.
+type Admission struct {
+	// Keys реестр ключей, nil если регистрация ключа не учитывается
+	Keys KeyLookup
+	// StampDifficulty сложность штампа для отправителей без ключа, 0 - штамп не принимается
+	StampDifficulty int
+}
+
+// Check проверяет, что сообщение можно принять на хранение до подтверждения в блоке
+func (a *Admission) Check(tx *transaction.Transaction) error {
+	if a.StampDifficulty > 0 && blockchain.VerifyTransactionStamp(tx, a.StampDifficulty) {
+		return nil
+	}
+
+	if a.Keys != nil {
+		record, err := a.Keys.LookupKeys(tx.Sender)
+		if err == nil && !record.Retired() {
+			return nil
+		}
+		if err != nil && !errors.Is(err, blockchain.ErrKeyNotRegistered) {
+			return fmt.Errorf("failed to look up sender keys: %w", err)
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrNotAdmitted, tx.Sender)
+}
+
+// Receiver принимает сообщения, доставленные напрямую (см. inbox.Inbox) и
+// возвращает количество выходов, адресованных локальным получателям
+type Receiver interface {
+	Deliver(tx *transaction.Transaction) (int, error)
+}
+
+// Receivers передает сообщение нескольким получателям на одном узле, например
+// inbox.Inbox и mailbox.Mailbox, и возвращает сумму их результатов
+type Receivers []Receiver
+
+func (r Receivers) Deliver(tx *transaction.Transaction) (int, error) {
+	total := 0
+	for _, receiver := range r {
+		count, err := receiver.Deliver(tx)
+		if err != nil {
+			return total, err
+		}
+		total += count
+	}
+
+	return total, nil
+}
+
+// Send доставляет подписанное сообщение напрямую узлам сети, не дожидаясь
+// майнинга, и возвращает количество узлов, принявших его для своих получателей.
+// Принявшие узлы закрепляют сообщение в блокчейне (см. HandleDeliver).
+// Отправитель без ключа в блокчейне должен приложить штамп (см. Admission).
+func Send(n *network.Network, tx *transaction.Transaction) (int, error) {
+	err := CheckEnvelope(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := tx.Serialize()
+	if err != nil {
+		return 0, fmt.Errorf("failed to serialize transaction: %w", err)
+	}
+
+	delivered := 0
+	var lastErr error
+	for _, node := range n.NodeList {
+		response, err := n.Request(node.Address, CommandDeliver, data)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if response.Command == CommandDelivered {
+			delivered++
+		}
+	}
+
+	if delivered == 0 {
+		if lastErr != nil {
+			return 0, fmt.Errorf("%w: %v", ErrNotDelivered, lastErr)
+		}
+		return 0, ErrNotDelivered
+	}
+
+	return delivered, nil
+}
+
+// HandleDeliver проверяет сообщение, полученное командой deliver, и условия
+// admission, передает его receiver и отвечает отправителю результатом доставки.
+// Сообщение для локальных получателей добавляется в batcher для закрепления в
+// блокчейне, nil если узел не закрепляет сообщения.
+func HandleDeliver(receiver Receiver, admission *Admission, batcher *Batcher, msg *network.Message, conn net.Conn) error {
+	tx, err := transaction.DeserializeTransaction(msg.Data)
+	if err != nil {
+		replyErr := network.Reply(conn, CommandRejected, nil)
+		if replyErr != nil {
+			return replyErr
+		}
+		return fmt.Errorf("failed to deserialize direct message: %w", err)
+	}
+
+	err = CheckEnvelope(tx)
+	if err == nil {
+		err = admission.Check(tx)
+	}
+	if err != nil {
+		replyErr := network.Reply(conn, CommandRejected, []byte(tx.ID))
+		if replyErr != nil {
+			return replyErr
+		}
+		return err
+	}
+
+	count, err := receiver.Deliver(tx)
+	if err != nil {
+		// Ошибка хранилища не раскрывается отправителю
+		replyErr := network.Reply(conn, CommandRejected, []byte(tx.ID))
+		if replyErr != nil {
+			return replyErr
+		}
+		return fmt.Errorf("failed to deliver message %s: %w", tx.ID, err)
+	}
+
+	if count == 0 {
+		return network.Reply(conn, CommandNotLocal, []byte(tx.ID))
+	}
+
+	err = network.Reply(conn, CommandDelivered, []byte(tx.ID))
+	if err != nil {
+		return err
+	}
+
+	if batcher != nil {
+		err = batcher.Add(tx)
+		if err != nil {
+			return fmt.Errorf("failed to queue message %s for anchoring: %w", tx.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// CheckEnvelope проверяет, что транзакция является подписанным сообщением,
+// которое можно доставить напрямую
+func CheckEnvelope(tx *transaction.Transaction) error {
+	if tx.Type != transaction.TypeMessage {
+		return fmt.Errorf("%w: transaction type %q", ErrInvalidEnvelope, tx.Type)
+	}
+
+	if tx.Pruned() || len(tx.Outputs) == 0 {
+		return fmt.Errorf("%w: no message content", ErrInvalidEnvelope)
+	}
+
+	meta, err := tx.MessageMeta()
+	if err != nil {
+		return err
+	}
+
+	err = meta.Validate()
+	if err != nil {
+		return err
+	}
+
+	return tx.VerifySignature()
+}
@@ -0,0 +1,302 @@
+package delivery
+
+import (
+	"blockchainStorage/common"
+	"blockchainStorage/internal/blockchain"
+	"blockchainStorage/internal/identity"
+	"blockchainStorage/internal/inbox"
+	"blockchainStorage/internal/mempool"
+	"blockchainStorage/internal/network"
+	"blockchainStorage/internal/storage"
+	"blockchainStorage/internal/transaction"
+	"crypto"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type identityResolver map[string]*identity.Identity
+
+func (r identityResolver) ResolveEncryptionKey(recipient string) (crypto.PublicKey, error) {
+	return &r[recipient].PrivateKey().PublicKey, nil
+}
+
+// anchorMap закрепленные пакеты по идентификатору транзакции
+type anchorMap map[string]*blockchain.AnchorRecord
+
+func (m anchorMap) LookupAnchor(txID string) (*blockchain.AnchorRecord, error) {
+	record, exists := m[txID]
+	if !exists {
+		return nil, blockchain.ErrAnchorNotFound
+	}
+	return record, nil
+}
+
+// keyMap ключи пользователей по адресу
+type keyMap map[string]*blockchain.KeyRecord
+
+func (m keyMap) LookupKeys(address string) (*blockchain.KeyRecord, error) {
+	record, exists := m[address]
+	if !exists {
+		return nil, blockchain.ErrKeyNotRegistered
+	}
+	return record, nil
+}
+
+func TestMerkleProof(t *testing.T) {
+	for count := 1; count <= 9; count++ {
+		leaves := make([][]byte, count)
+		for i := range leaves {
+			leaves[i] = []byte(fmt.Sprintf("leaf %d", i))
+		}
+		root := MerkleRoot(leaves)
+
+		for index := range leaves {
+			path, err := MerkleProof(leaves, index)
+			require.NoError(t, err)
+			assert.NoError(t, VerifyMerkleProof(root, leaves[index], index, count, path), "count %d, index %d", count, index)
+
+			// Доказательство не подходит к другому листу и другой позиции
+			assert.ErrorIs(t, VerifyMerkleProof(root, []byte("other"), index, count, path), ErrInvalidProof)
+			if count > 1 {
+				assert.Error(t, VerifyMerkleProof(root, leaves[index], (index+1)%count, count, path))
+			}
+		}
+	}
+
+	// Порядок листьев влияет на корень
+	assert.NotEqual(t, MerkleRoot([][]byte{[]byte("a"), []byte("b")}), MerkleRoot([][]byte{[]byte("b"), []byte("a")}))
+}
+
+func TestDirectDeliveryAndAnchor(t *testing.T) {
+	alice, err := identity.Generate()
+	require.NoError(t, err)
+	bob, err := identity.Generate()
+	require.NoError(t, err)
+
+	// Узел alice принимает сообщения напрямую в свой inbox
+	box := inbox.New(blockchain.NewMockDbStorage(), storage.NewMemoryStore())
+	box.AddKey(alice.Address(), alice)
+	keys := keyMap{}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+
+	server := &network.Network{}
+	go func() {
+		_ = server.StartServer(port, func(msg *network.Message, conn net.Conn) {
+			if msg.Command == CommandDeliver {
+				_ = HandleDeliver(box, &Admission{Keys: keys, StampDifficulty: 2}, nil, msg, conn)
+			}
+		})
+	}()
+
+	tx, err := transaction.NewTransactionWithResolver(nil, []transaction.MessageOutput{
+		{Recipient: alice.Address(), EncryptedData: []byte("instant")},
+	}, identityResolver{alice.Address(): alice})
+	require.NoError(t, err)
+	tx.Sender = bob.Address()
+	require.NoError(t, blockchain.StampTransaction(tx, 2))
+	require.NoError(t, tx.Sign(bob.PrivateKey()))
+
+	client := &network.Network{NodeList: []common.Node{{Address: fmt.Sprintf("127.0.0.1:%d", port)}}}
+
+	// Сервер запускается асинхронно
+	var delivered int
+	for attempt := 0; attempt < 50; attempt++ {
+		delivered, err = Send(client, tx)
+		if err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+
+	messages, err := box.Unread()
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.True(t, messages[0].Direct)
+	assert.Equal(t, []byte("instant"), messages[0].Content)
+	assert.Equal(t, bob.Address(), messages[0].Sender)
+
+	// Сообщение без штампа от отправителя без ключа в блокчейне не принимается
+	unstamped, err := transaction.NewTransactionWithResolver(nil, []transaction.MessageOutput{
+		{Recipient: alice.Address(), EncryptedData: []byte("spam")},
+	}, identityResolver{alice.Address(): alice})
+	require.NoError(t, err)
+	require.NoError(t, unstamped.Sign(bob.PrivateKey()))
+	_, err = Send(client, unstamped)
+	assert.ErrorIs(t, err, ErrNotDelivered)
+
+	// Отправителю с зарегистрированным ключом штамп не нужен
+	keys[bob.Address()] = &blockchain.KeyRecord{Address: bob.Address()}
+	delivered, err = Send(client, unstamped)
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+
+	// Неподписанное сообщение не доставляется
+	unsigned := *tx
+	unsigned.Signature = nil
+	_, err = Send(client, &unsigned)
+	assert.ErrorIs(t, err, transaction.ErrUnsigned)
+
+	// Отправитель закрепляет доставленные сообщения пакетом
+	batcher := NewBatcher(storage.NewMemoryStore())
+	other, err := transaction.NewTransactionWithResolver(nil, []transaction.MessageOutput{
+		{Recipient: alice.Address(), EncryptedData: []byte("second")},
+	}, identityResolver{alice.Address(): alice})
+	require.NoError(t, err)
+	require.NoError(t, other.Sign(bob.PrivateKey()))
+
+	require.NoError(t, batcher.Add(tx))
+	require.NoError(t, batcher.Add(other))
+
+	_, err = batcher.Proof(tx)
+	assert.ErrorIs(t, err, ErrNotAnchored)
+
+	// Пакет, который не удалось отправить в пул, не закрепляется, а сообщения
+	// остаются в очереди
+	rejected := errors.New("pool is unavailable")
+	_, err = batcher.Flush(func(*transaction.Transaction) error { return rejected })
+	assert.ErrorIs(t, err, rejected)
+
+	_, err = batcher.Proof(tx)
+	assert.ErrorIs(t, err, ErrNotAnchored)
+
+	var submitted []*transaction.Transaction
+	submit := func(anchorTx *transaction.Transaction) error {
+		submitted = append(submitted, anchorTx)
+		return nil
+	}
+
+	anchorTx, err := batcher.Flush(submit)
+	require.NoError(t, err)
+	require.NotNil(t, anchorTx)
+	require.Len(t, submitted, 1)
+	assert.Same(t, anchorTx, submitted[0])
+
+	empty, err := batcher.Flush(submit)
+	require.NoError(t, err)
+	assert.Nil(t, empty)
+	assert.Len(t, submitted, 1, "empty batch is not submitted")
+
+	anchor, err := anchorTx.Anchor()
+	require.NoError(t, err)
+	anchors := anchorMap{anchorTx.ID: {Anchor: *anchor, TxID: anchorTx.ID, Sender: bob.Address(), Height: 7}}
+
+	proof, err := batcher.Proof(other)
+	require.NoError(t, err)
+	assert.Equal(t, 1, proof.Index)
+
+	record, err := VerifyProof(anchors, other, proof)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), record.Height)
+
+	// Доказательство не подходит к другому сообщению
+	_, err = VerifyProof(anchors, tx, proof)
+	assert.ErrorIs(t, err, ErrAnchorMismatch)
+}
+
+func TestDeliveredMessagesAreAnchored(t *testing.T) {
+	alice, err := identity.Generate()
+	require.NoError(t, err)
+	bob, err := identity.Generate()
+	require.NoError(t, err)
+	node, err := identity.Generate()
+	require.NoError(t, err)
+
+	chain, err := blockchain.NewBlockchain(1, blockchain.NewMockDbStorage())
+	require.NoError(t, err)
+	pool := mempool.NewMempool(mempool.DefaultConfig())
+	pool.SetValidator(chain.ValidateTransaction)
+	chain.SetTxPool(pool)
+
+	// Узел принимает сообщения для alice и закрепляет их своим ключом
+	box := inbox.New(blockchain.NewMockDbStorage(), storage.NewMemoryStore())
+	box.AddKey(alice.Address(), alice)
+	batcher := NewBatcher(storage.NewMemoryStore())
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+
+	server := &network.Network{}
+	go func() {
+		_ = server.StartServer(port, func(msg *network.Message, conn net.Conn) {
+			if msg.Command == CommandDeliver {
+				_ = HandleDeliver(box, &Admission{StampDifficulty: 2}, batcher, msg, conn)
+			}
+		})
+	}()
+
+	tx, err := transaction.NewTransactionWithResolver(nil, []transaction.MessageOutput{
+		{Recipient: alice.Address(), EncryptedData: []byte("anchored")},
+	}, identityResolver{alice.Address(): alice})
+	require.NoError(t, err)
+	tx.Sender = bob.Address()
+	require.NoError(t, blockchain.StampTransaction(tx, 2))
+	require.NoError(t, tx.Sign(bob.PrivateKey()))
+
+	client := &network.Network{NodeList: []common.Node{{Address: fmt.Sprintf("127.0.0.1:%d", port)}}}
+
+	// Сервер запускается асинхронно
+	for attempt := 0; attempt < 50; attempt++ {
+		_, err = Send(client, tx)
+		if err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.NoError(t, err)
+
+	// Повторная доставка не добавляет сообщение в пакет второй раз
+	_, err = Send(client, tx)
+	require.NoError(t, err)
+
+	// Сообщение добавляется в пакет после ответа отправителю
+	var anchorTx *transaction.Transaction
+	submit := func(anchor *transaction.Transaction) error {
+		anchor.Sender = node.Address()
+		anchor.Sequence = 1
+		err := anchor.Sign(node.PrivateKey())
+		if err != nil {
+			return err
+		}
+		return pool.Add(anchor)
+	}
+	for attempt := 0; attempt < 50 && anchorTx == nil; attempt++ {
+		anchorTx, err = batcher.Flush(submit)
+		require.NoError(t, err)
+		if anchorTx == nil {
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	require.NotNil(t, anchorTx, "delivered message was not queued for anchoring")
+
+	anchor, err := anchorTx.Anchor()
+	require.NoError(t, err)
+	assert.Equal(t, 1, anchor.Count)
+
+	require.NoError(t, chain.AddBlock("Block Data", node.Address()))
+
+	proof, err := batcher.Proof(tx)
+	require.NoError(t, err)
+
+	// Закрепление узла принимается, если проверяющий доверяет этому узлу
+	record, err := VerifyProof(chain, tx, proof, node.Address())
+	require.NoError(t, err)
+	assert.Equal(t, node.Address(), record.Sender)
+	assert.Equal(t, anchorTx.ID, record.TxID)
+
+	_, err = VerifyProof(chain, tx, proof)
+	assert.ErrorIs(t, err, ErrAnchorMismatch)
+}
@@ -0,0 +1,121 @@
+package delivery
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+)
+
+// Префиксы хэшей листьев и узлов дерева не дают выдать внутренний узел за лист
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+var ErrInvalidProof = errors.New("invalid merkle proof")
+
+// MerkleRoot вычисляет корень дерева Меркла для листьев в заданном порядке.
+// Узел без пары переносится на следующий уровень без изменений.
+func MerkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		return nil
+	}
+
+	level := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = hashLeaf(leaf)
+	}
+
+	for len(level) > 1 {
+		level = nextLevel(level)
+	}
+
+	return level[0]
+}
+
+// MerkleProof возвращает хэши соседних узлов на пути от листа index к корню
+func MerkleProof(leaves [][]byte, index int) ([][]byte, error) {
+	if index < 0 || index >= len(leaves) {
+		return nil, ErrInvalidProof
+	}
+
+	level := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = hashLeaf(leaf)
+	}
+
+	var path [][]byte
+	for len(level) > 1 {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			path = append(path, level[sibling])
+		}
+
+		level = nextLevel(level)
+		index /= 2
+	}
+
+	return path, nil
+}
+
+// VerifyMerkleProof проверяет, что leaf является листом index дерева из count
+// листьев с корнем root
+func VerifyMerkleProof(root []byte, leaf []byte, index int, count int, path [][]byte) error {
+	if index < 0 || index >= count {
+		return ErrInvalidProof
+	}
+
+	hash := hashLeaf(leaf)
+	for width := count; width > 1; width = (width + 1) / 2 {
+		sibling := index ^ 1
+		if sibling < width {
+			if len(path) == 0 {
+				return ErrInvalidProof
+			}
+
+			if index%2 == 0 {
+				hash = hashNode(hash, path[0])
+			} else {
+				hash = hashNode(path[0], hash)
+			}
+			path = path[1:]
+		}
+
+		index /= 2
+	}
+
+	if len(path) != 0 || !bytes.Equal(hash, root) {
+		return ErrInvalidProof
+	}
+
+	return nil
+}
+
+func nextLevel(level [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		if i+1 == len(level) {
+			next = append(next, level[i])
+			continue
+		}
+
+		next = append(next, hashNode(level[i], level[i+1]))
+	}
+
+	return next
+}
+
+func hashLeaf(leaf []byte) []byte {
+	hash := sha256.Sum256(append([]byte{leafPrefix}, leaf...))
+	return hash[:]
+}
+
+func hashNode(left []byte, right []byte) []byte {
+	data := make([]byte, 0, 1+len(left)+len(right))
+	data = append(data, nodePrefix)
+	data = append(data, left...)
+	data = append(data, right...)
+
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
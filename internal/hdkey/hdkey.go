@@ -0,0 +1,212 @@
+package hdkey
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// HardenedOffset смещение индексов усиленного (hardened) вывода
+const HardenedOffset uint32 = 1 << 31
+
+// Curve кривая, для которой выводится дерево ключей (по SLIP-10)
+type Curve string
+
+const (
+	// CurveP256 ключи подписи ECDSA P-256
+	CurveP256 Curve = "Nist256p1 seed"
+	// CurveX25519 ключи X25519 для установления сессий
+	CurveX25519 Curve = "curve25519 seed"
+)
+
+const (
+	purpose  = 44
+	coinType = 7342
+
+	changeSigning = 0
+	changePrekey  = 1
+)
+
+var (
+	ErrInvalidSeed  = errors.New("seed must be 16-64 bytes long")
+	ErrNotHardened  = errors.New("only hardened derivation is supported")
+	ErrInvalidPath  = errors.New("invalid derivation path")
+	ErrInvalidCurve = errors.New("unsupported curve")
+)
+
+// Key узел дерева ключей: закрытый ключ и цепной код
+type Key struct {
+	curve     Curve
+	key       []byte
+	chainCode []byte
+}
+
+// NewMaster вычисляет корневой ключ дерева из seed
+func NewMaster(seed []byte, curve Curve) (*Key, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, ErrInvalidSeed
+	}
+	if curve != CurveP256 && curve != CurveX25519 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCurve, curve)
+	}
+
+	data := seed
+	for {
+		sum := hmacSHA512([]byte(curve), data)
+		key := &Key{curve: curve, key: sum[:32], chainCode: sum[32:]}
+		if key.valid() {
+			return key, nil
+		}
+
+		// Недопустимый для кривой скаляр: по SLIP-10 повторяем HMAC от результата
+		data = sum
+	}
+}
+
+// Child выводит дочерний ключ с усиленным индексом index (HardenedOffset уже должен быть прибавлен)
+func (k *Key) Child(index uint32) (*Key, error) {
+	if index < HardenedOffset {
+		return nil, fmt.Errorf("%w: index %d", ErrNotHardened, index)
+	}
+
+	data := make([]byte, 0, 37)
+	data = append(data, 0)
+	data = append(data, k.key...)
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	for {
+		sum := hmacSHA512(k.chainCode, data)
+		child := &Key{curve: k.curve, key: sum[:32], chainCode: sum[32:]}
+
+		if k.curve == CurveP256 {
+			// Для P-256 дочерний скаляр равен I_L + k_par mod n
+			n := elliptic.P256().Params().N
+			value := new(big.Int).SetBytes(sum[:32])
+			if value.Cmp(n) < 0 {
+				value.Add(value, new(big.Int).SetBytes(k.key))
+				value.Mod(value, n)
+				child.key = value.FillBytes(make([]byte, 32))
+			}
+		}
+
+		if child.valid() {
+			return child, nil
+		}
+
+		data = append([]byte{1}, sum[32:]...)
+		data = binary.BigEndian.AppendUint32(data, index)
+	}
+}
+
+// Derive выводит ключ по цепочке усиленных индексов
+func (k *Key) Derive(path []uint32) (*Key, error) {
+	current := k
+	for _, index := range path {
+		child, err := current.Child(index)
+		if err != nil {
+			return nil, err
+		}
+		current = child
+	}
+
+	return current, nil
+}
+
+// ECDSA возвращает ключ подписи P-256 узла
+func (k *Key) ECDSA() (*ecdsa.PrivateKey, error) {
+	if k.curve != CurveP256 {
+		return nil, fmt.Errorf("%w: %s is not a signing curve", ErrInvalidCurve, k.curve)
+	}
+
+	privateKey := &ecdsa.PrivateKey{D: new(big.Int).SetBytes(k.key)}
+	privateKey.Curve = elliptic.P256()
+	privateKey.X, privateKey.Y = privateKey.Curve.ScalarBaseMult(k.key)
+
+	return privateKey, nil
+}
+
+// X25519 возвращает ключ X25519 узла
+func (k *Key) X25519() (*ecdh.PrivateKey, error) {
+	if k.curve != CurveX25519 {
+		return nil, fmt.Errorf("%w: %s is not an X25519 curve", ErrInvalidCurve, k.curve)
+	}
+
+	privateKey, err := ecdh.X25519().NewPrivateKey(k.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create X25519 key: %w", err)
+	}
+
+	return privateKey, nil
+}
+
+// ChainCode возвращает цепной код узла
+func (k *Key) ChainCode() []byte {
+	return append([]byte(nil), k.chainCode...)
+}
+
+// valid проверяет, что ключ является допустимым скаляром кривой
+func (k *Key) valid() bool {
+	if k.curve != CurveP256 {
+		return true
+	}
+
+	value := new(big.Int).SetBytes(k.key)
+	return value.Sign() > 0 && value.Cmp(elliptic.P256().Params().N) < 0
+}
+
+// ParsePath разбирает путь вида m/44'/7342'/0'/0'. Все индексы должны быть усиленными.
+func ParsePath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidPath, path)
+	}
+
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		if !strings.HasSuffix(part, "'") {
+			return nil, fmt.Errorf("%w: %q", ErrNotHardened, part)
+		}
+
+		index, err := strconv.ParseUint(strings.TrimSuffix(part, "'"), 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidPath, part)
+		}
+
+		indexes = append(indexes, uint32(index)+HardenedOffset)
+	}
+
+	return indexes, nil
+}
+
+// SigningPath путь ключа подписи учетной записи account
+func SigningPath(account uint32) []uint32 {
+	return accountPath(account, changeSigning)
+}
+
+// PrekeyPath путь X25519 ключа учетной записи account
+func PrekeyPath(account uint32) []uint32 {
+	return accountPath(account, changePrekey)
+}
+
+func accountPath(account uint32, change uint32) []uint32 {
+	return []uint32{
+		purpose + HardenedOffset,
+		coinType + HardenedOffset,
+		account + HardenedOffset,
+		change + HardenedOffset,
+	}
+}
+
+func hmacSHA512(key []byte, data []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
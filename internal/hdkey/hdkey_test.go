@@ -0,0 +1,110 @@
+package hdkey
+
+import (
+	"blockchainStorage/internal/keystore"
+	"blockchainStorage/internal/mnemonic"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestDeriveP256Vector(t *testing.T) {
+	// Тестовый вектор SLIP-10 для кривой nist256p1
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+
+	master, err := NewMaster(seed, CurveP256)
+	if err != nil {
+		t.Fatalf("Ошибка вычисления корневого ключа: %v", err)
+	}
+
+	if fmt.Sprintf("%x", master.key) != "612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2" {
+		t.Errorf("Неверный корневой ключ: %x", master.key)
+	}
+
+	path, err := ParsePath("m/0'")
+	if err != nil {
+		t.Fatalf("Ошибка разбора пути: %v", err)
+	}
+
+	child, err := master.Derive(path)
+	if err != nil {
+		t.Fatalf("Ошибка вывода дочернего ключа: %v", err)
+	}
+
+	if fmt.Sprintf("%x", child.key) != "6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c" {
+		t.Errorf("Неверный дочерний ключ: %x", child.key)
+	}
+
+	_, err = master.Child(0)
+	if !errors.Is(err, ErrNotHardened) {
+		t.Errorf("Ожидалась ошибка ErrNotHardened, получено %v", err)
+	}
+
+	_, err = ParsePath("m/0")
+	if !errors.Is(err, ErrNotHardened) {
+		t.Errorf("Ожидалась ошибка ErrNotHardened для пути без усиления, получено %v", err)
+	}
+}
+
+func TestRestoreFromMnemonic(t *testing.T) {
+	phrase, err := mnemonic.New(128)
+	if err != nil {
+		t.Fatalf("Ошибка генерации фразы: %v", err)
+	}
+
+	first, err := FromMnemonic(phrase, "", 0)
+	if err != nil {
+		t.Fatalf("Ошибка вывода ключей: %v", err)
+	}
+
+	second, err := FromMnemonic(phrase, "", 1)
+	if err != nil {
+		t.Fatalf("Ошибка вывода ключей: %v", err)
+	}
+
+	if first.SigningKey.Equal(second.SigningKey) {
+		t.Error("Разные учетные записи получили одинаковый ключ подписи")
+	}
+
+	// Восстановление на "новом ноутбуке" дает те же ключи
+	ks, err := keystore.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Ошибка открытия хранилища: %v", err)
+	}
+	ks.ScryptN = 1 << 10
+
+	restored, err := Restore(ks, phrase, "", "alice", 2, "password")
+	if err != nil {
+		t.Fatalf("Ошибка восстановления: %v", err)
+	}
+
+	if len(restored) != 2 {
+		t.Fatalf("Ожидалось 2 учетные записи, получено %d", len(restored))
+	}
+
+	signer, err := ks.UnlockSigner("alice-0-signing", "password")
+	if err != nil {
+		t.Fatalf("Ошибка разблокировки ключа подписи: %v", err)
+	}
+	if !signer.Equal(first.SigningKey) {
+		t.Error("Восстановленный ключ подписи отличается от исходного")
+	}
+
+	prekey, err := ks.UnlockPrekey("alice-1-prekey", "password")
+	if err != nil {
+		t.Fatalf("Ошибка разблокировки X25519 ключа: %v", err)
+	}
+	if !prekey.Equal(second.Prekey) {
+		t.Error("Восстановленный X25519 ключ отличается от исходного")
+	}
+
+	// Другое дополнительное слово дает другие ключи
+	other, err := FromMnemonic(phrase, "extra", 0)
+	if err != nil {
+		t.Fatalf("Ошибка вывода ключей: %v", err)
+	}
+	if other.SigningKey.Equal(first.SigningKey) {
+		t.Error("Дополнительное слово не повлияло на ключи")
+	}
+}
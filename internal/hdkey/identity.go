@@ -0,0 +1,109 @@
+package hdkey
+
+import (
+	"blockchainStorage/internal/keystore"
+	"blockchainStorage/internal/mnemonic"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"fmt"
+)
+
+const (
+	signingSuffix = "-signing"
+	prekeySuffix  = "-prekey"
+)
+
+// Identity ключи одной учетной записи, выведенные из seed-фразы.
+// Одна и та же фраза и номер учетной записи всегда дают одинаковые ключи.
+type Identity struct {
+	Account    uint32
+	SigningKey *ecdsa.PrivateKey
+	Prekey     *ecdh.PrivateKey
+}
+
+// DeriveIdentity выводит ключ подписи и X25519 ключ учетной записи account из seed
+func DeriveIdentity(seed []byte, account uint32) (*Identity, error) {
+	signingMaster, err := NewMaster(seed, CurveP256)
+	if err != nil {
+		return nil, err
+	}
+
+	signingNode, err := signingMaster.Derive(SigningPath(account))
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive signing key: %w", err)
+	}
+
+	signingKey, err := signingNode.ECDSA()
+	if err != nil {
+		return nil, err
+	}
+
+	prekeyMaster, err := NewMaster(seed, CurveX25519)
+	if err != nil {
+		return nil, err
+	}
+
+	prekeyNode, err := prekeyMaster.Derive(PrekeyPath(account))
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive prekey: %w", err)
+	}
+
+	prekey, err := prekeyNode.X25519()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Identity{Account: account, SigningKey: signingKey, Prekey: prekey}, nil
+}
+
+// FromMnemonic проверяет seed-фразу и выводит ключи учетной записи account.
+// passphrase - необязательное дополнительное слово BIP39, а не пароль хранилища.
+func FromMnemonic(phrase string, passphrase string, account uint32) (*Identity, error) {
+	seed, err := mnemonic.Seed(phrase, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute seed: %w", err)
+	}
+
+	return DeriveIdentity(seed, account)
+}
+
+// Save сохраняет ключи учетной записи в хранилище под именами name-signing и name-prekey
+func (id *Identity) Save(ks *keystore.Keystore, name string, passphrase string) error {
+	_, err := ks.Add(name+signingSuffix, id.SigningKey, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to store signing key: %w", err)
+	}
+
+	_, err = ks.Add(name+prekeySuffix, id.Prekey, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to store prekey: %w", err)
+	}
+
+	return nil
+}
+
+// Restore восстанавливает ключи учетных записей 0..accounts-1 из seed-фразы
+// и сохраняет их в хранилище под именами name-<номер>-signing и name-<номер>-prekey
+func Restore(ks *keystore.Keystore, phrase string, mnemonicPassphrase string, name string, accounts uint32, passphrase string) ([]*Identity, error) {
+	seed, err := mnemonic.Seed(phrase, mnemonicPassphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute seed: %w", err)
+	}
+
+	identities := make([]*Identity, 0, accounts)
+	for account := uint32(0); account < accounts; account++ {
+		identity, err := DeriveIdentity(seed, account)
+		if err != nil {
+			return nil, err
+		}
+
+		err = identity.Save(ks, fmt.Sprintf("%s-%d", name, account), passphrase)
+		if err != nil {
+			return nil, err
+		}
+
+		identities = append(identities, identity)
+	}
+
+	return identities, nil
+}
@@ -0,0 +1,179 @@
+package identity
+
+import (
+	"blockchainStorage/internal/address"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+const (
+	// PublicKeyLength длина открытого ключа P-256 в несжатом формате
+	PublicKeyLength = 65
+
+	eciesInfo = "blockchainChat ecies"
+)
+
+var (
+	ErrInvalidPublicKey = errors.New("invalid P-256 public key")
+	ErrDecryptFailed    = errors.New("failed to decrypt message")
+)
+
+// Identity ключ пользователя ECDSA P-256, которым он подписывает транзакции и
+// получает сообщения (ECIES). Один ключ заменяет пару ключей подписи и шифрования.
+type Identity struct {
+	privateKey *ecdsa.PrivateKey
+}
+
+// Generate создает новую личность со случайным ключом
+func Generate() (*Identity, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate identity key: %w", err)
+	}
+
+	return &Identity{privateKey: privateKey}, nil
+}
+
+// FromPrivateKey создает личность из существующего ключа P-256,
+// например из хранилища ключей или выведенного из seed-фразы
+func FromPrivateKey(privateKey *ecdsa.PrivateKey) (*Identity, error) {
+	if privateKey == nil || privateKey.Curve != elliptic.P256() {
+		return nil, errors.New("identity key must be a P-256 key")
+	}
+
+	return &Identity{privateKey: privateKey}, nil
+}
+
+// PrivateKey возвращает ключ подписи личности
+func (id *Identity) PrivateKey() *ecdsa.PrivateKey {
+	return id.privateKey
+}
+
+// PublicKey возвращает открытый ключ в несжатом формате, который публикуется
+// в блокчейне и указывается в транзакциях
+func (id *Identity) PublicKey() []byte {
+	return MarshalPublicKey(&id.privateKey.PublicKey)
+}
+
+// Address возвращает адрес личности
+func (id *Identity) Address() string {
+	return address.FromPublicKey(id.PublicKey())
+}
+
+// Decrypt расшифровывает сообщение, зашифрованное функцией Encrypt на ключ личности
+func (id *Identity) Decrypt(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < PublicKeyLength {
+		return nil, ErrDecryptFailed
+	}
+
+	ephemeralKey, err := ecdh.P256().NewPublicKey(ciphertext[:PublicKeyLength])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid ephemeral key", ErrDecryptFailed)
+	}
+
+	privateKey, err := id.privateKey.ECDH()
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert identity key: %w", err)
+	}
+
+	shared, err := privateKey.ECDH(ephemeralKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecryptFailed, err)
+	}
+
+	aead, err := newCipher(shared, ciphertext[:PublicKeyLength], id.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, make([]byte, aead.NonceSize()), ciphertext[PublicKeyLength:], nil)
+	if err != nil {
+		return nil, ErrDecryptFailed
+	}
+
+	return plaintext, nil
+}
+
+// Encrypt шифрует сообщение на открытый ключ получателя по схеме ECIES:
+// эфемерный ключ P-256, ECDH, HKDF-SHA256 и AES-256-GCM.
+// Результат: эфемерный открытый ключ (65 байт) и шифртекст.
+func Encrypt(recipient *ecdsa.PublicKey, plaintext []byte) ([]byte, error) {
+	recipientKey, err := recipient.ECDH()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPublicKey, err)
+	}
+
+	ephemeral, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	shared, err := ephemeral.ECDH(recipientKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute shared secret: %w", err)
+	}
+
+	ephemeralPublic := ephemeral.PublicKey().Bytes()
+	aead, err := newCipher(shared, ephemeralPublic, recipientKey.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	// Ключ шифрования уникален для каждого сообщения, поэтому нулевой nonce безопасен
+	return aead.Seal(ephemeralPublic, make([]byte, aead.NonceSize()), plaintext, nil), nil
+}
+
+// ParsePublicKey разбирает открытый ключ P-256 в несжатом формате
+func ParsePublicKey(data []byte) (*ecdsa.PublicKey, error) {
+	x, y := elliptic.Unmarshal(elliptic.P256(), data)
+	if x == nil {
+		return nil, ErrInvalidPublicKey
+	}
+
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+}
+
+// MarshalPublicKey кодирует открытый ключ P-256 в несжатом формате
+func MarshalPublicKey(publicKey *ecdsa.PublicKey) []byte {
+	return elliptic.Marshal(elliptic.P256(), publicKey.X, publicKey.Y)
+}
+
+// newCipher выводит ключ AES-256-GCM из общего секрета, связывая его с
+// эфемерным ключом и ключом получателя
+func newCipher(shared []byte, ephemeralKey []byte, recipientKey []byte) (cipher.AEAD, error) {
+	salt := make([]byte, 0, len(ephemeralKey)+len(recipientKey))
+	salt = append(salt, ephemeralKey...)
+	salt = append(salt, recipientKey...)
+
+	block, err := aes.NewCipher(hkdf(salt, shared, eciesInfo))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	return aead, nil
+}
+
+// hkdf реализует HKDF-SHA256 (RFC 5869) для ключа длиной 32 байта
+func hkdf(salt []byte, secret []byte, info string) []byte {
+	extractor := hmac.New(sha256.New, salt)
+	extractor.Write(secret)
+	prk := extractor.Sum(nil)
+
+	expander := hmac.New(sha256.New, prk)
+	expander.Write([]byte(info))
+	expander.Write([]byte{1})
+	return expander.Sum(nil)
+}
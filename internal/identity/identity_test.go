@@ -0,0 +1,75 @@
+package identity
+
+import (
+	"blockchainStorage/internal/address"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	recipient, err := Generate()
+	if err != nil {
+		t.Fatalf("Ошибка генерации ключа: %v", err)
+	}
+
+	publicKey, err := ParsePublicKey(recipient.PublicKey())
+	if err != nil {
+		t.Fatalf("Ошибка разбора открытого ключа: %v", err)
+	}
+
+	ciphertext, err := Encrypt(publicKey, []byte("hello"))
+	if err != nil {
+		t.Fatalf("Ошибка шифрования: %v", err)
+	}
+
+	plaintext, err := recipient.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("Ошибка расшифровки: %v", err)
+	}
+	if !bytes.Equal(plaintext, []byte("hello")) {
+		t.Errorf("Ожидалось hello, получено %s", plaintext)
+	}
+
+	// Каждое шифрование использует новый эфемерный ключ
+	again, err := Encrypt(publicKey, []byte("hello"))
+	if err != nil {
+		t.Fatalf("Ошибка шифрования: %v", err)
+	}
+	if bytes.Equal(ciphertext, again) {
+		t.Error("Одинаковые сообщения дали одинаковый шифртекст")
+	}
+
+	// Чужой ключ не расшифровывает сообщение
+	other, err := Generate()
+	if err != nil {
+		t.Fatalf("Ошибка генерации ключа: %v", err)
+	}
+	_, err = other.Decrypt(ciphertext)
+	if !errors.Is(err, ErrDecryptFailed) {
+		t.Errorf("Ожидалась ошибка ErrDecryptFailed, получено %v", err)
+	}
+
+	// Измененный шифртекст отклоняется
+	ciphertext[len(ciphertext)-1] ^= 1
+	_, err = recipient.Decrypt(ciphertext)
+	if !errors.Is(err, ErrDecryptFailed) {
+		t.Errorf("Ожидалась ошибка ErrDecryptFailed, получено %v", err)
+	}
+}
+
+func TestIdentityAddress(t *testing.T) {
+	id, err := Generate()
+	if err != nil {
+		t.Fatalf("Ошибка генерации ключа: %v", err)
+	}
+
+	if !address.MatchesPublicKey(id.Address(), id.PublicKey()) {
+		t.Error("Адрес не соответствует открытому ключу")
+	}
+
+	_, err = ParsePublicKey([]byte("invalid"))
+	if !errors.Is(err, ErrInvalidPublicKey) {
+		t.Errorf("Ожидалась ошибка ErrInvalidPublicKey, получено %v", err)
+	}
+}
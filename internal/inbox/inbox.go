@@ -0,0 +1,497 @@
+package inbox
+
+import (
+	"blockchainStorage/internal/blockchain"
+	"blockchainStorage/internal/blockchain/iterator"
+	"blockchainStorage/internal/session"
+	"blockchainStorage/internal/storage"
+	"blockchainStorage/internal/transaction"
+	"crypto"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// Namespace префикс входящих сообщений в storage.DataStore (см. DataStore.Namespace)
+	Namespace = "inbox_"
+
+	// MessagePrefix префикс расшифрованных сообщений в локальном хранилище
+	MessagePrefix = "msg_"
+	// IndexKey список идентификаторов сообщений в порядке получения
+	IndexKey = "index"
+	// CursorKey хэш последнего просмотренного блока
+	CursorKey = "cursor"
+	// ExpiringKey сообщения со сроком хранения, текст которых еще не удален
+	ExpiringKey = "expiring"
+)
+
+var ErrMessageNotFound = errors.New("message not found in inbox")
+
+// Message расшифрованное сообщение, адресованное одному из локальных ключей
+type Message struct {
+	// ID идентификатор сообщения: транзакция и номер выхода
+	ID          string
+	TxID        string
+	OutputIndex int
+	Sender      string
+	Recipient   string
+	Amount      int64
+	// Height и BlockHash блок, подтвердивший сообщение, пусто до подтверждения
+	Height    int64
+	BlockHash string
+	// Direct сообщение доставлено напрямую и еще не подтверждено блоком
+	Direct    bool
+	Timestamp int64
+	Content   []byte
+	Meta      transaction.MessageMeta
+	// Error причина, по которой сообщение не удалось расшифровать
+	Error string `json:",omitempty"`
+	Read  bool
+	// Expired расшифрованный текст удален по истечении срока хранения
+	Expired bool
+}
+
+// Inbox находит в блоках сообщения для локальных ключей, расшифровывает их и
+// хранит в локальном индексе вместе с отметками о прочтении. Расшифрованные
+// сообщения не покидают узел.
+type Inbox struct {
+	mu       sync.Mutex
+	db       blockchain.DbInterface
+	kv       storage.KeyValueStore
+	keys     map[string]crypto.PrivateKey
+	sessions *session.Manager
+}
+
+func New(db blockchain.DbInterface, kv storage.KeyValueStore) *Inbox {
+	return &Inbox{
+		db:   db,
+		kv:   kv,
+		keys: make(map[string]crypto.PrivateKey),
+	}
+}
+
+// AddKey добавляет локальный ключ получателя address: *identity.Identity,
+// *ecdsa.PrivateKey или *rsa.PrivateKey. Уже просмотренные блоки не
+// сканируются заново, для этого нужен Rescan.
+func (ib *Inbox) AddKey(address string, privateKey crypto.PrivateKey) {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	ib.keys[address] = privateKey
+}
+
+// SetSessions подключает менеджер сессий для расшифровки сообщений с прямой секретностью
+func (ib *Inbox) SetSessions(sessions *session.Manager) {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	ib.sessions = sessions
+}
+
+// Watch сканирует цепочку до текущего последнего блока и подписывается на новые
+// блоки. Ошибки сканирования новых блоков передаются в onError.
+func (ib *Inbox) Watch(bc *blockchain.Blockchain, onError func(err error)) error {
+	_, err := ib.Scan(bc.Tip)
+	if err != nil {
+		return err
+	}
+
+	bc.Subscribe(func(block *blockchain.Block) {
+		_, err := ib.Scan([]byte(block.Hash))
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	})
+
+	return nil
+}
+
+// Scan просматривает блоки от tip до последнего просмотренного блока и
+// добавляет новые сообщения в индекс. Возвращает количество новых сообщений.
+func (ib *Inbox) Scan(tip []byte) (int, error) {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	var cursor string
+	_, err := ib.kv.Get(CursorKey, &cursor)
+	if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		return 0, fmt.Errorf("failed to load inbox cursor: %w", err)
+	}
+
+	return ib.scan(tip, cursor)
+}
+
+// Rescan просматривает всю цепочку, например после добавления ключа.
+// Уже полученные сообщения и их отметки о прочтении сохраняются.
+func (ib *Inbox) Rescan(tip []byte) (int, error) {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	return ib.scan(tip, "")
+}
+
+func (ib *Inbox) scan(tip []byte, cursor string) (int, error) {
+	// Итератор идет от новых блоков к старым, сообщения добавляются от старых к новым.
+	// Если после реорганизации цепочки cursor в ней отсутствует, просматривается
+	// вся цепочка: уже полученные сообщения не дублируются.
+	var blocks []*blockchain.Block
+	it := iterator.NewBlockchainIterator(ib.db, tip)
+	for {
+		block, err := it.Next()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read block: %w", err)
+		}
+
+		if block.Hash == cursor {
+			break
+		}
+
+		blocks = append(blocks, block)
+		if block.PrevHash == "" {
+			break
+		}
+	}
+
+	index, err := ib.index()
+	if err != nil {
+		return 0, err
+	}
+
+	expiring, err := ib.expiring()
+	if err != nil {
+		return 0, err
+	}
+
+	received := 0
+	for i := len(blocks) - 1; i >= 0; i-- {
+		var messages []*Message
+		for _, tx := range blocks[i].Transactions {
+			txMessages, _, err := ib.receive(tx, blocks[i])
+			if err != nil {
+				return received, err
+			}
+			messages = append(messages, txMessages...)
+		}
+
+		index, expiring, err = ib.store(messages, index, expiring)
+		if err != nil {
+			return received, err
+		}
+		received += len(messages)
+
+		count := len(expiring)
+		expiring, err = ib.expire(expiring, blocks[i].Index)
+		if err != nil {
+			return received, err
+		}
+
+		if len(expiring) != count {
+			err = ib.kv.Put(ExpiringKey, expiring)
+			if err != nil {
+				return received, fmt.Errorf("failed to save expiring messages: %w", err)
+			}
+		}
+
+		err = ib.kv.Put(CursorKey, blocks[i].Hash)
+		if err != nil {
+			return received, fmt.Errorf("failed to save inbox cursor: %w", err)
+		}
+	}
+
+	return received, nil
+}
+
+// expire удаляет текст сообщений, срок хранения которых истек к блоку height,
+// и возвращает оставшиеся сообщения со сроком хранения
+func (ib *Inbox) expire(expiring []string, height int64) ([]string, error) {
+	var remaining []string
+	for _, id := range expiring {
+		message, err := ib.load(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if !message.Meta.Expired(height) {
+			remaining = append(remaining, id)
+			continue
+		}
+
+		message.Content = nil
+		message.Expired = true
+		err = ib.kv.Put(MessagePrefix+id, message)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save message %s: %w", id, err)
+		}
+	}
+
+	return remaining, nil
+}
+
+// Deliver принимает сообщение, доставленное напрямую до подтверждения в блоке,
+// и возвращает количество его выходов для локальных ключей. Когда сообщение
+// попадет в блок, Scan дополнит его индексом блока.
+func (ib *Inbox) Deliver(tx *transaction.Transaction) (int, error) {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	messages, local, err := ib.receive(tx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	index, err := ib.index()
+	if err != nil {
+		return 0, err
+	}
+
+	expiring, err := ib.expiring()
+	if err != nil {
+		return 0, err
+	}
+
+	_, _, err = ib.store(messages, index, expiring)
+	if err != nil {
+		return 0, err
+	}
+
+	return local, nil
+}
+
+// store сохраняет новые сообщения и добавляет их в индекс и список сообщений
+// со сроком хранения
+func (ib *Inbox) store(messages []*Message, index []string, expiring []string) ([]string, []string, error) {
+	if len(messages) == 0 {
+		return index, expiring, nil
+	}
+
+	for _, message := range messages {
+		err := ib.kv.Put(MessagePrefix+message.ID, message)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to save message %s: %w", message.ID, err)
+		}
+
+		index = append(index, message.ID)
+		if message.Meta.ExpiresAt != 0 {
+			expiring = append(expiring, message.ID)
+		}
+	}
+
+	err := ib.kv.Put(IndexKey, index)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to save inbox index: %w", err)
+	}
+
+	err = ib.kv.Put(ExpiringKey, expiring)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to save expiring messages: %w", err)
+	}
+
+	return index, expiring, nil
+}
+
+// receive расшифровывает новые выходы сообщения для локальных ключей и
+// возвращает их вместе с количеством выходов для локальных ключей. block равен
+// nil для сообщений, доставленных напрямую.
+func (ib *Inbox) receive(tx *transaction.Transaction, block *blockchain.Block) ([]*Message, int, error) {
+	if tx.Type != transaction.TypeMessage {
+		return nil, 0, nil
+	}
+
+	var messages []*Message
+	local := 0
+	for i, output := range tx.Outputs {
+		if len(output.EncryptedData) == 0 {
+			continue
+		}
+
+		// Сообщение на имя вида "@name" адресовано владельцу имени
+		owner, err := blockchain.OutputOwner(ib.db, tx, i)
+		if errors.Is(err, blockchain.ErrNameNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to resolve recipient of %s: %w", messageID(tx.ID, i), err)
+		}
+
+		privateKey, isLocal := ib.keys[owner]
+		if !isLocal {
+			continue
+		}
+		local++
+
+		id := messageID(tx.ID, i)
+		existing, err := ib.load(id)
+		if err == nil {
+			// Сообщение, доставленное напрямую, подтверждено блоком
+			if existing.Direct && block != nil {
+				existing.Direct = false
+				existing.Height = block.Index
+				existing.BlockHash = block.Hash
+				err = ib.kv.Put(MessagePrefix+id, existing)
+				if err != nil {
+					return nil, 0, fmt.Errorf("failed to save message %s: %w", id, err)
+				}
+			}
+			continue
+		}
+		if !errors.Is(err, ErrMessageNotFound) {
+			return nil, 0, err
+		}
+
+		message := &Message{
+			ID:          id,
+			TxID:        tx.ID,
+			OutputIndex: i,
+			Sender:      tx.Sender,
+			Recipient:   output.Recipient,
+			Amount:      output.Amount,
+			Direct:      block == nil,
+			Timestamp:   time.Now().UnixNano(),
+		}
+
+		if block != nil {
+			message.Height = block.Index
+			message.BlockHash = block.Hash
+			message.Timestamp = block.Timestamp
+		}
+
+		meta, err := tx.MessageMeta()
+		if err == nil {
+			message.Meta = *meta
+		}
+
+		// Сообщение, которое не удалось расшифровать, сохраняется с причиной
+		// ошибки: оно адресовано пользователю, и сканирование не должно из-за
+		// него останавливаться
+		message.Content, err = ib.decrypt(tx, i, privateKey)
+		if err != nil {
+			message.Error = err.Error()
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, local, nil
+}
+
+func (ib *Inbox) decrypt(tx *transaction.Transaction, index int, privateKey crypto.PrivateKey) ([]byte, error) {
+	if tx.Outputs[index].Scheme != transaction.SchemeRatchet {
+		return tx.DecryptOutput(index, privateKey)
+	}
+
+	if ib.sessions == nil {
+		return nil, session.ErrNoSession
+	}
+
+	return ib.sessions.DecryptOutput(tx.Sender, tx.Outputs[index])
+}
+
+// Messages возвращает все сообщения в порядке получения
+func (ib *Inbox) Messages() ([]*Message, error) {
+	return ib.filter(func(*Message) bool { return true })
+}
+
+// Unread возвращает непрочитанные сообщения в порядке получения
+func (ib *Inbox) Unread() ([]*Message, error) {
+	return ib.filter(func(message *Message) bool { return !message.Read })
+}
+
+// Get возвращает сообщение по идентификатору
+func (ib *Inbox) Get(id string) (*Message, error) {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	return ib.load(id)
+}
+
+// MarkRead отмечает сообщение прочитанным
+func (ib *Inbox) MarkRead(id string) error {
+	return ib.setRead(id, true)
+}
+
+// MarkUnread снимает отметку о прочтении
+func (ib *Inbox) MarkUnread(id string) error {
+	return ib.setRead(id, false)
+}
+
+func (ib *Inbox) setRead(id string, read bool) error {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	message, err := ib.load(id)
+	if err != nil {
+		return err
+	}
+
+	message.Read = read
+	err = ib.kv.Put(MessagePrefix+id, message)
+	if err != nil {
+		return fmt.Errorf("failed to save message %s: %w", id, err)
+	}
+
+	return nil
+}
+
+func (ib *Inbox) filter(match func(message *Message) bool) ([]*Message, error) {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	index, err := ib.index()
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []*Message
+	for _, id := range index {
+		message, err := ib.load(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if match(message) {
+			messages = append(messages, message)
+		}
+	}
+
+	return messages, nil
+}
+
+func (ib *Inbox) index() ([]string, error) {
+	var index []string
+	_, err := ib.kv.Get(IndexKey, &index)
+	if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		return nil, fmt.Errorf("failed to load inbox index: %w", err)
+	}
+
+	return index, nil
+}
+
+func (ib *Inbox) expiring() ([]string, error) {
+	var expiring []string
+	_, err := ib.kv.Get(ExpiringKey, &expiring)
+	if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		return nil, fmt.Errorf("failed to load expiring messages: %w", err)
+	}
+
+	return expiring, nil
+}
+
+func (ib *Inbox) load(id string) (*Message, error) {
+	var message Message
+	_, err := ib.kv.Get(MessagePrefix+id, &message)
+	if err != nil {
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to load message %s: %w", id, err)
+	}
+
+	return &message, nil
+}
+
+func messageID(txID string, index int) string {
+	return txID + ":" + strconv.Itoa(index)
+}
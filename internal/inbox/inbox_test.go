@@ -0,0 +1,279 @@
+package inbox
+
+import (
+	"blockchainStorage/internal/blockchain"
+	"blockchainStorage/internal/identity"
+	"blockchainStorage/internal/storage"
+	"blockchainStorage/internal/transaction"
+	"crypto"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type identityResolver map[string]*identity.Identity
+
+func (r identityResolver) ResolveEncryptionKey(recipient string) (crypto.PublicKey, error) {
+	return &r[recipient].PrivateKey().PublicKey, nil
+}
+
+// addBlock сохраняет блок с транзакциями поверх prev без майнинга
+func addBlock(t *testing.T, db *blockchain.MockDbStorage, prev *blockchain.Block, txs ...*transaction.Transaction) *blockchain.Block {
+	block := &blockchain.Block{Timestamp: 1, Transactions: txs}
+	if prev != nil {
+		block.Index = prev.Index + 1
+		block.PrevHash = prev.Hash
+	}
+	block.Hash = block.HashTransactions() + string(rune('a'+block.Index))
+
+	require.NoError(t, db.SaveBlockToDB(block))
+	return block
+}
+
+func TestInbox_ScanAndReadState(t *testing.T) {
+	alice, err := identity.Generate()
+	require.NoError(t, err)
+	bob, err := identity.Generate()
+	require.NoError(t, err)
+	resolver := identityResolver{alice.Address(): alice, bob.Address(): bob}
+
+	toAlice, err := transaction.NewTransactionWithResolver(nil, []transaction.MessageOutput{
+		{Recipient: alice.Address(), EncryptedData: []byte("hi alice")},
+		{Recipient: bob.Address(), EncryptedData: []byte("hi bob")},
+	}, resolver)
+	require.NoError(t, err)
+	toAlice.Sender = bob.Address()
+
+	db := blockchain.NewMockDbStorage()
+	genesis := addBlock(t, db, nil)
+	first := addBlock(t, db, genesis, transaction.NewCoinbaseTransaction(alice.Address(), 10), toAlice)
+
+	box := New(db, storage.NewMemoryStore())
+	box.AddKey(alice.Address(), alice)
+
+	// Находится только выход для локального ключа, выход вознаграждения пропускается
+	received, err := box.Scan([]byte(first.Hash))
+	require.NoError(t, err)
+	assert.Equal(t, 1, received)
+
+	unread, err := box.Unread()
+	require.NoError(t, err)
+	require.Len(t, unread, 1)
+	assert.Equal(t, []byte("hi alice"), unread[0].Content)
+	assert.Equal(t, bob.Address(), unread[0].Sender)
+	assert.Equal(t, first.Index, unread[0].Height)
+	assert.Empty(t, unread[0].Error)
+
+	require.NoError(t, box.MarkRead(unread[0].ID))
+	unread, err = box.Unread()
+	require.NoError(t, err)
+	assert.Empty(t, unread)
+
+	// Новый блок сканируется от последнего просмотренного
+	again, err := transaction.NewTransactionWithResolver(nil, []transaction.MessageOutput{
+		{Recipient: alice.Address(), EncryptedData: []byte("again")},
+	}, resolver)
+	require.NoError(t, err)
+	second := addBlock(t, db, first, again)
+
+	received, err = box.Scan([]byte(second.Hash))
+	require.NoError(t, err)
+	assert.Equal(t, 1, received)
+
+	// Повторный просмотр всей цепочки не дублирует сообщения и сохраняет отметки
+	received, err = box.Rescan([]byte(second.Hash))
+	require.NoError(t, err)
+	assert.Equal(t, 0, received)
+
+	messages, err := box.Messages()
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	assert.True(t, messages[0].Read)
+	assert.False(t, messages[1].Read)
+	assert.Equal(t, []byte("again"), messages[1].Content)
+
+	_, err = box.Get("missing:0")
+	assert.ErrorIs(t, err, ErrMessageNotFound)
+}
+
+func TestInbox_UndecryptableMessage(t *testing.T) {
+	alice, err := identity.Generate()
+	require.NoError(t, err)
+	mallory, err := identity.Generate()
+	require.NoError(t, err)
+
+	// Выход адресован alice, но зашифрован ключом другого пользователя
+	tx, err := transaction.NewTransactionWithResolver(nil, []transaction.MessageOutput{
+		{Recipient: alice.Address(), EncryptedData: []byte("secret")},
+	}, identityResolver{alice.Address(): mallory})
+	require.NoError(t, err)
+
+	db := blockchain.NewMockDbStorage()
+	block := addBlock(t, db, addBlock(t, db, nil), tx)
+
+	box := New(db, storage.NewMemoryStore())
+	box.AddKey(alice.Address(), alice)
+
+	received, err := box.Scan([]byte(block.Hash))
+	require.NoError(t, err)
+	assert.Equal(t, 1, received)
+
+	messages, err := box.Messages()
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Nil(t, messages[0].Content)
+	assert.NotEmpty(t, messages[0].Error)
+}
+
+func TestInbox_ExpiredMessagesLosePlaintext(t *testing.T) {
+	alice, err := identity.Generate()
+	require.NoError(t, err)
+	resolver := identityResolver{alice.Address(): alice}
+
+	tx, err := transaction.NewTransactionWithResolver(nil, []transaction.MessageOutput{
+		{Recipient: alice.Address(), EncryptedData: []byte("burn after reading")},
+	}, resolver)
+	require.NoError(t, err)
+	require.NoError(t, tx.SetMessageMeta(transaction.MessageMeta{ExpiresAt: 3}))
+
+	db := blockchain.NewMockDbStorage()
+	first := addBlock(t, db, addBlock(t, db, nil), tx)
+
+	box := New(db, storage.NewMemoryStore())
+	box.AddKey(alice.Address(), alice)
+
+	_, err = box.Scan([]byte(first.Hash))
+	require.NoError(t, err)
+
+	message, err := box.Get(messageID(tx.ID, 0))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("burn after reading"), message.Content)
+	assert.False(t, message.Expired)
+
+	// Текст удаляется при просмотре блока, в котором истекает срок хранения
+	third := addBlock(t, db, addBlock(t, db, first))
+	_, err = box.Scan([]byte(third.Hash))
+	require.NoError(t, err)
+
+	message, err = box.Get(messageID(tx.ID, 0))
+	require.NoError(t, err)
+	assert.Nil(t, message.Content)
+	assert.True(t, message.Expired)
+	assert.Equal(t, int64(3), message.Meta.ExpiresAt)
+}
+
+func TestInbox_DirectDeliveryConfirmedByBlock(t *testing.T) {
+	alice, err := identity.Generate()
+	require.NoError(t, err)
+	bob, err := identity.Generate()
+	require.NoError(t, err)
+
+	tx, err := transaction.NewTransactionWithResolver(nil, []transaction.MessageOutput{
+		{Recipient: alice.Address(), EncryptedData: []byte("instant")},
+		{Recipient: bob.Address(), EncryptedData: []byte("not ours")},
+	}, identityResolver{alice.Address(): alice, bob.Address(): bob})
+	require.NoError(t, err)
+	tx.Sender = bob.Address()
+
+	db := blockchain.NewMockDbStorage()
+	box := New(db, storage.NewMemoryStore())
+	box.AddKey(alice.Address(), alice)
+
+	local, err := box.Deliver(tx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, local)
+
+	message, err := box.Get(messageID(tx.ID, 0))
+	require.NoError(t, err)
+	assert.True(t, message.Direct)
+	assert.Equal(t, []byte("instant"), message.Content)
+	assert.Empty(t, message.BlockHash)
+
+	// Повторная доставка не дублирует сообщение
+	_, err = box.Deliver(tx)
+	require.NoError(t, err)
+	messages, err := box.Messages()
+	require.NoError(t, err)
+	assert.Len(t, messages, 1)
+
+	// Блок с сообщением подтверждает его, не создавая нового
+	block := addBlock(t, db, addBlock(t, db, nil), tx)
+	received, err := box.Scan([]byte(block.Hash))
+	require.NoError(t, err)
+	assert.Equal(t, 0, received)
+
+	message, err = box.Get(messageID(tx.ID, 0))
+	require.NoError(t, err)
+	assert.False(t, message.Direct)
+	assert.Equal(t, block.Index, message.Height)
+	assert.Equal(t, block.Hash, message.BlockHash)
+}
+
+// saveState записывает значение состояния цепочки так же, как при подключении блока
+func saveState(t *testing.T, db *blockchain.MockDbStorage, key string, value interface{}) {
+	data, err := json.Marshal(value)
+	require.NoError(t, err)
+	require.NoError(t, db.SaveStateToDB(key, data))
+}
+
+func TestInbox_MessageToName(t *testing.T) {
+	alice, err := identity.Generate()
+	require.NoError(t, err)
+	bob, err := identity.Generate()
+	require.NoError(t, err)
+	resolver := identityResolver{"@alice": alice}
+
+	db := blockchain.NewMockDbStorage()
+	saveState(t, db, blockchain.NameStatePrefix+"alice", blockchain.NameRecord{Name: "alice", Owner: alice.Address()})
+
+	toName, err := transaction.NewTransactionWithResolver(nil, []transaction.MessageOutput{
+		{Recipient: "@alice", EncryptedData: []byte("hi @alice")},
+	}, resolver)
+	require.NoError(t, err)
+	toName.Sender = bob.Address()
+
+	box := New(db, storage.NewMemoryStore())
+	box.AddKey(alice.Address(), alice)
+
+	// До подтверждения имя разрешается по текущей регистрации
+	local, err := box.Deliver(toName)
+	require.NoError(t, err)
+	assert.Equal(t, 1, local)
+
+	message, err := box.Get(messageID(toName.ID, 0))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hi @alice"), message.Content)
+	assert.Equal(t, "@alice", message.Recipient)
+
+	// После подтверждения получатель берется из выхода: имя уже занято bob,
+	// но сообщение было адресовано alice
+	saveState(t, db, blockchain.NameStatePrefix+"alice", blockchain.NameRecord{Name: "alice", Owner: bob.Address()})
+	saveState(t, db, blockchain.OutputStatePrefix+toName.ID+"_0", blockchain.OutputRecord{TxID: toName.ID, Owner: alice.Address()})
+
+	later, err := transaction.NewTransactionWithResolver(nil, []transaction.MessageOutput{
+		{Recipient: "@alice", EncryptedData: []byte("hi bob")},
+	}, identityResolver{"@alice": bob})
+	require.NoError(t, err)
+	saveState(t, db, blockchain.OutputStatePrefix+later.ID+"_0", blockchain.OutputRecord{TxID: later.ID, Owner: bob.Address()})
+
+	block := addBlock(t, db, addBlock(t, db, nil), toName, later)
+	received, err := box.Scan([]byte(block.Hash))
+	require.NoError(t, err)
+	assert.Equal(t, 0, received, "message to the new owner of the name is not ours")
+
+	message, err = box.Get(messageID(toName.ID, 0))
+	require.NoError(t, err)
+	assert.False(t, message.Direct)
+	assert.Equal(t, block.Hash, message.BlockHash)
+
+	// Сообщение на незарегистрированное имя пропускается
+	unknown, err := transaction.NewTransactionWithResolver(nil, []transaction.MessageOutput{
+		{Recipient: "@nobody", EncryptedData: []byte("lost")},
+	}, identityResolver{"@nobody": bob})
+	require.NoError(t, err)
+	local, err = box.Deliver(unknown)
+	require.NoError(t, err)
+	assert.Equal(t, 0, local)
+}
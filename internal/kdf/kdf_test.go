@@ -0,0 +1,30 @@
+package kdf
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrypt(t *testing.T) {
+	// Тестовые векторы из RFC 7914
+	key, err := Scrypt([]byte(""), []byte(""), 16, 1, 1, 64)
+	require.NoError(t, err)
+	assert.Equal(t, "77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906", hex.EncodeToString(key))
+
+	key, err = Scrypt([]byte("password"), []byte("NaCl"), 1024, 8, 16, 64)
+	require.NoError(t, err)
+	assert.Equal(t, "fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b3731622eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640", hex.EncodeToString(key))
+
+	_, err = Scrypt([]byte("password"), []byte("salt"), 1000, 8, 1, 32)
+	assert.Error(t, err)
+}
+
+func TestPBKDF2(t *testing.T) {
+	// Тестовый вектор из RFC 6070
+	key := PBKDF2([]byte("password"), []byte("salt"), 4096, 20, sha1.New)
+	assert.Equal(t, "4b007901b765489abead49d926f721d065a429c1", hex.EncodeToString(key))
+}
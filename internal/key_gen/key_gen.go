@@ -1,27 +1,53 @@
 package key_gen
 
 import (
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
+	"blockchainStorage/internal/hdkey"
+	"blockchainStorage/internal/keystore"
+	"blockchainStorage/internal/mnemonic"
 	"fmt"
 )
 
-func GenerateKey() {
-	// Выбор эллиптической кривой (например, P-256)
-	curve := elliptic.P256()
+// GenerateKey создает ключ личности P-256 и сохраняет его в хранилище ключей,
+// зашифрованным паролем. Этим ключом подписываются транзакции и расшифровываются
+// полученные сообщения. Закрытый ключ не выводится и не сохраняется открыто.
+func GenerateKey(ks *keystore.Keystore, name string, passphrase string) error {
+	entry, err := ks.Generate(name, keystore.KeySigning, passphrase)
+	if err != nil {
+		return fmt.Errorf("ошибка генерации приватного ключа: %w", err)
+	}
+
+	// Вывод публичного ключа
+	fmt.Printf("Ключ %s сохранен, публичный ключ: %x\n", entry.Name, entry.PublicKey)
+
+	return nil
+}
+
+// GenerateSeedPhrase создает seed-фразу из 24 слов и сохраняет выведенные из нее ключи
+// первой учетной записи в хранилище. Фразу нужно записать: по ней восстанавливаются все ключи.
+func GenerateSeedPhrase(ks *keystore.Keystore, name string, passphrase string) (string, error) {
+	phrase, err := mnemonic.New(256)
+	if err != nil {
+		return "", fmt.Errorf("ошибка генерации seed-фразы: %w", err)
+	}
 
-	// Генерация приватного ключа
-	privateKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+	_, err = hdkey.Restore(ks, phrase, "", name, 1, passphrase)
 	if err != nil {
-		fmt.Println("Ошибка генерации приватного ключа:", err)
-		return
+		return "", fmt.Errorf("ошибка вывода ключей из seed-фразы: %w", err)
 	}
 
-	// Получение публичного ключа из приватного
-	publicKey := &privateKey.PublicKey
+	return phrase, nil
+}
+
+// RestoreFromSeedPhrase восстанавливает ключи учетных записей 0..accounts-1 из seed-фразы
+func RestoreFromSeedPhrase(ks *keystore.Keystore, phrase string, name string, accounts uint32, passphrase string) error {
+	identities, err := hdkey.Restore(ks, phrase, "", name, accounts, passphrase)
+	if err != nil {
+		return fmt.Errorf("ошибка восстановления ключей: %w", err)
+	}
+
+	for _, identity := range identities {
+		fmt.Printf("Учетная запись %d восстановлена\n", identity.Account)
+	}
 
-	// Вывод приватного и публичного ключей
-	fmt.Printf("Приватный ключ: %x\n", privateKey.D)
-	fmt.Printf("Публичный ключ: %x\n", elliptic.Marshal(curve, publicKey.X, publicKey.Y))
+	return nil
 }
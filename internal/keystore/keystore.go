@@ -0,0 +1,445 @@
+package keystore
+
+import (
+	"blockchainStorage/internal/identity"
+	"blockchainStorage/internal/kdf"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// KeyType назначение и алгоритм ключа в хранилище
+type KeyType string
+
+const (
+	// KeySigning ключ личности ECDSA P-256: подпись транзакций и получение сообщений (ECIES)
+	KeySigning KeyType = "ecdsa-p256"
+	// KeyEncryption устаревший ключ шифрования сообщений RSA
+	KeyEncryption KeyType = "rsa"
+	// KeyPrekey X25519 ключ для установления сессий с прямой секретностью
+	KeyPrekey KeyType = "x25519"
+)
+
+const (
+	DefaultScryptN = 1 << 15
+	DefaultScryptR = 8
+	DefaultScryptP = 1
+	RSAKeyBits     = 2048
+
+	entryExtension = ".json"
+	kdfScrypt      = "scrypt"
+	keyLength      = 32
+	saltLength     = 32
+)
+
+var (
+	ErrKeyExists      = errors.New("key with this name already exists")
+	ErrKeyNotFound    = errors.New("key not found in keystore")
+	ErrWrongPassword  = errors.New("wrong passphrase or corrupted key")
+	ErrInvalidKeyName = errors.New("invalid key name")
+	ErrWrongKeyType   = errors.New("unexpected key type")
+
+	keyNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+)
+
+// CryptoParams параметры шифрования закрытого ключа
+type CryptoParams struct {
+	KDF        string
+	N          int
+	R          int
+	P          int
+	Salt       []byte
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// Entry запись хранилища: открытая часть ключа и зашифрованный закрытый ключ
+type Entry struct {
+	Name      string
+	Type      KeyType
+	PublicKey []byte
+	Created   int64
+	Crypto    CryptoParams
+}
+
+// Keystore хранит закрытые ключи в каталоге, зашифрованными паролем
+// (scrypt + AES-256-GCM). Закрытые ключи никогда не записываются на диск открыто.
+type Keystore struct {
+	dir     string
+	ScryptN int
+	ScryptR int
+	ScryptP int
+}
+
+// Open открывает хранилище ключей в каталоге dir, создавая его при необходимости
+func Open(dir string) (*Keystore, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create keystore directory: %w", err)
+	}
+
+	return &Keystore{
+		dir:     dir,
+		ScryptN: DefaultScryptN,
+		ScryptR: DefaultScryptR,
+		ScryptP: DefaultScryptP,
+	}, nil
+}
+
+// Generate создает новый ключ типа keyType и сохраняет его под паролем passphrase
+func (ks *Keystore) Generate(name string, keyType KeyType, passphrase string) (*Entry, error) {
+	var privateKey crypto.PrivateKey
+	var err error
+
+	switch keyType {
+	case KeySigning:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyEncryption:
+		privateKey, err = rsa.GenerateKey(rand.Reader, RSAKeyBits)
+	case KeyPrekey:
+		privateKey, err = ecdh.X25519().GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrWrongKeyType, keyType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key: %w", keyType, err)
+	}
+
+	return ks.store(name, privateKey, passphrase)
+}
+
+// Import сохраняет закрытый ключ из PEM (PKCS#8, PKCS#1 или SEC 1) под паролем
+func (ks *Keystore) Import(name string, pemData []byte, passphrase string) (*Entry, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("failed to decode PEM data")
+	}
+
+	var privateKey crypto.PrivateKey
+	var err error
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		privateKey, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	return ks.store(name, privateKey, passphrase)
+}
+
+// Add сохраняет уже созданный закрытый ключ (например, полученный из seed-фразы) под паролем
+func (ks *Keystore) Add(name string, privateKey crypto.PrivateKey, passphrase string) (*Entry, error) {
+	return ks.store(name, privateKey, passphrase)
+}
+
+// Export возвращает закрытый ключ в формате PEM (PKCS#8).
+// Результат не зашифрован, его нельзя сохранять на диск без защиты.
+func (ks *Keystore) Export(name string, passphrase string) ([]byte, error) {
+	privateKey, err := ks.Unlock(name, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// ExportPublic возвращает открытый ключ в формате PEM (PKIX) без ввода пароля
+func (ks *Keystore) ExportPublic(name string) ([]byte, error) {
+	entry, err := ks.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: entry.PublicKey}), nil
+}
+
+// List возвращает записи хранилища, отсортированные по имени
+func (ks *Keystore) List() ([]*Entry, error) {
+	files, err := os.ReadDir(ks.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore directory: %w", err)
+	}
+
+	var entries []*Entry
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), entryExtension) {
+			continue
+		}
+
+		entry, err := ks.Get(strings.TrimSuffix(file.Name(), entryExtension))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	return entries, nil
+}
+
+// Get возвращает запись хранилища без расшифровки закрытого ключа
+func (ks *Keystore) Get(name string) (*Entry, error) {
+	if !keyNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidKeyName, name)
+	}
+
+	data, err := os.ReadFile(ks.path(name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, name)
+		}
+		return nil, fmt.Errorf("failed to read key %s: %w", name, err)
+	}
+
+	var entry Entry
+	err = json.Unmarshal(data, &entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal key %s: %w", name, err)
+	}
+
+	return &entry, nil
+}
+
+// Delete удаляет ключ из хранилища
+func (ks *Keystore) Delete(name string) error {
+	_, err := ks.Get(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(ks.path(name))
+	if err != nil {
+		return fmt.Errorf("failed to delete key %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// Unlock расшифровывает закрытый ключ паролем passphrase
+func (ks *Keystore) Unlock(name string, passphrase string) (crypto.PrivateKey, error) {
+	entry, err := ks.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	params := entry.Crypto
+	if params.KDF != kdfScrypt {
+		return nil, fmt.Errorf("unsupported key derivation function %s", params.KDF)
+	}
+
+	aead, err := newCipher(passphrase, params)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := aead.Open(nil, params.Nonce, params.Ciphertext, additionalData(entry))
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+
+	privateKey, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	return privateKey, nil
+}
+
+// UnlockSigner расшифровывает ключ подписи транзакций
+func (ks *Keystore) UnlockSigner(name string, passphrase string) (*ecdsa.PrivateKey, error) {
+	privateKey, err := ks.Unlock(name, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := privateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not a signing key", ErrWrongKeyType, name)
+	}
+
+	return signer, nil
+}
+
+// UnlockIdentity расшифровывает ключ личности, которым подписываются транзакции
+// и расшифровываются полученные сообщения
+func (ks *Keystore) UnlockIdentity(name string, passphrase string) (*identity.Identity, error) {
+	signer, err := ks.UnlockSigner(name, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return identity.FromPrivateKey(signer)
+}
+
+// UnlockDecrypter расшифровывает ключ для расшифровки сообщений
+func (ks *Keystore) UnlockDecrypter(name string, passphrase string) (*rsa.PrivateKey, error) {
+	privateKey, err := ks.Unlock(name, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypter, ok := privateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not an encryption key", ErrWrongKeyType, name)
+	}
+
+	return decrypter, nil
+}
+
+// UnlockPrekey расшифровывает X25519 ключ для сессий с прямой секретностью
+func (ks *Keystore) UnlockPrekey(name string, passphrase string) (*ecdh.PrivateKey, error) {
+	privateKey, err := ks.Unlock(name, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	prekey, ok := privateKey.(*ecdh.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not a prekey", ErrWrongKeyType, name)
+	}
+
+	return prekey, nil
+}
+
+func (ks *Keystore) store(name string, privateKey crypto.PrivateKey, passphrase string) (*Entry, error) {
+	if !keyNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidKeyName, name)
+	}
+
+	_, err := os.Stat(ks.path(name))
+	if err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrKeyExists, name)
+	}
+
+	keyType, publicKey, err := describeKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	publicKeyData, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+
+	entry := &Entry{
+		Name:      name,
+		Type:      keyType,
+		PublicKey: publicKeyData,
+		Created:   time.Now().Unix(),
+		Crypto: CryptoParams{
+			KDF:   kdfScrypt,
+			N:     ks.ScryptN,
+			R:     ks.ScryptR,
+			P:     ks.ScryptP,
+			Salt:  make([]byte, saltLength),
+			Nonce: make([]byte, 12),
+		},
+	}
+
+	_, err = rand.Read(entry.Crypto.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	_, err = rand.Read(entry.Crypto.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	aead, err := newCipher(passphrase, entry.Crypto)
+	if err != nil {
+		return nil, err
+	}
+	entry.Crypto.Ciphertext = aead.Seal(nil, entry.Crypto.Nonce, der, additionalData(entry))
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal key %s: %w", name, err)
+	}
+
+	err = os.WriteFile(ks.path(name), data, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write key %s: %w", name, err)
+	}
+
+	return entry, nil
+}
+
+func (ks *Keystore) path(name string) string {
+	return filepath.Join(ks.dir, name+entryExtension)
+}
+
+// describeKey определяет тип ключа и его открытую часть
+func describeKey(privateKey crypto.PrivateKey) (KeyType, crypto.PublicKey, error) {
+	switch key := privateKey.(type) {
+	case *ecdsa.PrivateKey:
+		if key.Curve != elliptic.P256() {
+			return "", nil, fmt.Errorf("%w: only P-256 signing keys are supported", ErrWrongKeyType)
+		}
+		return KeySigning, &key.PublicKey, nil
+	case *rsa.PrivateKey:
+		return KeyEncryption, &key.PublicKey, nil
+	case *ecdh.PrivateKey:
+		if key.Curve() != ecdh.X25519() {
+			return "", nil, fmt.Errorf("%w: only X25519 prekeys are supported", ErrWrongKeyType)
+		}
+		return KeyPrekey, key.PublicKey(), nil
+	default:
+		return "", nil, fmt.Errorf("%w: %T", ErrWrongKeyType, privateKey)
+	}
+}
+
+// newCipher выводит ключ AES-256-GCM из пароля
+func newCipher(passphrase string, params CryptoParams) (cipher.AEAD, error) {
+	key, err := kdf.Scrypt([]byte(passphrase), params.Salt, params.N, params.R, params.P, keyLength)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// additionalData связывает шифротекст с именем и типом ключа
+func additionalData(entry *Entry) []byte {
+	return []byte(entry.Name + ":" + string(entry.Type))
+}
@@ -0,0 +1,485 @@
+package mailbox
+
+import (
+	"blockchainStorage/internal/blockchain"
+	"blockchainStorage/internal/network"
+	"blockchainStorage/internal/storage"
+	"blockchainStorage/internal/transaction"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// CommandRegister регистрация получателя, Data содержит Request
+	CommandRegister = "mailregister"
+	// CommandFetchMail запрос сохраненных сообщений, Data содержит Request
+	CommandFetchMail = "fetchmail"
+	// CommandRegistered ответ на успешную регистрацию
+	CommandRegistered = "registered"
+	// CommandMail ответ на fetchmail, Data содержит список транзакций сообщений
+	CommandMail = "mail"
+	// CommandDenied ответ на запрос, не прошедший проверку
+	CommandDenied = "denied"
+
+	// Namespace префикс почтового ящика в storage.DataStore (см. DataStore.Namespace)
+	Namespace = "mailbox_"
+	// AccountPrefix регистрация получателя по адресу
+	AccountPrefix = "account_"
+	// QueuePrefix очередь идентификаторов сообщений получателя
+	QueuePrefix = "queue_"
+	// EnvelopePrefix сохраненное сообщение по идентификатору транзакции
+	EnvelopePrefix = "envelope_"
+	// AccountCountKey количество зарегистрированных получателей
+	AccountCountKey = "accounts"
+
+	// MaxQueue максимальное количество сообщений, хранимых для одного получателя
+	MaxQueue = 1000
+	// MaxFetch максимальное количество сообщений в одном ответе на fetchmail
+	MaxFetch = 100
+	// MaxAccounts максимальное количество получателей на одном узле
+	MaxAccounts = 10000
+)
+
+var (
+	ErrNotRegistered = errors.New("recipient is not registered on this mailbox")
+	ErrMailboxFull   = errors.New("recipient mailbox is full")
+	ErrTooManyUsers  = errors.New("mailbox has no room for new recipients")
+)
+
+type account struct {
+	Registered  int64
+	LastRequest int64
+}
+
+// envelope сообщение и получатели, еще не подтвердившие его получение
+type envelope struct {
+	Tx      *transaction.Transaction
+	Pending []string
+}
+
+// Mailbox хранит зашифрованные сообщения для зарегистрированных получателей,
+// пока они не в сети, и отдает их по запросу, подписанному ключом получателя.
+// Узел не может прочитать сообщения: они зашифрованы ключами получателей.
+type Mailbox struct {
+	mu sync.Mutex
+	// address адрес узла, который получатели указывают в запросах
+	address string
+	db      blockchain.DbInterface
+	kv      storage.KeyValueStore
+}
+
+// New создает почтовый ящик узла с адресом address, по которому к нему обращаются получатели
+func New(address string, db blockchain.DbInterface, kv storage.KeyValueStore) *Mailbox {
+	return &Mailbox{address: address, db: db, kv: kv}
+}
+
+// Register регистрирует получателя, подписавшего запрос. Сообщения, пришедшие
+// до регистрации, не сохраняются.
+func (mb *Mailbox) Register(request *Request) error {
+	if request.Action != ActionRegister {
+		return fmt.Errorf("%w: expected %q action", ErrInvalidRequest, ActionRegister)
+	}
+
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	recipient := request.Recipient()
+	acc, err := mb.account(recipient)
+	if err != nil && !errors.Is(err, ErrNotRegistered) {
+		return err
+	}
+
+	// Регистрация ничего не стоит, поэтому число получателей ограничено
+	var count int
+	isNew := acc == nil
+	if isNew {
+		_, err = mb.kv.Get(AccountCountKey, &count)
+		if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+			return fmt.Errorf("failed to load mailbox account count: %w", err)
+		}
+
+		if count >= MaxAccounts {
+			return ErrTooManyUsers
+		}
+
+		acc = &account{Registered: time.Now().UnixNano()}
+	}
+
+	err = mb.accept(request, acc)
+	if err != nil {
+		return err
+	}
+
+	if isNew {
+		err = mb.kv.Put(AccountCountKey, count+1)
+		if err != nil {
+			return fmt.Errorf("failed to save mailbox account count: %w", err)
+		}
+	}
+
+	return mb.saveAccount(recipient, acc)
+}
+
+// Deliver сохраняет сообщение для его зарегистрированных получателей и
+// возвращает их количество. Реализует delivery.Receiver.
+func (mb *Mailbox) Deliver(tx *transaction.Transaction) (int, error) {
+	if tx.Type != transaction.TypeMessage || tx.Pruned() {
+		return 0, nil
+	}
+
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	env, err := mb.envelope(tx.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	full := false
+	seen := make(map[string]bool)
+	for i, output := range tx.Outputs {
+		if len(output.EncryptedData) == 0 {
+			continue
+		}
+
+		// Сообщение на имя вида "@name" хранится для владельца имени
+		recipient, err := blockchain.OutputOwner(mb.db, tx, i)
+		if errors.Is(err, blockchain.ErrNameNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to resolve recipient of %s: %w", tx.ID, err)
+		}
+
+		if seen[recipient] {
+			continue
+		}
+		seen[recipient] = true
+
+		_, err = mb.account(recipient)
+		if errors.Is(err, ErrNotRegistered) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		queue, err := mb.queue(recipient)
+		if err != nil {
+			return 0, err
+		}
+
+		if contains(queue, tx.ID) {
+			queued++
+			continue
+		}
+
+		if len(queue) >= MaxQueue {
+			full = true
+			continue
+		}
+
+		err = mb.kv.Put(QueuePrefix+recipient, append(queue, tx.ID))
+		if err != nil {
+			return 0, fmt.Errorf("failed to save mailbox queue: %w", err)
+		}
+
+		env.Pending = append(env.Pending, recipient)
+		queued++
+	}
+
+	if queued == 0 {
+		if full {
+			return 0, ErrMailboxFull
+		}
+		return 0, nil
+	}
+
+	env.Tx = tx
+	err = mb.kv.Put(EnvelopePrefix+tx.ID, env)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save message %s: %w", tx.ID, err)
+	}
+
+	return queued, nil
+}
+
+// Fetch удаляет сообщения, получение которых подтверждено в request.Ack, и
+// возвращает до MaxFetch оставшихся сообщений получателя в порядке поступления
+func (mb *Mailbox) Fetch(request *Request) ([]*transaction.Transaction, error) {
+	if request.Action != ActionFetch {
+		return nil, fmt.Errorf("%w: expected %q action", ErrInvalidRequest, ActionFetch)
+	}
+
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	recipient := request.Recipient()
+	acc, err := mb.account(recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	err = mb.accept(request, acc)
+	if err != nil {
+		return nil, err
+	}
+
+	err = mb.saveAccount(recipient, acc)
+	if err != nil {
+		return nil, err
+	}
+
+	queue, err := mb.queue(recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := queue[:0]
+	for _, txID := range queue {
+		if !contains(request.Ack, txID) {
+			remaining = append(remaining, txID)
+			continue
+		}
+
+		err = mb.release(txID, recipient)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = mb.kv.Put(QueuePrefix+recipient, remaining)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save mailbox queue: %w", err)
+	}
+
+	var messages []*transaction.Transaction
+	for _, txID := range remaining {
+		if len(messages) == MaxFetch {
+			break
+		}
+
+		env, err := mb.envelope(txID)
+		if err != nil {
+			return nil, err
+		}
+
+		if env.Tx != nil {
+			messages = append(messages, env.Tx)
+		}
+	}
+
+	return messages, nil
+}
+
+// Watch сохраняет для зарегистрированных получателей сообщения из новых блоков.
+// Ошибки сохранения передаются в onError.
+func (mb *Mailbox) Watch(bc *blockchain.Blockchain, onError func(err error)) {
+	bc.Subscribe(func(block *blockchain.Block) {
+		for _, tx := range block.Transactions {
+			_, err := mb.Deliver(tx)
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	})
+}
+
+// accept проверяет запрос и запоминает его время, чтобы его нельзя было повторить
+func (mb *Mailbox) accept(request *Request, acc *account) error {
+	err := request.Verify(time.Now(), mb.address)
+	if err != nil {
+		return err
+	}
+
+	if request.Timestamp <= acc.LastRequest {
+		return ErrStaleRequest
+	}
+	acc.LastRequest = request.Timestamp
+
+	return nil
+}
+
+// release удаляет получателя из ожидающих сообщение и само сообщение, если
+// его получили все
+func (mb *Mailbox) release(txID string, recipient string) error {
+	env, err := mb.envelope(txID)
+	if err != nil {
+		return err
+	}
+
+	pending := env.Pending[:0]
+	for _, address := range env.Pending {
+		if address != recipient {
+			pending = append(pending, address)
+		}
+	}
+	env.Pending = pending
+
+	if len(env.Pending) == 0 {
+		err = mb.kv.Delete(EnvelopePrefix + txID)
+		if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+			return fmt.Errorf("failed to delete message %s: %w", txID, err)
+		}
+		return nil
+	}
+
+	err = mb.kv.Put(EnvelopePrefix+txID, env)
+	if err != nil {
+		return fmt.Errorf("failed to save message %s: %w", txID, err)
+	}
+
+	return nil
+}
+
+func (mb *Mailbox) account(recipient string) (*account, error) {
+	var acc account
+	_, err := mb.kv.Get(AccountPrefix+recipient, &acc)
+	if err != nil {
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrNotRegistered, recipient)
+		}
+		return nil, fmt.Errorf("failed to load mailbox account: %w", err)
+	}
+
+	return &acc, nil
+}
+
+func (mb *Mailbox) saveAccount(recipient string, acc *account) error {
+	err := mb.kv.Put(AccountPrefix+recipient, acc)
+	if err != nil {
+		return fmt.Errorf("failed to save mailbox account: %w", err)
+	}
+
+	return nil
+}
+
+func (mb *Mailbox) queue(recipient string) ([]string, error) {
+	var queue []string
+	_, err := mb.kv.Get(QueuePrefix+recipient, &queue)
+	if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		return nil, fmt.Errorf("failed to load mailbox queue: %w", err)
+	}
+
+	return queue, nil
+}
+
+func (mb *Mailbox) envelope(txID string) (*envelope, error) {
+	var env envelope
+	_, err := mb.kv.Get(EnvelopePrefix+txID, &env)
+	if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		return nil, fmt.Errorf("failed to load message %s: %w", txID, err)
+	}
+
+	return &env, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Register регистрирует получателя на почтовом ящике node
+func Register(n *network.Network, node string, privateKey *ecdsa.PrivateKey) error {
+	response, err := request(n, node, CommandRegister, ActionRegister, nil, privateKey)
+	if err != nil {
+		return err
+	}
+
+	if response.Command != CommandRegistered {
+		return fmt.Errorf("mailbox %s denied registration: %s", node, response.Data)
+	}
+
+	return nil
+}
+
+// Fetch получает сообщения получателя с почтового ящика node, подтверждая
+// получение сообщений ack из предыдущего ответа. Полученные сообщения можно
+// передать в inbox.Inbox.Deliver.
+func Fetch(n *network.Network, node string, privateKey *ecdsa.PrivateKey, ack []string) ([]*transaction.Transaction, error) {
+	response, err := request(n, node, CommandFetchMail, ActionFetch, ack, privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Command != CommandMail {
+		return nil, fmt.Errorf("mailbox %s denied fetch: %s", node, response.Data)
+	}
+
+	var messages []*transaction.Transaction
+	err = json.Unmarshal(response.Data, &messages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mail: %w", err)
+	}
+
+	return messages, nil
+}
+
+func request(n *network.Network, node string, command string, action string, ack []string, privateKey *ecdsa.PrivateKey) (*network.Message, error) {
+	req, err := NewRequest(action, node, ack, privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal mailbox request: %w", err)
+	}
+
+	return n.Request(node, command, data)
+}
+
+// HandleRegister регистрирует получателя по запросу mailregister
+func HandleRegister(mb *Mailbox, msg *network.Message, conn net.Conn) error {
+	var req Request
+	err := json.Unmarshal(msg.Data, &req)
+	if err == nil {
+		err = mb.Register(&req)
+	}
+
+	if err != nil {
+		replyErr := network.Reply(conn, CommandDenied, []byte(err.Error()))
+		if replyErr != nil {
+			return replyErr
+		}
+		return fmt.Errorf("failed to register mailbox recipient: %w", err)
+	}
+
+	return network.Reply(conn, CommandRegistered, nil)
+}
+
+// HandleFetchMail отвечает на запрос fetchmail сообщениями получателя
+func HandleFetchMail(mb *Mailbox, msg *network.Message, conn net.Conn) error {
+	var messages []*transaction.Transaction
+	var req Request
+	err := json.Unmarshal(msg.Data, &req)
+	if err == nil {
+		messages, err = mb.Fetch(&req)
+	}
+
+	if err != nil {
+		replyErr := network.Reply(conn, CommandDenied, []byte(err.Error()))
+		if replyErr != nil {
+			return replyErr
+		}
+		return fmt.Errorf("failed to fetch mail: %w", err)
+	}
+
+	data, err := json.Marshal(messages)
+	if err != nil {
+		return fmt.Errorf("failed to marshal mail: %w", err)
+	}
+
+	return network.Reply(conn, CommandMail, data)
+}
@@ -0,0 +1,258 @@
+package mailbox
+
+import (
+	"blockchainStorage/common"
+	"blockchainStorage/internal/blockchain"
+	"blockchainStorage/internal/identity"
+	"blockchainStorage/internal/network"
+	"blockchainStorage/internal/storage"
+	"blockchainStorage/internal/transaction"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testMailbox адрес почтового ящика в тестах без сети
+const testMailbox = "mailbox.test:8080"
+
+type identityResolver map[string]*identity.Identity
+
+func (r identityResolver) ResolveEncryptionKey(recipient string) (crypto.PublicKey, error) {
+	return &r[recipient].PrivateKey().PublicKey, nil
+}
+
+func newMessage(t *testing.T, resolver identityResolver, recipients ...*identity.Identity) *transaction.Transaction {
+	var outputs []transaction.MessageOutput
+	for _, recipient := range recipients {
+		outputs = append(outputs, transaction.MessageOutput{Recipient: recipient.Address(), EncryptedData: []byte("hello")})
+	}
+
+	tx, err := transaction.NewTransactionWithResolver(nil, outputs, resolver)
+	require.NoError(t, err)
+	return tx
+}
+
+func TestMailbox_StoreAndFetch(t *testing.T) {
+	alice, err := identity.Generate()
+	require.NoError(t, err)
+	bob, err := identity.Generate()
+	require.NoError(t, err)
+	resolver := identityResolver{alice.Address(): alice, bob.Address(): bob}
+
+	mb := New(testMailbox, blockchain.NewMockDbStorage(), storage.NewMemoryStore())
+
+	// Сообщения незарегистрированным получателям не сохраняются
+	stored, err := mb.Deliver(newMessage(t, resolver, alice))
+	require.NoError(t, err)
+	assert.Equal(t, 0, stored)
+
+	for _, id := range []*identity.Identity{alice, bob} {
+		request, err := NewRequest(ActionRegister, testMailbox, nil, id.PrivateKey())
+		require.NoError(t, err)
+		require.NoError(t, mb.Register(request))
+	}
+
+	shared := newMessage(t, resolver, alice, bob)
+	stored, err = mb.Deliver(shared)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stored)
+
+	// Повторная доставка не дублирует сообщение
+	_, err = mb.Deliver(shared)
+	require.NoError(t, err)
+
+	request, err := NewRequest(ActionFetch, testMailbox, nil, alice.PrivateKey())
+	require.NoError(t, err)
+	messages, err := mb.Fetch(request)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, shared.ID, messages[0].ID)
+	assert.Equal(t, shared.Outputs, messages[0].Outputs)
+
+	// Повтор запроса отклоняется
+	_, err = mb.Fetch(request)
+	assert.ErrorIs(t, err, ErrStaleRequest)
+
+	// Подпись чужим ключом не проходит проверку
+	forged, err := NewRequest(ActionFetch, testMailbox, nil, alice.PrivateKey())
+	require.NoError(t, err)
+	forged.PublicKey = bob.PublicKey()
+	_, err = mb.Fetch(forged)
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+
+	// Подтвержденное сообщение удаляется только из очереди alice
+	request, err = NewRequest(ActionFetch, testMailbox, []string{shared.ID}, alice.PrivateKey())
+	require.NoError(t, err)
+	messages, err = mb.Fetch(request)
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+
+	request, err = NewRequest(ActionFetch, testMailbox, []string{shared.ID}, bob.PrivateKey())
+	require.NoError(t, err)
+	messages, err = mb.Fetch(request)
+	require.NoError(t, err)
+	assert.Empty(t, messages, "acknowledged message is removed in the same request")
+
+	envelope, err := mb.envelope(shared.ID)
+	require.NoError(t, err)
+	assert.Nil(t, envelope.Tx, "message is deleted once every recipient acknowledged it")
+}
+
+func TestMailbox_AccountLimit(t *testing.T) {
+	alice, err := identity.Generate()
+	require.NoError(t, err)
+	bob, err := identity.Generate()
+	require.NoError(t, err)
+
+	kv := storage.NewMemoryStore()
+	mb := New(testMailbox, blockchain.NewMockDbStorage(), kv)
+
+	request, err := NewRequest(ActionRegister, testMailbox, nil, alice.PrivateKey())
+	require.NoError(t, err)
+	require.NoError(t, mb.Register(request))
+
+	var count int
+	_, err = kv.Get(AccountCountKey, &count)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// Когда мест нет, новый получатель не регистрируется
+	require.NoError(t, kv.Put(AccountCountKey, MaxAccounts))
+	request, err = NewRequest(ActionRegister, testMailbox, nil, bob.PrivateKey())
+	require.NoError(t, err)
+	assert.ErrorIs(t, mb.Register(request), ErrTooManyUsers)
+
+	_, err = mb.account(bob.Address())
+	assert.ErrorIs(t, err, ErrNotRegistered)
+
+	// Повторная регистрация существующего получателя не занимает нового места
+	request, err = NewRequest(ActionRegister, testMailbox, nil, alice.PrivateKey())
+	require.NoError(t, err)
+	require.NoError(t, mb.Register(request))
+}
+
+func TestMailbox_MessageToName(t *testing.T) {
+	alice, err := identity.Generate()
+	require.NoError(t, err)
+
+	db := blockchain.NewMockDbStorage()
+	record, err := json.Marshal(blockchain.NameRecord{Name: "alice", Owner: alice.Address()})
+	require.NoError(t, err)
+	require.NoError(t, db.SaveStateToDB(blockchain.NameStatePrefix+"alice", record))
+
+	mb := New(testMailbox, db, storage.NewMemoryStore())
+	request, err := NewRequest(ActionRegister, testMailbox, nil, alice.PrivateKey())
+	require.NoError(t, err)
+	require.NoError(t, mb.Register(request))
+
+	tx, err := transaction.NewTransactionWithResolver(nil, []transaction.MessageOutput{
+		{Recipient: "@alice", EncryptedData: []byte("hello")},
+		{Recipient: "@nobody", EncryptedData: []byte("lost")},
+	}, identityResolver{"@alice": alice, "@nobody": alice})
+	require.NoError(t, err)
+
+	// Сообщение на имя сохраняется для владельца имени, незарегистрированное имя пропускается
+	stored, err := mb.Deliver(tx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stored)
+
+	request, err = NewRequest(ActionFetch, testMailbox, nil, alice.PrivateKey())
+	require.NoError(t, err)
+	messages, err := mb.Fetch(request)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, tx.ID, messages[0].ID)
+}
+
+func TestRequest_Verify(t *testing.T) {
+	alice, err := identity.Generate()
+	require.NoError(t, err)
+
+	request, err := NewRequest(ActionFetch, testMailbox, nil, alice.PrivateKey())
+	require.NoError(t, err)
+	assert.NoError(t, request.Verify(time.Now(), testMailbox))
+	assert.Equal(t, alice.Address(), request.Recipient())
+
+	assert.ErrorIs(t, request.Verify(time.Now().Add(2*MaxClockSkew), testMailbox), ErrStaleRequest)
+
+	// Подпись запроса на регистрацию нельзя использовать для получения сообщений
+	request.Action = ActionRegister
+	assert.ErrorIs(t, request.Verify(time.Now(), testMailbox), ErrInvalidRequest)
+
+	request.Action = "delete"
+	assert.ErrorIs(t, request.Verify(time.Now(), testMailbox), ErrInvalidRequest)
+
+	// Запрос к одному почтовому ящику нельзя повторить на другом узле
+	request, err = NewRequest(ActionFetch, testMailbox, nil, alice.PrivateKey())
+	require.NoError(t, err)
+	assert.ErrorIs(t, request.Verify(time.Now(), "other.test:8080"), ErrInvalidRequest)
+
+	request.Mailbox = "other.test:8080"
+	assert.ErrorIs(t, request.Verify(time.Now(), "other.test:8080"), ErrInvalidRequest, "mailbox address is signed")
+}
+
+func TestMailbox_OverNetwork(t *testing.T) {
+	alice, err := identity.Generate()
+	require.NoError(t, err)
+	resolver := identityResolver{alice.Address(): alice}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+
+	node := fmt.Sprintf("127.0.0.1:%d", port)
+	mb := New(node, blockchain.NewMockDbStorage(), storage.NewMemoryStore())
+
+	server := &network.Network{}
+	go func() {
+		_ = server.StartServer(port, func(msg *network.Message, conn net.Conn) {
+			switch msg.Command {
+			case CommandRegister:
+				_ = HandleRegister(mb, msg, conn)
+			case CommandFetchMail:
+				_ = HandleFetchMail(mb, msg, conn)
+			}
+		})
+	}()
+
+	client := &network.Network{NodeList: []common.Node{{Address: node}}}
+
+	// Сервер запускается асинхронно
+	for attempt := 0; attempt < 50; attempt++ {
+		err = Register(client, node, alice.PrivateKey())
+		if err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.NoError(t, err)
+
+	tx := newMessage(t, resolver, alice)
+	_, err = mb.Deliver(tx)
+	require.NoError(t, err)
+
+	messages, err := Fetch(client, node, alice.PrivateKey(), nil)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+
+	plaintext, err := messages[0].DecryptOutput(0, alice.PrivateKey())
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello"), plaintext)
+
+	messages, err = Fetch(client, node, alice.PrivateKey(), []string{tx.ID})
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+
+	// Незарегистрированный получатель получает отказ
+	mallory, err := identity.Generate()
+	require.NoError(t, err)
+	_, err = Fetch(client, node, mallory.PrivateKey(), nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,120 @@
+package mailbox
+
+import (
+	"blockchainStorage/internal/identity"
+	"blockchainStorage/internal/transaction"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// ActionRegister регистрация получателя на узле-почтовом ящике
+	ActionRegister = "register"
+	// ActionFetch получение сохраненных для получателя сообщений
+	ActionFetch = "fetch"
+
+	// MaxClockSkew допустимое расхождение времени запроса и часов узла
+	MaxClockSkew = 5 * time.Minute
+
+	// requestDomain отделяет подписи запросов к почтовому ящику от других подписей
+	requestDomain = "blockchainChat mailbox"
+)
+
+var (
+	ErrInvalidRequest = errors.New("invalid mailbox request")
+	ErrStaleRequest   = errors.New("mailbox request is stale or replayed")
+)
+
+// Request запрос получателя к почтовому ящику. Подпись ключом получателя
+// доказывает право на его сообщения, а время запроса и адрес почтового ящика
+// защищают от повтора на этом и других узлах.
+type Request struct {
+	Action string
+	// Mailbox адрес узла-почтового ящика, которому адресован запрос
+	Mailbox   string
+	Timestamp int64
+	// Ack идентификаторы сообщений, полученных по предыдущему запросу, которые
+	// узел может удалить
+	Ack       []string `json:",omitempty"`
+	PublicKey []byte   `json:",omitempty"`
+	Signature []byte   `json:",omitempty"`
+}
+
+// NewRequest создает и подписывает запрос к почтовому ящику mailbox ключом получателя
+func NewRequest(action string, mailbox string, ack []string, privateKey *ecdsa.PrivateKey) (*Request, error) {
+	request := &Request{
+		Action:    action,
+		Mailbox:   mailbox,
+		Timestamp: time.Now().UnixNano(),
+		Ack:       ack,
+		PublicKey: identity.MarshalPublicKey(&privateKey.PublicKey),
+	}
+
+	hash, err := request.signingHash()
+	if err != nil {
+		return nil, err
+	}
+
+	request.Signature, err = ecdsa.SignASN1(rand.Reader, privateKey, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign mailbox request: %w", err)
+	}
+
+	return request, nil
+}
+
+// Recipient возвращает адрес получателя, подписавшего запрос
+func (r *Request) Recipient() string {
+	return transaction.SenderFromPublicKey(r.PublicKey)
+}
+
+// Verify проверяет действие, адресата, время и подпись запроса к почтовому
+// ящику mailbox относительно now
+func (r *Request) Verify(now time.Time, mailbox string) error {
+	if r.Action != ActionRegister && r.Action != ActionFetch {
+		return fmt.Errorf("%w: unknown action %q", ErrInvalidRequest, r.Action)
+	}
+
+	if r.Mailbox == "" || r.Mailbox != mailbox {
+		return fmt.Errorf("%w: addressed to mailbox %q", ErrInvalidRequest, r.Mailbox)
+	}
+
+	skew := now.Sub(time.Unix(0, r.Timestamp))
+	if skew > MaxClockSkew || skew < -MaxClockSkew {
+		return fmt.Errorf("%w: timestamp is out of range", ErrStaleRequest)
+	}
+
+	publicKey, err := identity.ParsePublicKey(r.PublicKey)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+
+	hash, err := r.signingHash()
+	if err != nil {
+		return err
+	}
+
+	if !ecdsa.VerifyASN1(publicKey, hash, r.Signature) {
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, transaction.ErrInvalidSignature)
+	}
+
+	return nil
+}
+
+func (r *Request) signingHash() ([]byte, error) {
+	unsigned := *r
+	unsigned.Signature = nil
+
+	data, err := json.Marshal(&unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal mailbox request: %w", err)
+	}
+
+	hash := sha256.Sum256(append([]byte(requestDomain), data...))
+	return hash[:], nil
+}
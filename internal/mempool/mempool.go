@@ -0,0 +1,279 @@
+package mempool
+
+import (
+	"blockchainStorage/internal/blockchain"
+	"blockchainStorage/internal/transaction"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Ordering порядок выдачи транзакций из пула
+type Ordering int
+
+const (
+	// OrderByArrival выдает транзакции в порядке поступления
+	OrderByArrival Ordering = iota
+	// OrderByFee выдает транзакции по убыванию комиссии
+	OrderByFee
+)
+
+const (
+	DefaultMaxSize      = 5000
+	DefaultMaxPerSender = 100
+	DefaultTTL          = 24 * time.Hour
+)
+
+var (
+	ErrDuplicate   = errors.New("transaction already in mempool")
+	ErrPoolFull    = errors.New("mempool is full")
+	ErrSenderLimit = errors.New("sender exceeded mempool limit")
+	ErrNilTx       = errors.New("transaction is nil")
+	ErrBadStamp    = errors.New("transaction has no valid proof-of-work stamp")
+)
+
+// Config параметры пула неподтвержденных транзакций
+type Config struct {
+	MaxSize      int
+	MaxPerSender int
+	TTL          time.Duration
+	Ordering     Ordering
+	// StampDifficulty сложность штампа, обязательного для транзакций без комиссии.
+	// Ноль отключает проверку.
+	StampDifficulty int
+}
+
+// DefaultConfig возвращает параметры пула по умолчанию
+func DefaultConfig() Config {
+	return Config{
+		MaxSize:      DefaultMaxSize,
+		MaxPerSender: DefaultMaxPerSender,
+		TTL:          DefaultTTL,
+		Ordering:     OrderByFee,
+	}
+}
+
+type entry struct {
+	tx       *transaction.Transaction
+	arrival  uint64
+	received time.Time
+}
+
+// Mempool хранит неподтвержденные транзакции до их включения в блок
+type Mempool struct {
+	mu       sync.Mutex
+	config   Config
+	entries  map[string]*entry
+	bySender map[string]int
+	counter  uint64
+	validate func(tx *transaction.Transaction) error
+	now      func() time.Time
+}
+
+func NewMempool(config Config) *Mempool {
+	return &Mempool{
+		config:   config,
+		entries:  make(map[string]*entry),
+		bySender: make(map[string]int),
+		now:      time.Now,
+	}
+}
+
+// SetValidator задает проверку транзакций относительно состояния цепочки,
+// например blockchain.Blockchain.ValidateTransaction
+func (mp *Mempool) SetValidator(validate func(tx *transaction.Transaction) error) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.validate = validate
+}
+
+// Add добавляет транзакцию в пул с проверкой дубликатов и лимитов
+func (mp *Mempool) Add(tx *transaction.Transaction) error {
+	if tx == nil {
+		return ErrNilTx
+	}
+
+	if mp.config.StampDifficulty > 0 && tx.Fee == 0 &&
+		!blockchain.VerifyTransactionStamp(tx, mp.config.StampDifficulty) {
+		return ErrBadStamp
+	}
+
+	mp.mu.Lock()
+	validate := mp.validate
+	mp.mu.Unlock()
+
+	if validate != nil {
+		err := validate(tx)
+		if err != nil {
+			return fmt.Errorf("invalid transaction %s: %w", tx.ID, err)
+		}
+	}
+
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.expireLocked()
+
+	if _, exists := mp.entries[tx.ID]; exists {
+		return ErrDuplicate
+	}
+
+	if mp.config.MaxPerSender > 0 && mp.bySender[tx.Sender] >= mp.config.MaxPerSender {
+		return ErrSenderLimit
+	}
+
+	if mp.config.MaxSize > 0 && len(mp.entries) >= mp.config.MaxSize {
+		if !mp.evictLocked(tx) {
+			return ErrPoolFull
+		}
+	}
+
+	mp.counter++
+	mp.entries[tx.ID] = &entry{
+		tx:       tx,
+		arrival:  mp.counter,
+		received: mp.now(),
+	}
+	mp.bySender[tx.Sender]++
+
+	return nil
+}
+
+// Remove удаляет транзакции из пула, например после их подтверждения в блоке
+func (mp *Mempool) Remove(ids ...string) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	for _, id := range ids {
+		mp.removeLocked(id)
+	}
+}
+
+// Has проверяет наличие транзакции в пуле
+func (mp *Mempool) Has(id string) bool {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	_, exists := mp.entries[id]
+	return exists
+}
+
+// Get возвращает транзакцию из пула по ее идентификатору
+func (mp *Mempool) Get(id string) (*transaction.Transaction, bool) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	e, exists := mp.entries[id]
+	if !exists {
+		return nil, false
+	}
+
+	return e.tx, true
+}
+
+// Size возвращает количество транзакций в пуле
+func (mp *Mempool) Size() int {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	return len(mp.entries)
+}
+
+// Pending возвращает до limit транзакций в порядке, заданном конфигурацией пула
+func (mp *Mempool) Pending(limit int) []*transaction.Transaction {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.expireLocked()
+
+	sorted := mp.sortedLocked()
+	if limit > 0 && len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+
+	txs := make([]*transaction.Transaction, len(sorted))
+	for i, e := range sorted {
+		txs[i] = e.tx
+	}
+
+	return txs
+}
+
+// Expire удаляет из пула транзакции с истекшим сроком ожидания
+func (mp *Mempool) Expire() int {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	return mp.expireLocked()
+}
+
+func (mp *Mempool) expireLocked() int {
+	if mp.config.TTL <= 0 {
+		return 0
+	}
+
+	deadline := mp.now().Add(-mp.config.TTL)
+	expired := 0
+	for id, e := range mp.entries {
+		if e.received.Before(deadline) {
+			mp.removeLocked(id)
+			expired++
+		}
+	}
+
+	return expired
+}
+
+// evictLocked освобождает место для новой транзакции, вытесняя транзакцию
+// с наименьшим приоритетом. При упорядочивании по поступлению вытеснение не
+// выполняется, чтобы не терять давно ожидающие сообщения.
+func (mp *Mempool) evictLocked(tx *transaction.Transaction) bool {
+	if mp.config.Ordering != OrderByFee {
+		return false
+	}
+
+	sorted := mp.sortedLocked()
+	if len(sorted) == 0 {
+		return false
+	}
+
+	lowest := sorted[len(sorted)-1]
+	if lowest.tx.Fee >= tx.Fee {
+		return false
+	}
+
+	mp.removeLocked(lowest.tx.ID)
+	return true
+}
+
+func (mp *Mempool) removeLocked(id string) {
+	e, exists := mp.entries[id]
+	if !exists {
+		return
+	}
+
+	delete(mp.entries, id)
+	mp.bySender[e.tx.Sender]--
+	if mp.bySender[e.tx.Sender] <= 0 {
+		delete(mp.bySender, e.tx.Sender)
+	}
+}
+
+func (mp *Mempool) sortedLocked() []*entry {
+	sorted := make([]*entry, 0, len(mp.entries))
+	for _, e := range mp.entries {
+		sorted = append(sorted, e)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if mp.config.Ordering == OrderByFee && sorted[i].tx.Fee != sorted[j].tx.Fee {
+			return sorted[i].tx.Fee > sorted[j].tx.Fee
+		}
+		return sorted[i].arrival < sorted[j].arrival
+	})
+
+	return sorted
+}
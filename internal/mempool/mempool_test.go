@@ -0,0 +1,223 @@
+package mempool
+
+import (
+	"blockchainStorage/internal/blockchain"
+	"blockchainStorage/internal/network"
+	"blockchainStorage/internal/transaction"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestTx(id string, sender string, fee int64) *transaction.Transaction {
+	return &transaction.Transaction{
+		ID:     id,
+		Sender: sender,
+		Fee:    fee,
+	}
+}
+
+func TestMempool_AddDuplicate(t *testing.T) {
+	mp := NewMempool(DefaultConfig())
+
+	err := mp.Add(newTestTx("tx1", "alice", 1))
+	if err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+
+	// Повторное добавление транзакции с тем же ID должно отклоняться
+	err = mp.Add(newTestTx("tx1", "alice", 1))
+	if err != ErrDuplicate {
+		t.Errorf("expected ErrDuplicate, got %v", err)
+	}
+
+	if mp.Size() != 1 {
+		t.Errorf("expected mempool size 1, got %d", mp.Size())
+	}
+}
+
+func TestMempool_SenderLimit(t *testing.T) {
+	mp := NewMempool(Config{MaxSize: 10, MaxPerSender: 2})
+
+	_ = mp.Add(newTestTx("tx1", "alice", 0))
+	_ = mp.Add(newTestTx("tx2", "alice", 0))
+
+	err := mp.Add(newTestTx("tx3", "alice", 0))
+	if err != ErrSenderLimit {
+		t.Errorf("expected ErrSenderLimit, got %v", err)
+	}
+
+	// Другой отправитель не затронут лимитом
+	err = mp.Add(newTestTx("tx4", "bob", 0))
+	if err != nil {
+		t.Errorf("failed to add transaction from another sender: %v", err)
+	}
+
+	// После удаления транзакции отправитель снова может добавлять сообщения
+	mp.Remove("tx1")
+	err = mp.Add(newTestTx("tx3", "alice", 0))
+	if err != nil {
+		t.Errorf("failed to add transaction after removal: %v", err)
+	}
+}
+
+func TestMempool_OrderingAndEviction(t *testing.T) {
+	mp := NewMempool(Config{MaxSize: 2, Ordering: OrderByFee})
+
+	_ = mp.Add(newTestTx("low", "alice", 1))
+	_ = mp.Add(newTestTx("high", "bob", 5))
+
+	// Транзакция с меньшей комиссией не вытесняет существующие
+	err := mp.Add(newTestTx("lower", "carol", 1))
+	if err != ErrPoolFull {
+		t.Errorf("expected ErrPoolFull, got %v", err)
+	}
+
+	// Транзакция с большей комиссией вытесняет транзакцию с наименьшей
+	err = mp.Add(newTestTx("higher", "carol", 3))
+	if err != nil {
+		t.Fatalf("failed to add transaction with higher fee: %v", err)
+	}
+
+	if mp.Has("low") {
+		t.Error("expected transaction with lowest fee to be evicted")
+	}
+
+	pending := mp.Pending(0)
+	expected := []string{"high", "higher"}
+	if len(pending) != len(expected) {
+		t.Fatalf("expected %d pending transactions, got %d", len(expected), len(pending))
+	}
+	for i, id := range expected {
+		if pending[i].ID != id {
+			t.Errorf("pending[%d]: got %s, expected %s", i, pending[i].ID, id)
+		}
+	}
+}
+
+func TestMempool_OrderByArrival(t *testing.T) {
+	mp := NewMempool(Config{Ordering: OrderByArrival})
+
+	_ = mp.Add(newTestTx("first", "alice", 1))
+	_ = mp.Add(newTestTx("second", "bob", 10))
+	_ = mp.Add(newTestTx("third", "carol", 5))
+
+	pending := mp.Pending(2)
+	if len(pending) != 2 || pending[0].ID != "first" || pending[1].ID != "second" {
+		t.Errorf("unexpected pending order: %+v", pending)
+	}
+}
+
+func TestMempool_Expire(t *testing.T) {
+	mp := NewMempool(Config{TTL: time.Minute})
+
+	now := time.Now()
+	mp.now = func() time.Time { return now }
+	_ = mp.Add(newTestTx("old", "alice", 0))
+
+	// Сдвигаем время за пределы срока ожидания транзакции
+	mp.now = func() time.Time { return now.Add(2 * time.Minute) }
+	_ = mp.Add(newTestTx("new", "bob", 0))
+
+	expired := mp.Expire()
+	if expired != 0 {
+		t.Errorf("expected expired transactions to be removed on add, got %d removed now", expired)
+	}
+
+	if mp.Has("old") {
+		t.Error("expected expired transaction to be removed")
+	}
+	if !mp.Has("new") {
+		t.Error("expected fresh transaction to stay in mempool")
+	}
+}
+
+func TestMempool_Validator(t *testing.T) {
+	mp := NewMempool(DefaultConfig())
+	mp.SetValidator(func(tx *transaction.Transaction) error {
+		if tx.Sequence == 0 {
+			return errors.New("sequence required")
+		}
+		return nil
+	})
+
+	err := mp.Add(newTestTx("tx1", "alice", 0))
+	if err == nil {
+		t.Error("expected validator to reject transaction")
+	}
+
+	tx := newTestTx("tx2", "alice", 0)
+	tx.Sequence = 1
+	err = mp.Add(tx)
+	if err != nil {
+		t.Errorf("failed to add valid transaction: %v", err)
+	}
+}
+
+func TestMempool_StampDifficulty(t *testing.T) {
+	mp := NewMempool(Config{StampDifficulty: 2})
+
+	// Бесплатная транзакция без штампа отклоняется
+	err := mp.Add(newTestTx("tx1", "alice", 0))
+	if err != ErrBadStamp {
+		t.Errorf("expected ErrBadStamp, got %v", err)
+	}
+
+	stamped := newTestTx("tx1", "alice", 0)
+	err = blockchain.StampTransaction(stamped, 2)
+	if err != nil {
+		t.Fatalf("failed to stamp transaction: %v", err)
+	}
+	err = mp.Add(stamped)
+	if err != nil {
+		t.Errorf("failed to add stamped transaction: %v", err)
+	}
+
+	// Транзакция с комиссией принимается без штампа
+	err = mp.Add(newTestTx("tx2", "bob", 1))
+	if err != nil {
+		t.Errorf("failed to add transaction with fee: %v", err)
+	}
+}
+
+func TestHandleTransaction(t *testing.T) {
+	mp := NewMempool(DefaultConfig())
+	n := &network.Network{}
+
+	data, err := newTestTx("tx1", "alice", 1).Serialize()
+	if err != nil {
+		t.Fatalf("failed to serialize transaction: %v", err)
+	}
+
+	err = HandleTransaction(mp, n, &network.Message{Command: CommandTx, Data: data})
+	if err != nil {
+		t.Fatalf("failed to handle transaction: %v", err)
+	}
+
+	if !mp.Has("tx1") {
+		t.Errorf("expected transaction in mempool")
+	}
+
+	// Повторно полученная транзакция не считается ошибкой и не пересылается
+	err = HandleTransaction(mp, n, &network.Message{Command: CommandTx, Data: data})
+	if err != nil {
+		t.Errorf("expected duplicate to be ignored, got %v", err)
+	}
+
+	// Транзакция, отклоненная пулом, возвращает ошибку
+	mp.SetValidator(func(tx *transaction.Transaction) error {
+		return errors.New("rejected")
+	})
+	data, err = newTestTx("tx2", "alice", 1).Serialize()
+	if err != nil {
+		t.Fatalf("failed to serialize transaction: %v", err)
+	}
+
+	err = HandleTransaction(mp, n, &network.Message{Command: CommandTx, Data: data})
+	if err == nil {
+		t.Errorf("expected rejected transaction to return an error")
+	}
+	if mp.Has("tx2") {
+		t.Errorf("rejected transaction must not be added")
+	}
+}
@@ -0,0 +1,46 @@
+package mempool
+
+import (
+	"blockchainStorage/internal/network"
+	"blockchainStorage/internal/transaction"
+	"errors"
+	"fmt"
+)
+
+// CommandTx неподтвержденная транзакция, Data содержит сериализованную транзакцию
+const CommandTx = "tx"
+
+// Submit добавляет транзакцию в локальный пул и рассылает ее узлам сети
+func Submit(pool *Mempool, n *network.Network, tx *transaction.Transaction) error {
+	err := pool.Add(tx)
+	if err != nil {
+		return err
+	}
+
+	data, err := tx.Serialize()
+	if err != nil {
+		return fmt.Errorf("failed to serialize transaction: %w", err)
+	}
+
+	return n.Broadcast(CommandTx, data)
+}
+
+// HandleTransaction добавляет транзакцию, полученную командой tx, в пул и
+// передает ее дальше, если пул ее принял. Уже известные транзакции не
+// пересылаются повторно, поэтому рассылка не зацикливается.
+func HandleTransaction(pool *Mempool, n *network.Network, msg *network.Message) error {
+	tx, err := transaction.DeserializeTransaction(msg.Data)
+	if err != nil {
+		return fmt.Errorf("failed to deserialize transaction: %w", err)
+	}
+
+	err = pool.Add(tx)
+	if errors.Is(err, ErrDuplicate) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return n.Broadcast(CommandTx, msg.Data)
+}
@@ -0,0 +1,135 @@
+package mnemonic
+
+import (
+	"blockchainStorage/internal/kdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// SeedLength длина seed, получаемого из мнемонической фразы
+const SeedLength = 64
+
+var (
+	ErrInvalidEntropy  = errors.New("entropy length must be 128-256 bits and a multiple of 32")
+	ErrInvalidMnemonic = errors.New("invalid mnemonic")
+	ErrInvalidChecksum = errors.New("invalid mnemonic checksum")
+
+	//go:embed english.txt
+	englishWordlist string
+
+	// English список слов BIP39
+	English   = strings.Fields(englishWordlist)
+	wordIndex = buildIndex(English)
+)
+
+func buildIndex(words []string) map[string]int {
+	index := make(map[string]int, len(words))
+	for i, word := range words {
+		index[word] = i
+	}
+	return index
+}
+
+// New создает мнемоническую фразу из bits случайных бит (128 бит - 12 слов, 256 бит - 24 слова)
+func New(bits int) (string, error) {
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", ErrInvalidEntropy
+	}
+
+	entropy := make([]byte, bits/8)
+	_, err := rand.Read(entropy)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate entropy: %w", err)
+	}
+
+	return FromEntropy(entropy)
+}
+
+// FromEntropy кодирует энтропию в мнемоническую фразу по BIP39
+func FromEntropy(entropy []byte) (string, error) {
+	bits := len(entropy) * 8
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", ErrInvalidEntropy
+	}
+
+	checksumBits := bits / 32
+	hash := sha256.Sum256(entropy)
+
+	// Энтропия дополняется первыми битами хэша, затем делится на группы по 11 бит
+	value := new(big.Int).SetBytes(entropy)
+	value.Lsh(value, uint(checksumBits))
+	value.Or(value, big.NewInt(int64(hash[0]>>(8-checksumBits))))
+
+	wordCount := (bits + checksumBits) / 11
+	words := make([]string, wordCount)
+	mask := big.NewInt(2047)
+	index := new(big.Int)
+	for i := wordCount - 1; i >= 0; i-- {
+		index.And(value, mask)
+		words[i] = English[index.Int64()]
+		value.Rsh(value, 11)
+	}
+
+	return strings.Join(words, " "), nil
+}
+
+// ToEntropy декодирует мнемоническую фразу в энтропию с проверкой контрольной суммы
+func ToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, fmt.Errorf("%w: unexpected number of words %d", ErrInvalidMnemonic, len(words))
+	}
+
+	value := new(big.Int)
+	for _, word := range words {
+		index, exists := wordIndex[word]
+		if !exists {
+			return nil, fmt.Errorf("%w: unknown word %q", ErrInvalidMnemonic, word)
+		}
+
+		value.Lsh(value, 11)
+		value.Or(value, big.NewInt(int64(index)))
+	}
+
+	totalBits := len(words) * 11
+	checksumBits := totalBits / 33
+	entropyBits := totalBits - checksumBits
+
+	checksum := new(big.Int).And(value, big.NewInt(int64(1<<checksumBits-1)))
+	value.Rsh(value, uint(checksumBits))
+
+	entropy := make([]byte, entropyBits/8)
+	value.FillBytes(entropy)
+
+	hash := sha256.Sum256(entropy)
+	if int64(hash[0]>>(8-checksumBits)) != checksum.Int64() {
+		return nil, ErrInvalidChecksum
+	}
+
+	return entropy, nil
+}
+
+// Validate проверяет слова и контрольную сумму мнемонической фразы
+func Validate(mnemonic string) error {
+	_, err := ToEntropy(mnemonic)
+	return err
+}
+
+// Seed вычисляет seed из мнемонической фразы и необязательного пароля по BIP39.
+// Фраза и пароль используются без NFKD-нормализации, поэтому пароль следует
+// ограничивать ASCII-символами.
+func Seed(mnemonic string, passphrase string) ([]byte, error) {
+	err := Validate(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+
+	normalized := strings.Join(strings.Fields(mnemonic), " ")
+	return kdf.PBKDF2([]byte(normalized), []byte("mnemonic"+passphrase), 2048, SeedLength, sha512.New), nil
+}
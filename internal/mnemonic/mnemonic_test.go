@@ -0,0 +1,76 @@
+package mnemonic
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWordlist(t *testing.T) {
+	if len(English) != 2048 {
+		t.Fatalf("Ожидалось 2048 слов, получено %d", len(English))
+	}
+	if English[0] != "abandon" || English[2047] != "zoo" {
+		t.Errorf("Неожиданные границы списка слов: %s, %s", English[0], English[2047])
+	}
+}
+
+func TestFromEntropyAndSeed(t *testing.T) {
+	// Тестовый вектор BIP39 для нулевой энтропии
+	phrase, err := FromEntropy(make([]byte, 16))
+	if err != nil {
+		t.Fatalf("Ошибка кодирования энтропии: %v", err)
+	}
+
+	expected := strings.Repeat("abandon ", 11) + "about"
+	if phrase != expected {
+		t.Fatalf("Ожидалась фраза %q, получена %q", expected, phrase)
+	}
+
+	seed, err := Seed(phrase, "TREZOR")
+	if err != nil {
+		t.Fatalf("Ошибка вычисления seed: %v", err)
+	}
+
+	expectedSeed := "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"
+	if hex.EncodeToString(seed) != expectedSeed {
+		t.Errorf("Неверный seed: %x", seed)
+	}
+}
+
+func TestNewAndValidate(t *testing.T) {
+	for _, bits := range []int{128, 160, 192, 224, 256} {
+		phrase, err := New(bits)
+		if err != nil {
+			t.Fatalf("Ошибка генерации фразы из %d бит: %v", bits, err)
+		}
+
+		words := len(strings.Fields(phrase))
+		if words != (bits+bits/32)/11 {
+			t.Errorf("Неверное количество слов для %d бит: %d", bits, words)
+		}
+
+		err = Validate(phrase)
+		if err != nil {
+			t.Errorf("Сгенерированная фраза не прошла проверку: %v", err)
+		}
+	}
+
+	_, err := New(100)
+	if !errors.Is(err, ErrInvalidEntropy) {
+		t.Errorf("Ожидалась ошибка ErrInvalidEntropy, получено %v", err)
+	}
+
+	// Замена последнего слова нарушает контрольную сумму
+	phrase := strings.Repeat("abandon ", 12)
+	err = Validate(phrase)
+	if !errors.Is(err, ErrInvalidChecksum) {
+		t.Errorf("Ожидалась ошибка ErrInvalidChecksum, получено %v", err)
+	}
+
+	err = Validate(strings.Repeat("abandon ", 11) + "aboot")
+	if !errors.Is(err, ErrInvalidMnemonic) {
+		t.Errorf("Ожидалась ошибка ErrInvalidMnemonic, получено %v", err)
+	}
+}
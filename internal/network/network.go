@@ -4,7 +4,16 @@ import (
 	network "blockchainStorage/common"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
+	"time"
+)
+
+const (
+	// MaxMessageSize ограничивает размер входящего сообщения
+	MaxMessageSize = 4 << 20
+	// RequestTimeout время ожидания ответа на запрос к узлу
+	RequestTimeout = 10 * time.Second
 )
 
 type Message struct {
@@ -65,15 +74,10 @@ func (n *Network) StartServer(port int, handler func(msg *Message, conn net.Conn
 		go func() {
 			defer conn.Close()
 
-			buf := make([]byte, 1024)
-			n, err := conn.Read(buf)
-			if err != nil {
-				// Handle error
-				return
-			}
-
+			// Сообщение читается целиком, а не одним вызовом Read, так как блоки
+			// и фрагменты вложений не помещаются в один пакет
 			var msg Message
-			err = json.Unmarshal(buf[:n], &msg)
+			err := json.NewDecoder(io.LimitReader(conn, MaxMessageSize)).Decode(&msg)
 			if err != nil {
 				// Handle error
 				return
@@ -83,3 +87,45 @@ func (n *Network) StartServer(port int, handler func(msg *Message, conn net.Conn
 		}()
 	}
 }
+
+// Request отправляет сообщение узлу address и ожидает ответ в том же соединении
+func (n *Network) Request(address string, command string, data []byte) (*Message, error) {
+	conn, err := net.DialTimeout("tcp", address, RequestTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(RequestTimeout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set deadline: %w", err)
+	}
+
+	err = Reply(conn, command, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var response Message
+	err = json.NewDecoder(io.LimitReader(conn, MaxMessageSize)).Decode(&response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %w", address, err)
+	}
+
+	return &response, nil
+}
+
+// Reply отправляет сообщение в открытое соединение, например ответ на запрос
+func Reply(conn net.Conn, command string, data []byte) error {
+	payload, err := json.Marshal(&Message{command, data})
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	_, err = conn.Write(payload)
+	if err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package presence
+
+import (
+	"blockchainStorage/internal/blockchain"
+	"blockchainStorage/internal/identity"
+	"blockchainStorage/internal/network"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keyMap ключи пользователей по адресу
+type keyMap map[string]*blockchain.KeyRecord
+
+func (m keyMap) LookupKeys(address string) (*blockchain.KeyRecord, error) {
+	record, exists := m[address]
+	if !exists {
+		return nil, blockchain.ErrKeyNotRegistered
+	}
+	return record, nil
+}
+
+// registered реестр, в котором опубликованы ключи ids
+func registered(ids ...*identity.Identity) keyMap {
+	keys := keyMap{}
+	for _, id := range ids {
+		keys[id.Address()] = &blockchain.KeyRecord{Address: id.Address()}
+	}
+	return keys
+}
+
+func TestSignal_Verify(t *testing.T) {
+	alice, err := identity.Generate()
+	require.NoError(t, err)
+	bob, err := identity.Generate()
+	require.NoError(t, err)
+
+	signal, err := NewSignal(StatusOnline, &bob.PrivateKey().PublicKey, time.Minute, alice.PrivateKey())
+	require.NoError(t, err)
+	assert.NoError(t, signal.Verify(time.Now()))
+	assert.Equal(t, alice.Address(), signal.Sender())
+
+	assert.ErrorIs(t, signal.Verify(time.Now().Add(2*time.Minute)), ErrSignalExpired)
+	assert.ErrorIs(t, signal.Verify(time.Now().Add(-time.Minute)), ErrInvalidSignal)
+
+	// Подпись не переносится на измененный сигнал
+	forged := *signal
+	forged.Status = StatusAway
+	assert.ErrorIs(t, forged.Verify(time.Now()), ErrInvalidSignal)
+
+	forged = *signal
+	forged.PublicKey = bob.PublicKey()
+	assert.ErrorIs(t, forged.Verify(time.Now()), ErrInvalidSignal)
+
+	_, err = NewSignal(StatusOnline, nil, MaxTTL+time.Second, alice.PrivateKey())
+	assert.ErrorIs(t, err, ErrInvalidSignal)
+	_, err = NewSignal("busy", nil, time.Minute, alice.PrivateKey())
+	assert.ErrorIs(t, err, ErrInvalidSignal)
+	_, err = NewSignal(StatusOffline, &bob.PrivateKey().PublicKey, time.Minute, alice.PrivateKey())
+	assert.ErrorIs(t, err, ErrInvalidSignal)
+}
+
+func mustSigningHash(t *testing.T, signal *Signal) []byte {
+	hash, err := signal.signingHash()
+	require.NoError(t, err)
+	return hash
+}
+
+func TestTable_AddAndExpire(t *testing.T) {
+	alice, err := identity.Generate()
+	require.NoError(t, err)
+	bob, err := identity.Generate()
+	require.NoError(t, err)
+
+	mallory, err := identity.Generate()
+	require.NoError(t, err)
+
+	now := time.Now()
+	table := NewTable(registered(alice, bob, mallory))
+	table.now = func() time.Time { return now }
+
+	typing, err := NewSignal(StatusOnline, &bob.PrivateKey().PublicKey, time.Minute, alice.PrivateKey())
+	require.NoError(t, err)
+
+	relay, err := table.Add(typing, "peer1")
+	require.NoError(t, err)
+	assert.True(t, relay)
+
+	// Повтор того же сигнала от другого узла не передается дальше
+	relay, err = table.Add(typing, "peer2")
+	require.NoError(t, err)
+	assert.False(t, relay)
+
+	// Набор текста видит только собеседник
+	entry, exists := table.Get(alice.Address())
+	require.True(t, exists)
+	assert.Equal(t, StatusOnline, entry.Status)
+	assert.True(t, entry.TypingTo(bob))
+	assert.False(t, entry.TypingTo(mallory))
+	assert.NotContains(t, string(entry.Typing), bob.Address())
+
+	// Чужой зашифрованный признак нельзя выдать за свой
+	copied, err := NewSignal(StatusOnline, nil, time.Minute, mallory.PrivateKey())
+	require.NoError(t, err)
+	copied.Typing = typing.Typing
+	copied.Signature, err = ecdsa.SignASN1(rand.Reader, mallory.PrivateKey(), mustSigningHash(t, &copied.Signal))
+	require.NoError(t, err)
+	_, err = table.Add(copied, "peer1")
+	require.NoError(t, err)
+	entry, exists = table.Get(mallory.Address())
+	require.True(t, exists)
+	assert.False(t, entry.TypingTo(bob))
+
+	// Набор текста гаснет раньше статуса
+	now = now.Add(TypingTimeout + time.Second)
+	entry, exists = table.Get(alice.Address())
+	require.True(t, exists)
+	assert.Equal(t, StatusOnline, entry.Status)
+	assert.Empty(t, entry.Typing)
+
+	away, err := NewSignal(StatusAway, nil, time.Minute, bob.PrivateKey())
+	require.NoError(t, err)
+	_, err = table.Add(away, "peer1")
+	require.NoError(t, err)
+
+	entries := table.List()
+	require.Len(t, entries, 3)
+	assert.True(t, entries[0].Address < entries[1].Address)
+
+	// Истекшие сигналы удаляются из таблицы
+	now = now.Add(time.Minute)
+	assert.Empty(t, table.List())
+	_, exists = table.Get(alice.Address())
+	assert.False(t, exists)
+}
+
+func TestTable_RateLimit(t *testing.T) {
+	alice, err := identity.Generate()
+	require.NoError(t, err)
+
+	now := time.Now()
+	table := NewTable(registered(alice))
+	table.now = func() time.Time { return now }
+
+	for i := 0; i < RateLimit; i++ {
+		signal, err := NewSignal(StatusOnline, nil, time.Minute, alice.PrivateKey())
+		require.NoError(t, err)
+		_, err = table.Add(signal, "peer")
+		require.NoError(t, err)
+	}
+
+	signal, err := NewSignal(StatusOnline, nil, time.Minute, alice.PrivateKey())
+	require.NoError(t, err)
+	_, err = table.Add(signal, "other peer")
+	assert.ErrorIs(t, err, ErrRateLimited)
+
+	// В следующем окне сигналы снова принимаются
+	now = now.Add(RateWindow)
+	signal, err = NewSignal(StatusOnline, nil, time.Minute, alice.PrivateKey())
+	require.NoError(t, err)
+	relay, err := table.Add(signal, "peer")
+	require.NoError(t, err)
+	assert.True(t, relay)
+
+	// Узел, передающий слишком много сигналов, ограничивается независимо от подписавших
+	rates := make(map[string]*rate)
+	for i := 0; i < PeerRateLimit; i++ {
+		require.NoError(t, limit(rates, "peer", PeerRateLimit, now))
+	}
+	assert.ErrorIs(t, limit(rates, "peer", PeerRateLimit, now), ErrRateLimited)
+	assert.NoError(t, limit(rates, "other peer", PeerRateLimit, now))
+}
+
+func TestTable_Admission(t *testing.T) {
+	alice, err := identity.Generate()
+	require.NoError(t, err)
+	bob, err := identity.Generate()
+	require.NoError(t, err)
+
+	keys := registered(alice)
+	table := NewTable(keys)
+
+	// Сигнал ключа, не опубликованного в блокчейне, не принимается
+	signal, err := NewSignal(StatusOnline, nil, time.Minute, bob.PrivateKey())
+	require.NoError(t, err)
+	_, err = table.Add(signal, "peer")
+	assert.ErrorIs(t, err, ErrNotRegistered)
+
+	// Отозванный ключ тоже не принимается
+	keys[bob.Address()] = &blockchain.KeyRecord{Address: bob.Address(), RevokedAt: 1}
+	_, err = table.Add(signal, "peer")
+	assert.ErrorIs(t, err, ErrNotRegistered)
+	assert.Empty(t, table.List())
+
+	// Заполненная таблица не принимает новых пользователей
+	for i := 0; i < MaxEntries; i++ {
+		table.signals[fmt.Sprintf("user%d", i)] = signal
+	}
+	signal, err = NewSignal(StatusOnline, nil, time.Minute, alice.PrivateKey())
+	require.NoError(t, err)
+	_, err = table.Add(signal, "peer")
+	assert.ErrorIs(t, err, ErrTableFull)
+}
+
+func TestHandlePresence(t *testing.T) {
+	alice, err := identity.Generate()
+	require.NoError(t, err)
+
+	signal, err := NewSignal(StatusOnline, nil, time.Minute, alice.PrivateKey())
+	require.NoError(t, err)
+	data, err := json.Marshal(signal)
+	require.NoError(t, err)
+
+	table := NewTable(registered(alice))
+	peer, _ := net.Pipe()
+	defer peer.Close()
+	require.NoError(t, HandlePresence(table, &network.Network{}, &network.Message{Command: CommandPresence, Data: data}, peer))
+
+	server, client := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer server.Close()
+		_ = HandlePresenceList(table, server)
+	}()
+
+	var response network.Message
+	require.NoError(t, json.NewDecoder(client).Decode(&response))
+	assert.Equal(t, CommandPresenceTable, response.Command)
+
+	var entries []Entry
+	require.NoError(t, json.Unmarshal(response.Data, &entries))
+	require.Len(t, entries, 1)
+	assert.Equal(t, alice.Address(), entries[0].Address)
+	assert.Equal(t, StatusOnline, entries[0].Status)
+}
@@ -0,0 +1,179 @@
+package presence
+
+import (
+	"blockchainStorage/internal/identity"
+	"blockchainStorage/internal/transaction"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// StatusOnline пользователь в сети
+	StatusOnline = "online"
+	// StatusAway пользователь в сети, но неактивен
+	StatusAway = "away"
+	// StatusOffline пользователь вышел из сети
+	StatusOffline = "offline"
+
+	// MaxTTL максимальное время действия сигнала
+	MaxTTL = 5 * time.Minute
+	// TypingTimeout время, в течение которого показывается набор текста, если
+	// клиент не отправил новый сигнал
+	TypingTimeout = 10 * time.Second
+	// MaxClockSkew допустимое опережение времени сигнала относительно часов узла
+	MaxClockSkew = 30 * time.Second
+	// MaxTypingSize максимальный размер зашифрованного признака набора текста
+	MaxTypingSize = 256
+
+	// signalDomain отделяет подписи сигналов присутствия от других подписей
+	signalDomain = "blockchainChat presence"
+)
+
+var (
+	ErrInvalidSignal = errors.New("invalid presence signal")
+	ErrSignalExpired = errors.New("presence signal expired")
+)
+
+var statuses = map[string]bool{
+	StatusOnline:  true,
+	StatusAway:    true,
+	StatusOffline: true,
+}
+
+// Signal эфемерный сигнал присутствия. Передается только по сети и никогда не
+// попадает в блокчейн.
+type Signal struct {
+	Status string
+	// Typing признак набора текста, зашифрованный ключом собеседника: узлы,
+	// передающие сигнал, не знают, кому пишет пользователь. Пустой, если
+	// пользователь не набирает сообщение.
+	Typing    []byte `json:",omitempty"`
+	Timestamp int64
+	TTL       time.Duration
+}
+
+// SignedSignal сигнал, подписанный ключом пользователя
+type SignedSignal struct {
+	Signal
+	PublicKey []byte
+	Signature []byte
+}
+
+// NewSignal создает и подписывает сигнал со временем действия ttl. typingTo
+// ключ собеседника, которому пользователь набирает сообщение, или nil.
+func NewSignal(status string, typingTo *ecdsa.PublicKey, ttl time.Duration, privateKey *ecdsa.PrivateKey) (*SignedSignal, error) {
+	signal := Signal{
+		Status:    status,
+		Timestamp: time.Now().UnixNano(),
+		TTL:       ttl,
+	}
+
+	publicKey := identity.MarshalPublicKey(&privateKey.PublicKey)
+	if typingTo != nil {
+		// Отправитель внутри шифртекста не позволяет выдать чужой признак за свой
+		typing, err := identity.Encrypt(typingTo, []byte(transaction.SenderFromPublicKey(publicKey)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt typing indicator: %w", err)
+		}
+		signal.Typing = typing
+	}
+
+	err := signal.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := signal.signingHash()
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := ecdsa.SignASN1(rand.Reader, privateKey, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign presence signal: %w", err)
+	}
+
+	return &SignedSignal{
+		Signal:    signal,
+		PublicKey: publicKey,
+		Signature: signature,
+	}, nil
+}
+
+// Validate проверяет статус и время действия сигнала
+func (s *Signal) Validate() error {
+	if !statuses[s.Status] {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidSignal, s.Status)
+	}
+
+	if s.TTL <= 0 || s.TTL > MaxTTL {
+		return fmt.Errorf("%w: ttl must be in (0, %s]", ErrInvalidSignal, MaxTTL)
+	}
+
+	if len(s.Typing) != 0 && s.Status == StatusOffline {
+		return fmt.Errorf("%w: offline user cannot be typing", ErrInvalidSignal)
+	}
+
+	if len(s.Typing) > MaxTypingSize {
+		return fmt.Errorf("%w: typing indicator is too large", ErrInvalidSignal)
+	}
+
+	return nil
+}
+
+// ExpiresAt возвращает время, после которого сигнал не действует
+func (s *Signal) ExpiresAt() time.Time {
+	return time.Unix(0, s.Timestamp).Add(s.TTL)
+}
+
+// Sender возвращает адрес пользователя, подписавшего сигнал
+func (s *SignedSignal) Sender() string {
+	return transaction.SenderFromPublicKey(s.PublicKey)
+}
+
+// Verify проверяет сигнал, его время относительно now и подпись
+func (s *SignedSignal) Verify(now time.Time) error {
+	err := s.Validate()
+	if err != nil {
+		return err
+	}
+
+	if time.Unix(0, s.Timestamp).Sub(now) > MaxClockSkew {
+		return fmt.Errorf("%w: timestamp is in the future", ErrInvalidSignal)
+	}
+
+	if !now.Before(s.ExpiresAt()) {
+		return ErrSignalExpired
+	}
+
+	publicKey, err := identity.ParsePublicKey(s.PublicKey)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignal, err)
+	}
+
+	hash, err := s.signingHash()
+	if err != nil {
+		return err
+	}
+
+	if !ecdsa.VerifyASN1(publicKey, hash, s.Signature) {
+		return fmt.Errorf("%w: %v", ErrInvalidSignal, transaction.ErrInvalidSignature)
+	}
+
+	return nil
+}
+
+func (s *Signal) signingHash() ([]byte, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal presence signal: %w", err)
+	}
+
+	hash := sha256.Sum256(append([]byte(signalDomain), data...))
+	return hash[:], nil
+}
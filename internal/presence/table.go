@@ -0,0 +1,296 @@
+package presence
+
+import (
+	"blockchainStorage/internal/blockchain"
+	"blockchainStorage/internal/identity"
+	"blockchainStorage/internal/network"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// CommandPresence сигнал присутствия, Data содержит SignedSignal
+	CommandPresence = "presence"
+	// CommandPresenceList запрос таблицы присутствия узла
+	CommandPresenceList = "presencelist"
+	// CommandPresenceTable ответ на presencelist, Data содержит список Entry
+	CommandPresenceTable = "presencetable"
+
+	// RateLimit максимальное количество сигналов одного пользователя за RateWindow
+	RateLimit = 10
+	// PeerRateLimit максимальное количество сигналов от одного узла за RateWindow.
+	// Соседний узел передает сигналы многих пользователей, поэтому предел выше.
+	PeerRateLimit = 200
+	// RateWindow окно ограничения частоты сигналов
+	RateWindow = 10 * time.Second
+	// MaxEntries максимальное количество пользователей и узлов в таблице
+	MaxEntries = 10000
+)
+
+var (
+	ErrRateLimited   = errors.New("presence signals are sent too often")
+	ErrTableFull     = errors.New("presence table is full")
+	ErrNotRegistered = errors.New("presence signer has no registered key")
+)
+
+// KeyLookup реестр ключей пользователей (см. blockchain.Blockchain)
+type KeyLookup interface {
+	LookupKeys(address string) (*blockchain.KeyRecord, error)
+}
+
+// Entry состояние пользователя в таблице присутствия
+type Entry struct {
+	Address string
+	Status  string
+	// Typing зашифрованный признак набора текста, прочитать его может только
+	// собеседник (см. TypingTo)
+	Typing    []byte `json:",omitempty"`
+	UpdatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// TypingTo проверяет, набирает ли пользователь сообщение владельцу ключа id
+func (e *Entry) TypingTo(id *identity.Identity) bool {
+	if len(e.Typing) == 0 {
+		return false
+	}
+
+	plaintext, err := id.Decrypt(e.Typing)
+	return err == nil && string(plaintext) == e.Address
+}
+
+type rate struct {
+	start time.Time
+	count int
+}
+
+// Table хранит в памяти последние действующие сигналы пользователей с
+// ключами в блокчейне и ограничивает частоту сигналов от каждого пользователя
+// и каждого соседнего узла
+type Table struct {
+	mu      sync.Mutex
+	keys    KeyLookup
+	signals map[string]*SignedSignal
+	rates   map[string]*rate
+	peers   map[string]*rate
+	now     func() time.Time
+}
+
+func NewTable(keys KeyLookup) *Table {
+	return &Table{
+		keys:    keys,
+		signals: make(map[string]*SignedSignal),
+		rates:   make(map[string]*rate),
+		peers:   make(map[string]*rate),
+		now:     time.Now,
+	}
+}
+
+// Add проверяет сигнал, полученный от узла peer, и запоминает его, если он
+// новее известного сигнала отправителя. Возвращает true, если сигнал нужно
+// передать дальше по сети; повторы и устаревшие сигналы пропускаются без ошибки.
+func (t *Table) Add(signal *SignedSignal, peer string) (bool, error) {
+	now := t.now()
+	err := signal.Verify(now)
+	if err != nil {
+		return false, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune(now)
+
+	sender := signal.Sender()
+	previous, exists := t.signals[sender]
+	if exists && signal.Timestamp <= previous.Timestamp {
+		return false, nil
+	}
+
+	// Частота считается после отбрасывания повторов, так как один сигнал
+	// приходит от нескольких соседних узлов
+	err = limit(t.peers, peer, PeerRateLimit, now)
+	if err != nil {
+		return false, err
+	}
+
+	if !exists && len(t.signals) >= MaxEntries {
+		return false, ErrTableFull
+	}
+
+	// Ключи ничего не стоят, поэтому сигналы принимаются только от
+	// пользователей, опубликовавших ключ в блокчейне
+	record, err := t.keys.LookupKeys(sender)
+	if errors.Is(err, blockchain.ErrKeyNotRegistered) || (err == nil && record.Retired()) {
+		return false, fmt.Errorf("%w: %s", ErrNotRegistered, sender)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to look up signer keys: %w", err)
+	}
+
+	err = limit(t.rates, sender, RateLimit, now)
+	if err != nil {
+		return false, err
+	}
+
+	t.signals[sender] = signal
+	return true, nil
+}
+
+// limit учитывает сигнал от source в окне частоты и отклоняет его сверх max
+func limit(rates map[string]*rate, source string, max int, now time.Time) error {
+	r, exists := rates[source]
+	if !exists || now.Sub(r.start) >= RateWindow {
+		if !exists && len(rates) >= MaxEntries {
+			return ErrTableFull
+		}
+
+		r = &rate{start: now}
+		rates[source] = r
+	}
+
+	if r.count >= max {
+		return fmt.Errorf("%w: %s", ErrRateLimited, source)
+	}
+	r.count++
+
+	return nil
+}
+
+// Get возвращает состояние пользователя, если его сигнал еще действует
+func (t *Table) Get(address string) (*Entry, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	t.prune(now)
+
+	signal, exists := t.signals[address]
+	if !exists {
+		return nil, false
+	}
+
+	return entry(address, signal, now), true
+}
+
+// List возвращает действующие состояния пользователей, упорядоченные по адресу
+func (t *Table) List() []Entry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	t.prune(now)
+
+	entries := make([]Entry, 0, len(t.signals))
+	for address, signal := range t.signals {
+		entries = append(entries, *entry(address, signal, now))
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Address < entries[j].Address
+	})
+
+	return entries
+}
+
+// prune удаляет истекшие сигналы и завершенные окна частоты
+func (t *Table) prune(now time.Time) {
+	for address, signal := range t.signals {
+		if !now.Before(signal.ExpiresAt()) {
+			delete(t.signals, address)
+		}
+	}
+
+	for _, rates := range []map[string]*rate{t.rates, t.peers} {
+		for source, r := range rates {
+			if now.Sub(r.start) >= RateWindow {
+				delete(rates, source)
+			}
+		}
+	}
+}
+
+func entry(address string, signal *SignedSignal, now time.Time) *Entry {
+	updated := time.Unix(0, signal.Timestamp)
+	e := &Entry{
+		Address:   address,
+		Status:    signal.Status,
+		UpdatedAt: updated,
+		ExpiresAt: signal.ExpiresAt(),
+	}
+
+	if now.Sub(updated) < TypingTimeout {
+		e.Typing = signal.Typing
+	}
+
+	return e
+}
+
+// Publish рассылает сигнал узлам сети
+func Publish(n *network.Network, signal *SignedSignal) error {
+	data, err := json.Marshal(signal)
+	if err != nil {
+		return fmt.Errorf("failed to marshal presence signal: %w", err)
+	}
+
+	return n.Broadcast(CommandPresence, data)
+}
+
+// HandlePresence добавляет сигнал, полученный командой presence по соединению
+// conn, в таблицу и передает новые сигналы соседним узлам
+func HandlePresence(table *Table, n *network.Network, msg *network.Message, conn net.Conn) error {
+	var signal SignedSignal
+	err := json.Unmarshal(msg.Data, &signal)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal presence signal: %w", err)
+	}
+
+	// Частота ограничивается по адресу узла: порт меняется с каждым соединением
+	peer := conn.RemoteAddr().String()
+	host, _, err := net.SplitHostPort(peer)
+	if err == nil {
+		peer = host
+	}
+
+	relay, err := table.Add(&signal, peer)
+	if err != nil || !relay {
+		return err
+	}
+
+	return n.Broadcast(CommandPresence, msg.Data)
+}
+
+// HandlePresenceList отвечает на запрос presencelist таблицей присутствия
+func HandlePresenceList(table *Table, conn net.Conn) error {
+	data, err := json.Marshal(table.List())
+	if err != nil {
+		return fmt.Errorf("failed to marshal presence table: %w", err)
+	}
+
+	return network.Reply(conn, CommandPresenceTable, data)
+}
+
+// FetchTable запрашивает таблицу присутствия узла node
+func FetchTable(n *network.Network, node string) ([]Entry, error) {
+	response, err := n.Request(node, CommandPresenceList, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Command != CommandPresenceTable {
+		return nil, fmt.Errorf("unexpected response %q from %s", response.Command, node)
+	}
+
+	var entries []Entry
+	err = json.Unmarshal(response.Data, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal presence table: %w", err)
+	}
+
+	return entries, nil
+}
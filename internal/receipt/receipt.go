@@ -0,0 +1,127 @@
+package receipt
+
+import (
+	"blockchainStorage/internal/blockchain"
+	"blockchainStorage/internal/network"
+	"blockchainStorage/internal/storage"
+	"blockchainStorage/internal/transaction"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	// CommandReceipt квитанция получателя, Data содержит transaction.SignedReceipt
+	CommandReceipt = "receipt"
+	// Namespace префикс квитанций в storage.DataStore (см. DataStore.Namespace).
+	// Квитанции о сообщении хранятся по идентификатору его транзакции.
+	Namespace = "receipt_"
+)
+
+var ErrNotRecipient = errors.New("receipt is not signed by the message recipient")
+
+// OutputLookup источник выходов подтвержденных сообщений и адресов их
+// владельцев после замены ключа (см. blockchain.Blockchain)
+type OutputLookup interface {
+	LookupOutput(txID string, index int) (*blockchain.OutputRecord, error)
+	CurrentAddress(address string) (string, error)
+}
+
+// Store хранит на узле отправителя последние квитанции получателей по каждому
+// выходу сообщения. Квитанции не попадают в блокчейн, если их не закрепить
+// транзакцией transaction.TypeReceipt.
+type Store struct {
+	mu      sync.Mutex
+	kv      storage.KeyValueStore
+	outputs OutputLookup
+}
+
+func NewStore(kv storage.KeyValueStore, outputs OutputLookup) *Store {
+	return &Store{kv: kv, outputs: outputs}
+}
+
+// Add проверяет подпись квитанции и то, что ее подписал получатель выхода или
+// его новый ключ после замены, и сохраняет ее, если она повышает статус.
+// Возвращает false для устаревших квитанций.
+func (s *Store) Add(receipt *transaction.SignedReceipt) (bool, error) {
+	err := receipt.Verify()
+	if err != nil {
+		return false, err
+	}
+
+	output, err := s.outputs.LookupOutput(receipt.TxID, receipt.OutputIndex)
+	if err != nil {
+		return false, err
+	}
+
+	owner, err := s.outputs.CurrentAddress(output.Owner)
+	if err != nil {
+		return false, err
+	}
+
+	if owner != receipt.Recipient() {
+		return false, fmt.Errorf("%w: %s:%d", ErrNotRecipient, receipt.TxID, receipt.OutputIndex)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	receipts, err := s.load(receipt.TxID)
+	if err != nil {
+		return false, err
+	}
+
+	previous, exists := receipts[receipt.OutputIndex]
+	if exists && !receipt.Supersedes(&previous.Receipt) {
+		return false, nil
+	}
+
+	receipts[receipt.OutputIndex] = *receipt
+	err = s.kv.Put(receipt.TxID, receipts)
+	if err != nil {
+		return false, fmt.Errorf("failed to save receipts for %s: %w", receipt.TxID, err)
+	}
+
+	return true, nil
+}
+
+// Receipts возвращает последние квитанции о сообщении txID по номерам выходов
+func (s *Store) Receipts(txID string) (map[int]transaction.SignedReceipt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.load(txID)
+}
+
+func (s *Store) load(txID string) (map[int]transaction.SignedReceipt, error) {
+	receipts := make(map[int]transaction.SignedReceipt)
+	_, err := s.kv.Get(txID, &receipts)
+	if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		return nil, fmt.Errorf("failed to load receipts for %s: %w", txID, err)
+	}
+
+	return receipts, nil
+}
+
+// Send отправляет квитанцию узлам сети, среди которых узел отправителя сообщения
+func Send(n *network.Network, receipt *transaction.SignedReceipt) error {
+	data, err := json.Marshal(receipt)
+	if err != nil {
+		return fmt.Errorf("failed to marshal receipt: %w", err)
+	}
+
+	return n.Broadcast(CommandReceipt, data)
+}
+
+// HandleReceipt сохраняет квитанцию, полученную командой receipt
+func HandleReceipt(store *Store, msg *network.Message) error {
+	var receipt transaction.SignedReceipt
+	err := json.Unmarshal(msg.Data, &receipt)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal receipt: %w", err)
+	}
+
+	_, err = store.Add(&receipt)
+	return err
+}
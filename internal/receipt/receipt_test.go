@@ -0,0 +1,114 @@
+package receipt
+
+import (
+	"blockchainStorage/internal/blockchain"
+	"blockchainStorage/internal/storage"
+	"blockchainStorage/internal/transaction"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// outputMap выходы сообщений по ключу "txID:index"
+type outputMap map[string]*blockchain.OutputRecord
+
+func (m outputMap) LookupOutput(txID string, index int) (*blockchain.OutputRecord, error) {
+	output, exists := m[txID]
+	if !exists || output.Index != index {
+		return nil, blockchain.ErrOutputNotFound
+	}
+	return output, nil
+}
+
+func (m outputMap) CurrentAddress(address string) (string, error) {
+	return address, nil
+}
+
+// rotatedOutputs выходы сообщений и замены ключей получателей
+type rotatedOutputs struct {
+	outputMap
+	rotatedTo map[string]string
+}
+
+func (r rotatedOutputs) CurrentAddress(address string) (string, error) {
+	for r.rotatedTo[address] != "" {
+		address = r.rotatedTo[address]
+	}
+	return address, nil
+}
+
+func newKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return key, transaction.SenderFromPublicKey(elliptic.Marshal(elliptic.P256(), key.PublicKey.X, key.PublicKey.Y))
+}
+
+func signReceipt(t *testing.T, key *ecdsa.PrivateKey, status string) *transaction.SignedReceipt {
+	signed, err := (&transaction.Receipt{TxID: "message", OutputIndex: 0, Status: status}).Sign(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestStore_Add(t *testing.T) {
+	recipientKey, recipient := newKey(t)
+	otherKey, _ := newKey(t)
+
+	store := NewStore(storage.NewMemoryStore(), outputMap{
+		"message": {TxID: "message", Index: 0, Owner: recipient},
+	})
+
+	added, err := store.Add(signReceipt(t, recipientKey, transaction.ReceiptDelivered))
+	require.NoError(t, err)
+	assert.True(t, added)
+
+	added, err = store.Add(signReceipt(t, recipientKey, transaction.ReceiptRead))
+	require.NoError(t, err)
+	assert.True(t, added)
+
+	// Квитанция о доставке после прочтения не меняет статус
+	added, err = store.Add(signReceipt(t, recipientKey, transaction.ReceiptDelivered))
+	require.NoError(t, err)
+	assert.False(t, added)
+
+	receipts, err := store.Receipts("message")
+	require.NoError(t, err)
+	require.Contains(t, receipts, 0)
+	latest := receipts[0]
+	assert.Equal(t, transaction.ReceiptRead, latest.Status)
+	assert.Equal(t, recipient, latest.Recipient())
+
+	// Квитанции не от получателя и с поддельной подписью отклоняются
+	_, err = store.Add(signReceipt(t, otherKey, transaction.ReceiptRead))
+	assert.ErrorIs(t, err, ErrNotRecipient)
+
+	forged := signReceipt(t, recipientKey, transaction.ReceiptDelivered)
+	forged.Status = transaction.ReceiptRead
+	_, err = store.Add(forged)
+	assert.ErrorIs(t, err, transaction.ErrInvalidSignature)
+
+	_, err = (&transaction.Receipt{TxID: "message", Status: "seen"}).Sign(recipientKey)
+	assert.ErrorIs(t, err, transaction.ErrInvalidReceipt)
+}
+
+func TestStore_AddAfterKeyRotation(t *testing.T) {
+	oldKey, oldAddress := newKey(t)
+	newRecipientKey, newAddress := newKey(t)
+
+	store := NewStore(storage.NewMemoryStore(), rotatedOutputs{
+		outputMap: outputMap{"message": {TxID: "message", Index: 0, Owner: oldAddress}},
+		rotatedTo: map[string]string{oldAddress: newAddress},
+	})
+
+	// Квитанцию о сообщении старому адресу подписывает новый ключ получателя
+	added, err := store.Add(signReceipt(t, newRecipientKey, transaction.ReceiptRead))
+	require.NoError(t, err)
+	assert.True(t, added)
+
+	// Замененный ключ больше не подписывает квитанции
+	_, err = store.Add(signReceipt(t, oldKey, transaction.ReceiptRead))
+	assert.ErrorIs(t, err, ErrNotRecipient)
+}
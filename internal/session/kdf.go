@@ -0,0 +1,48 @@
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+const kdfInfo = "blockchainChat ratchet"
+
+// hkdf реализует HKDF-SHA256 (RFC 5869) для вывода ключей ратчета
+func hkdf(salt []byte, secret []byte, info string, length int) []byte {
+	if salt == nil {
+		salt = make([]byte, sha256.Size)
+	}
+
+	extractor := hmac.New(sha256.New, salt)
+	extractor.Write(secret)
+	prk := extractor.Sum(nil)
+
+	var out, block []byte
+	for counter := byte(1); len(out) < length; counter++ {
+		expander := hmac.New(sha256.New, prk)
+		expander.Write(block)
+		expander.Write([]byte(info))
+		expander.Write([]byte{counter})
+		block = expander.Sum(nil)
+		out = append(out, block...)
+	}
+
+	return out[:length]
+}
+
+// kdfRoot выводит новый корневой ключ и ключ цепочки из результата ECDH
+func kdfRoot(rootKey []byte, dhOutput []byte) ([]byte, []byte) {
+	out := hkdf(rootKey, dhOutput, kdfInfo, 64)
+	return out[:32], out[32:]
+}
+
+// kdfChain продвигает ключ цепочки и возвращает ключ очередного сообщения
+func kdfChain(chainKey []byte) ([]byte, []byte) {
+	next := hmac.New(sha256.New, chainKey)
+	next.Write([]byte{0x02})
+
+	message := hmac.New(sha256.New, chainKey)
+	message.Write([]byte{0x01})
+
+	return next.Sum(nil), message.Sum(nil)
+}
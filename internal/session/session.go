@@ -0,0 +1,458 @@
+package session
+
+import (
+	"blockchainStorage/internal/transaction"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// MaxSkip максимальное количество пропущенных ключей сообщений в одной цепочке
+const MaxSkip = 1000
+
+var (
+	ErrNoSession     = errors.New("no session with peer")
+	ErrTooManySkips  = errors.New("too many skipped messages")
+	ErrDecryptFailed = errors.New("failed to decrypt message")
+	ErrWrongScheme   = errors.New("output is not encrypted with ratchet scheme")
+	ErrReplayedKey   = errors.New("session was already established with this ephemeral key")
+)
+
+// Header открытый заголовок сообщения ратчета
+type Header struct {
+	// DH текущий публичный ключ ратчета отправителя
+	DH []byte
+	// PN длина предыдущей цепочки отправки
+	PN uint32
+	// N номер сообщения в текущей цепочке
+	N uint32
+	// EphemeralKey одноразовый ключ инициатора сессии, передается до первого ответа
+	EphemeralKey []byte
+}
+
+// Envelope зашифрованное сообщение прямой переписки
+type Envelope struct {
+	Header     Header
+	Ciphertext []byte
+}
+
+// State состояние двойного ратчета с одним собеседником
+type State struct {
+	RootKey         []byte
+	SendingKey      []byte
+	RemoteKey       []byte
+	SendChain       []byte
+	RecvChain       []byte
+	SendCount       uint32
+	RecvCount       uint32
+	PrevCount       uint32
+	EphemeralKey    []byte
+	RemoteEphemeral []byte
+	Skipped         map[string][]byte
+}
+
+// Manager шифрует и расшифровывает прямые сообщения с прямой секретностью.
+// Долгосрочный ключ prekey используется только для установления сессии,
+// каждое сообщение шифруется отдельным ключом, выведенным из ратчета.
+type Manager struct {
+	mu     sync.Mutex
+	prekey *ecdh.PrivateKey
+	store  *Store
+}
+
+func NewManager(prekey *ecdh.PrivateKey, store *Store) *Manager {
+	return &Manager{
+		prekey: prekey,
+		store:  store,
+	}
+}
+
+// GeneratePrekey создает долгосрочный X25519 ключ для установления сессий
+func GeneratePrekey() (*ecdh.PrivateKey, error) {
+	return ecdh.X25519().GenerateKey(rand.Reader)
+}
+
+// Encrypt шифрует сообщение для собеседника peer. Если сессии еще нет,
+// она устанавливается с использованием его публичного ключа peerPrekey.
+func (m *Manager) Encrypt(peer string, peerPrekey *ecdh.PublicKey, plaintext []byte) (*Envelope, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.store.LoadSession(peer)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == nil {
+		if peerPrekey == nil {
+			return nil, ErrNoSession
+		}
+
+		state, err = initiate(peerPrekey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	chainKey, messageKey := kdfChain(state.SendChain)
+	state.SendChain = chainKey
+
+	sendingKey, err := ecdh.X25519().NewPrivateKey(state.SendingKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ratchet key: %w", err)
+	}
+
+	header := Header{
+		DH:           sendingKey.PublicKey().Bytes(),
+		PN:           state.PrevCount,
+		N:            state.SendCount,
+		EphemeralKey: state.EphemeralKey,
+	}
+	state.SendCount++
+
+	ciphertext, err := seal(messageKey, plaintext, header)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.store.SaveSession(peer, state)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Envelope{Header: header, Ciphertext: ciphertext}, nil
+}
+
+// Decrypt расшифровывает сообщение собеседника peer. Первое сообщение
+// инициатора устанавливает сессию на стороне получателя.
+func (m *Manager) Decrypt(peer string, envelope *Envelope) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.store.LoadSession(peer)
+	if err != nil {
+		return nil, err
+	}
+
+	var plaintext []byte
+	if state != nil {
+		// Работаем с копией, чтобы неудачная попытка не повредила сессию
+		working := state.clone()
+		plaintext, err = decryptWith(working, envelope)
+		if err == nil {
+			state = working
+		}
+	}
+
+	if state == nil || err != nil {
+		ephemeralKey := envelope.Header.EphemeralKey
+		if ephemeralKey == nil || (state != nil && bytes.Equal(state.RemoteEphemeral, ephemeralKey)) {
+			if err == nil {
+				err = ErrNoSession
+			}
+			return nil, err
+		}
+
+		// Первое сообщение прошлой сессии, доставленное повторно, не должно
+		// заменить текущую сессию и откатить ратчет
+		used, err := m.store.EphemeralUsed(peer, ephemeralKey)
+		if err != nil {
+			return nil, err
+		}
+		if used {
+			return nil, ErrReplayedKey
+		}
+
+		// Собеседник установил новую сессию
+		state, err = m.respond(ephemeralKey)
+		if err != nil {
+			return nil, err
+		}
+
+		plaintext, err = decryptWith(state, envelope)
+		if err != nil {
+			return nil, err
+		}
+
+		err = m.store.MarkEphemeral(peer, ephemeralKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Собеседник ответил, значит сессия установлена и ключ инициатора больше не нужен
+	state.EphemeralKey = nil
+
+	err = m.store.SaveSession(peer, state)
+	if err != nil {
+		return nil, err
+	}
+
+	return plaintext, nil
+}
+
+// EncryptOutput шифрует сообщение для recipient и возвращает выход транзакции
+func (m *Manager) EncryptOutput(recipient string, recipientPrekey *ecdh.PublicKey, plaintext []byte) (transaction.MessageOutput, error) {
+	envelope, err := m.Encrypt(recipient, recipientPrekey, plaintext)
+	if err != nil {
+		return transaction.MessageOutput{}, err
+	}
+
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return transaction.MessageOutput{}, fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+
+	return transaction.MessageOutput{
+		EncryptedData: data,
+		Recipient:     recipient,
+		Scheme:        transaction.SchemeRatchet,
+	}, nil
+}
+
+// DecryptOutput расшифровывает выход транзакции, полученный от sender
+func (m *Manager) DecryptOutput(sender string, output transaction.MessageOutput) ([]byte, error) {
+	if output.Scheme != transaction.SchemeRatchet {
+		return nil, ErrWrongScheme
+	}
+
+	var envelope Envelope
+	err := json.Unmarshal(output.EncryptedData, &envelope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal envelope: %w", err)
+	}
+
+	return m.Decrypt(sender, &envelope)
+}
+
+// initiate создает сессию на стороне отправителя первого сообщения
+func initiate(peerPrekey *ecdh.PublicKey) (*State, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	sharedSecret, err := ephemeral.ECDH(peerPrekey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute shared secret: %w", err)
+	}
+
+	sendingKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ratchet key: %w", err)
+	}
+
+	dhOutput, err := sendingKey.ECDH(peerPrekey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute ratchet secret: %w", err)
+	}
+
+	rootKey, sendChain := kdfRoot(sharedSecret, dhOutput)
+
+	return &State{
+		RootKey:      rootKey,
+		SendingKey:   sendingKey.Bytes(),
+		RemoteKey:    peerPrekey.Bytes(),
+		SendChain:    sendChain,
+		EphemeralKey: ephemeral.PublicKey().Bytes(),
+		Skipped:      make(map[string][]byte),
+	}, nil
+}
+
+// respond создает сессию на стороне получателя по ключу инициатора
+func (m *Manager) respond(ephemeralKey []byte) (*State, error) {
+	ephemeral, err := ecdh.X25519().NewPublicKey(ephemeralKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ephemeral key: %w", err)
+	}
+
+	sharedSecret, err := m.prekey.ECDH(ephemeral)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute shared secret: %w", err)
+	}
+
+	return &State{
+		RootKey:         sharedSecret,
+		SendingKey:      m.prekey.Bytes(),
+		RemoteEphemeral: ephemeralKey,
+		Skipped:         make(map[string][]byte),
+	}, nil
+}
+
+// decryptWith расшифровывает сообщение, продвигая ратчет в state
+func decryptWith(state *State, envelope *Envelope) ([]byte, error) {
+	header := envelope.Header
+
+	skippedKey := skippedIndex(header.DH, header.N)
+	if messageKey, exists := state.Skipped[skippedKey]; exists {
+		plaintext, err := open(messageKey, envelope.Ciphertext, header)
+		if err != nil {
+			return nil, err
+		}
+
+		delete(state.Skipped, skippedKey)
+		return plaintext, nil
+	}
+
+	if !bytes.Equal(header.DH, state.RemoteKey) {
+		err := state.skip(header.PN)
+		if err != nil {
+			return nil, err
+		}
+
+		err = state.ratchet(header.DH)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := state.skip(header.N)
+	if err != nil {
+		return nil, err
+	}
+
+	chainKey, messageKey := kdfChain(state.RecvChain)
+	state.RecvChain = chainKey
+	state.RecvCount++
+
+	return open(messageKey, envelope.Ciphertext, header)
+}
+
+// skip сохраняет ключи пропущенных сообщений текущей цепочки до номера until
+func (s *State) skip(until uint32) error {
+	if s.RecvChain == nil {
+		return nil
+	}
+
+	if until > s.RecvCount+MaxSkip {
+		return ErrTooManySkips
+	}
+
+	for s.RecvCount < until {
+		chainKey, messageKey := kdfChain(s.RecvChain)
+		s.RecvChain = chainKey
+		s.Skipped[skippedIndex(s.RemoteKey, s.RecvCount)] = messageKey
+		s.RecvCount++
+	}
+
+	if len(s.Skipped) > MaxSkip {
+		return ErrTooManySkips
+	}
+
+	return nil
+}
+
+// ratchet выполняет шаг DH-ратчета при получении нового ключа собеседника
+func (s *State) ratchet(remoteKey []byte) error {
+	remote, err := ecdh.X25519().NewPublicKey(remoteKey)
+	if err != nil {
+		return fmt.Errorf("failed to parse ratchet key: %w", err)
+	}
+
+	sendingKey, err := ecdh.X25519().NewPrivateKey(s.SendingKey)
+	if err != nil {
+		return fmt.Errorf("failed to load ratchet key: %w", err)
+	}
+
+	dhOutput, err := sendingKey.ECDH(remote)
+	if err != nil {
+		return fmt.Errorf("failed to compute ratchet secret: %w", err)
+	}
+
+	s.PrevCount = s.SendCount
+	s.SendCount = 0
+	s.RecvCount = 0
+	s.RemoteKey = remoteKey
+	s.RootKey, s.RecvChain = kdfRoot(s.RootKey, dhOutput)
+
+	newSendingKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate ratchet key: %w", err)
+	}
+
+	dhOutput, err = newSendingKey.ECDH(remote)
+	if err != nil {
+		return fmt.Errorf("failed to compute ratchet secret: %w", err)
+	}
+
+	s.SendingKey = newSendingKey.Bytes()
+	s.RootKey, s.SendChain = kdfRoot(s.RootKey, dhOutput)
+
+	return nil
+}
+
+func (s *State) clone() *State {
+	data, _ := json.Marshal(s)
+
+	var copied State
+	_ = json.Unmarshal(data, &copied)
+	if copied.Skipped == nil {
+		copied.Skipped = make(map[string][]byte)
+	}
+
+	return &copied
+}
+
+func skippedIndex(dh []byte, n uint32) string {
+	return hex.EncodeToString(dh) + ":" + strconv.FormatUint(uint64(n), 10)
+}
+
+// seal шифрует сообщение ключом messageKey, заголовок аутентифицируется
+func seal(messageKey []byte, plaintext []byte, header Header) ([]byte, error) {
+	aead, nonce, err := newCipher(messageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	headerData, err := json.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal header: %w", err)
+	}
+
+	return aead.Seal(nil, nonce, plaintext, headerData), nil
+}
+
+func open(messageKey []byte, ciphertext []byte, header Header) ([]byte, error) {
+	aead, nonce, err := newCipher(messageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	headerData, err := json.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal header: %w", err)
+	}
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, headerData)
+	if err != nil {
+		return nil, ErrDecryptFailed
+	}
+
+	return plaintext, nil
+}
+
+// newCipher создает AES-256-GCM из ключа сообщения. Ключ сообщения используется
+// один раз, поэтому ключ шифрования и nonce выводятся из него детерминированно.
+func newCipher(messageKey []byte) (cipher.AEAD, []byte, error) {
+	material := hkdf(nil, messageKey, kdfInfo+" message", 32+12)
+
+	block, err := aes.NewCipher(material[:32])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	return aead, material[32:], nil
+}
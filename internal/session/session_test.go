@@ -0,0 +1,123 @@
+package session
+
+import (
+	"blockchainStorage/internal/storage"
+	"blockchainStorage/internal/transaction"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	prekey, err := GeneratePrekey()
+	require.NoError(t, err)
+	return NewManager(prekey, NewStore(storage.NewMemoryStore()))
+}
+
+func TestManager_Conversation(t *testing.T) {
+	alice := newTestManager(t)
+	bob := newTestManager(t)
+
+	// Алиса начинает переписку, зная только долгосрочный ключ Боба
+	first, err := alice.Encrypt("bob", bob.prekey.PublicKey(), []byte("hello bob"))
+	require.NoError(t, err)
+	assert.NotNil(t, first.Header.EphemeralKey)
+
+	second, err := alice.Encrypt("bob", nil, []byte("are you there?"))
+	require.NoError(t, err)
+
+	// Каждое сообщение шифруется своим ключом
+	assert.NotEqual(t, first.Ciphertext, second.Ciphertext)
+
+	// Сообщения доставлены не по порядку
+	plaintext, err := bob.Decrypt("alice", second)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("are you there?"), plaintext)
+
+	plaintext, err = bob.Decrypt("alice", first)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello bob"), plaintext)
+
+	// Повторная доставка сообщения не расшифровывается: ключ уже удален
+	_, err = bob.Decrypt("alice", first)
+	assert.Error(t, err)
+
+	// Ответ Боба выполняет шаг DH-ратчета
+	reply, err := bob.Encrypt("alice", nil, []byte("hi alice"))
+	require.NoError(t, err)
+	assert.Nil(t, reply.Header.EphemeralKey)
+	assert.NotEqual(t, first.Header.DH, reply.Header.DH)
+
+	plaintext, err = alice.Decrypt("bob", reply)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hi alice"), plaintext)
+
+	// После ответа Алиса больше не передает ключ инициатора
+	next, err := alice.Encrypt("bob", nil, []byte("great"))
+	require.NoError(t, err)
+	assert.Nil(t, next.Header.EphemeralKey)
+
+	plaintext, err = bob.Decrypt("alice", next)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("great"), plaintext)
+}
+
+func TestManager_NoSession(t *testing.T) {
+	alice := newTestManager(t)
+
+	_, err := alice.Encrypt("bob", nil, []byte("hello"))
+	assert.ErrorIs(t, err, ErrNoSession)
+}
+
+func TestManager_TransactionOutput(t *testing.T) {
+	alice := newTestManager(t)
+	bob := newTestManager(t)
+
+	output, err := alice.EncryptOutput("bob", bob.prekey.PublicKey(), []byte("secret"))
+	require.NoError(t, err)
+	assert.Equal(t, transaction.SchemeRatchet, output.Scheme)
+	assert.Equal(t, "bob", output.Recipient)
+
+	// Выход с прямой секретностью не перешифровывается RSA ключом
+	tx := &transaction.Transaction{ID: "transaction_id", Outputs: []transaction.MessageOutput{output}}
+	require.NoError(t, tx.EncryptMessages())
+	assert.Equal(t, output.EncryptedData, tx.Outputs[0].EncryptedData)
+
+	plaintext, err := bob.DecryptOutput("alice", tx.Outputs[0])
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret"), plaintext)
+}
+
+func TestManager_ReplayedSessionStart(t *testing.T) {
+	alice := newTestManager(t)
+	bob := newTestManager(t)
+
+	old, err := alice.Encrypt("bob", bob.prekey.PublicKey(), []byte("first session"))
+	require.NoError(t, err)
+	_, err = bob.Decrypt("alice", old)
+	require.NoError(t, err)
+
+	// Алиса потеряла сессию и устанавливает новую
+	require.NoError(t, alice.store.DeleteSession("bob"))
+	fresh, err := alice.Encrypt("bob", bob.prekey.PublicKey(), []byte("second session"))
+	require.NoError(t, err)
+	plaintext, err := bob.Decrypt("alice", fresh)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("second session"), plaintext)
+
+	reply, err := bob.Encrypt("alice", nil, []byte("welcome back"))
+	require.NoError(t, err)
+	_, err = alice.Decrypt("bob", reply)
+	require.NoError(t, err)
+
+	// Повтор первого сообщения прошлой сессии не заменяет текущую
+	_, err = bob.Decrypt("alice", old)
+	assert.ErrorIs(t, err, ErrReplayedKey)
+
+	next, err := alice.Encrypt("bob", nil, []byte("still here"))
+	require.NoError(t, err)
+	plaintext, err = bob.Decrypt("alice", next)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("still here"), plaintext)
+}
@@ -0,0 +1,96 @@
+package session
+
+import (
+	"blockchainStorage/internal/storage"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+const (
+	// Namespace префикс сессий в storage.DataStore (см. DataStore.Namespace).
+	// Состояние сессии хранится по адресу собеседника.
+	Namespace = "session_"
+	// EphemeralPrefix одноразовые ключи инициаторов, по которым уже были
+	// установлены сессии
+	EphemeralPrefix = "ephemeral_"
+)
+
+// Store хранит состояния сессий с собеседниками в локальном хранилище узла.
+// Данные сессий не попадают в блокчейн.
+type Store struct {
+	kv storage.KeyValueStore
+}
+
+func NewStore(kv storage.KeyValueStore) *Store {
+	return &Store{kv: kv}
+}
+
+// LoadSession загружает сессию с собеседником, nil если сессии нет
+func (s *Store) LoadSession(peer string) (*State, error) {
+	var state State
+	_, err := s.kv.Get(peer, &state)
+	if err != nil {
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load session with %s: %w", peer, err)
+	}
+
+	if state.Skipped == nil {
+		state.Skipped = make(map[string][]byte)
+	}
+
+	return &state, nil
+}
+
+// SaveSession сохраняет сессию с собеседником
+func (s *Store) SaveSession(peer string, state *State) error {
+	err := s.kv.Put(peer, state)
+	if err != nil {
+		return fmt.Errorf("failed to save session with %s: %w", peer, err)
+	}
+
+	return nil
+}
+
+// DeleteSession удаляет сессию с собеседником вместе с ключами
+func (s *Store) DeleteSession(peer string) error {
+	err := s.kv.Delete(peer)
+	if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		return fmt.Errorf("failed to delete session with %s: %w", peer, err)
+	}
+
+	return nil
+}
+
+// MarkEphemeral запоминает одноразовый ключ, по которому собеседник установил
+// сессию. Запись не удаляется вместе с сессией.
+func (s *Store) MarkEphemeral(peer string, ephemeralKey []byte) error {
+	err := s.kv.Put(ephemeralIndex(peer, ephemeralKey), true)
+	if err != nil {
+		return fmt.Errorf("failed to save ephemeral key of %s: %w", peer, err)
+	}
+
+	return nil
+}
+
+// EphemeralUsed проверяет, устанавливалась ли уже сессия с собеседником по этому ключу
+func (s *Store) EphemeralUsed(peer string, ephemeralKey []byte) (bool, error) {
+	var used bool
+	_, err := s.kv.Get(ephemeralIndex(peer, ephemeralKey), &used)
+	if err != nil {
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to load ephemeral key of %s: %w", peer, err)
+	}
+
+	return used, nil
+}
+
+func ephemeralIndex(peer string, ephemeralKey []byte) string {
+	hash := sha256.Sum256(ephemeralKey)
+	return EphemeralPrefix + peer + ":" + hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// KeyValueStore локальное хранилище подсистемы узла: DataStore, Namespace или MemoryStore.
+// Значения сериализуются в JSON, отсутствующий ключ возвращает ErrKeyNotFound.
+type KeyValueStore interface {
+	Put(key string, value interface{}) error
+	Get(key string, value interface{}) ([]byte, error)
+	Delete(key string) error
+}
+
+// KeyLister хранилище, ключи которого можно перечислить: Namespace или MemoryStore
+type KeyLister interface {
+	KeyValueStore
+	Keys() ([]string, error)
+}
+
+// MemoryStore хранилище в памяти, сериализующее значения так же, как DataStore.
+// Используется узлами без БД и в тестах.
+type MemoryStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: make(map[string][]byte)}
+}
+
+func (ms *MemoryStore) Put(key string, value interface{}) error {
+	dataBytes, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data: %w", err)
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.data[key] = dataBytes
+	return nil
+}
+
+func (ms *MemoryStore) Get(key string, value interface{}) ([]byte, error) {
+	ms.mu.Lock()
+	dataBytes, exists := ms.data[key]
+	ms.mu.Unlock()
+
+	if !exists {
+		return nil, ErrKeyNotFound
+	}
+
+	err := json.Unmarshal(dataBytes, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal data: %w", err)
+	}
+
+	return dataBytes, nil
+}
+
+func (ms *MemoryStore) Delete(key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	delete(ms.data, key)
+	return nil
+}
+
+// Keys Возвращает ключи хранилища в порядке их сортировки
+func (ms *MemoryStore) Keys() ([]string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	keys := make([]string, 0, len(ms.data))
+	for key := range ms.data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
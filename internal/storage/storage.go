@@ -3,17 +3,24 @@ package storage
 import (
 	"blockchainStorage/internal/blockchain"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
 const (
 	BlockchainExistsKey = "blockchain_exists"
 	TipKey              = "tip"
 	BlockPrefix         = "block_"
+	StatePrefix         = "state_"
+	ChunkPrefix         = "chunk_"
 )
 
+// ErrKeyNotFound ключ отсутствует в БД
+var ErrKeyNotFound = errors.New("key not found")
+
 // DataStore contracts/DbInterface
 
 type DataStore struct {
@@ -67,7 +74,7 @@ func (ds *DataStore) Get(key string, value interface{}) ([]byte, error) {
 	dataBytes, err := ds.db.Get([]byte(key), nil)
 	if err != nil {
 		if err == leveldb.ErrNotFound {
-			return nil, fmt.Errorf("key not found")
+			return nil, ErrKeyNotFound
 		}
 		return nil, fmt.Errorf("failed to get data from LevelDB: %w", err)
 	}
@@ -84,7 +91,7 @@ func (ds *DataStore) Delete(key string) error {
 	err := ds.db.Delete([]byte(key), nil)
 	if err != nil {
 		if err == leveldb.ErrNotFound {
-			return fmt.Errorf("key not found")
+			return ErrKeyNotFound
 		}
 		return fmt.Errorf("failed to delete data from LevelDB: %w", err)
 	}
@@ -128,3 +135,128 @@ func (ds *DataStore) GetBlockFromDB(blockHash string) ([]byte, error) {
 
 	return blockData, nil
 }
+
+// PruneBlockInDB Заменяет блок очищенной версией и уплотняет диапазон ключа
+// блока, чтобы LevelDB не хранила прежнее содержимое до фоновой компакции
+func (ds *DataStore) PruneBlockInDB(block *blockchain.Block) error {
+	err := ds.SaveBlockToDB(block)
+	if err != nil {
+		return err
+	}
+
+	key := []byte(BlockPrefix + block.Hash)
+	err = ds.db.CompactRange(util.Range{Start: key, Limit: append(key, 0)})
+	if err != nil {
+		return fmt.Errorf("failed to compact pruned block: %w", err)
+	}
+
+	return nil
+}
+
+// GetStateFromDB Получает значение состояния цепочки, nil если ключ отсутствует
+func (ds *DataStore) GetStateFromDB(key string) ([]byte, error) {
+	value, err := ds.db.Get([]byte(StatePrefix+key), nil)
+	if err != nil {
+		if err == leveldb.ErrNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get state from DB: %w", err)
+	}
+
+	return value, nil
+}
+
+// SaveStateToDB Сохраняет значение состояния цепочки в БД
+func (ds *DataStore) SaveStateToDB(key string, value []byte) error {
+	err := ds.db.Put([]byte(StatePrefix+key), value, nil)
+	if err != nil {
+		return fmt.Errorf("failed to save state to DB: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteStateFromDB Удаляет значение состояния цепочки из БД
+func (ds *DataStore) DeleteStateFromDB(key string) error {
+	err := ds.db.Delete([]byte(StatePrefix+key), nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete state from DB: %w", err)
+	}
+
+	return nil
+}
+
+// SaveChunk Сохраняет зашифрованный фрагмент вложения по его хэшу
+func (ds *DataStore) SaveChunk(hash string, data []byte) error {
+	err := ds.db.Put([]byte(ChunkPrefix+hash), data, nil)
+	if err != nil {
+		return fmt.Errorf("failed to save chunk to DB: %w", err)
+	}
+
+	return nil
+}
+
+// GetChunk Получает фрагмент вложения по его хэшу
+func (ds *DataStore) GetChunk(hash string) ([]byte, error) {
+	data, err := ds.db.Get([]byte(ChunkPrefix+hash), nil)
+	if err != nil {
+		if err == leveldb.ErrNotFound {
+			return nil, ErrKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get chunk from DB: %w", err)
+	}
+
+	return data, nil
+}
+
+// DeleteChunk Удаляет фрагмент вложения по его хэшу
+func (ds *DataStore) DeleteChunk(hash string) error {
+	err := ds.db.Delete([]byte(ChunkPrefix+hash), nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete chunk from DB: %w", err)
+	}
+
+	return nil
+}
+
+// Namespace локальные данные одной подсистемы, ключи которых хранятся в БД с
+// общим префиксом и не пересекаются с ключами блокчейна и других подсистем
+type Namespace struct {
+	ds     *DataStore
+	prefix string
+}
+
+// Namespace Возвращает пространство ключей с префиксом prefix
+func (ds *DataStore) Namespace(prefix string) *Namespace {
+	return &Namespace{ds: ds, prefix: prefix}
+}
+
+func (ns *Namespace) Put(key string, value interface{}) error {
+	return ns.ds.Put(ns.prefix+key, value)
+}
+
+func (ns *Namespace) Get(key string, value interface{}) ([]byte, error) {
+	return ns.ds.Get(ns.prefix+key, value)
+}
+
+func (ns *Namespace) Delete(key string) error {
+	return ns.ds.Delete(ns.prefix + key)
+}
+
+// Keys Возвращает ключи пространства без префикса в порядке их сортировки
+func (ns *Namespace) Keys() ([]string, error) {
+	iter := ns.ds.db.NewIterator(util.BytesPrefix([]byte(ns.prefix)), nil)
+	defer iter.Release()
+
+	var keys []string
+	for iter.Next() {
+		keys = append(keys, string(iter.Key()[len(ns.prefix):]))
+	}
+
+	err := iter.Error()
+	if err != nil {
+		return nil, fmt.Errorf("failed to iterate namespace %q: %w", ns.prefix, err)
+	}
+
+	return keys, nil
+}
@@ -2,6 +2,10 @@ package storage
 
 import (
 	"blockchainStorage/internal/blockchain"
+	"blockchainStorage/internal/transaction"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"os"
 	"reflect"
 	"sync"
@@ -119,11 +123,16 @@ func TestSaveAndGetBlock(t *testing.T) {
 
 	// Получаем блок из БД по его хэшу
 	var blockData blockchain.Block
-	blockData, err = ds.GetBlockFromDB(block.Hash)
+	rawBlockData, err := ds.GetBlockFromDB(block.Hash)
 	if err != nil {
 		t.Fatalf("failed to get block from DB: %v", err)
 	}
 
+	err = json.Unmarshal(rawBlockData, &blockData)
+	if err != nil {
+		t.Fatalf("failed to unmarshal block data: %v", err)
+	}
+
 	// Проверяем, что полученные данные блока совпадают с ожидаемыми значениями
 	expectedBlock := &blockchain.Block{
 		Index:      1,
@@ -168,3 +177,220 @@ func TestSaveAndRetrieveTip(t *testing.T) {
 		t.Errorf("retrieved tip hash doesn't match the expected hash: got %s, expected %s", retrievedTipHash, tipHash)
 	}
 }
+
+func TestSaveGetAndDeleteState(t *testing.T) {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+
+	// Инициализируем хранилище данных
+	ds, cleanupDB := setupDataStore(t)
+	defer cleanupDB()
+
+	// Отсутствующий ключ состояния возвращает nil без ошибки
+	value, err := ds.GetStateFromDB("seq_alice")
+	if err != nil {
+		t.Fatalf("failed to get missing state: %v", err)
+	}
+	if value != nil {
+		t.Errorf("expected nil for missing state, got %s", value)
+	}
+
+	err = ds.SaveStateToDB("seq_alice", []byte("1"))
+	if err != nil {
+		t.Fatalf("failed to save state: %v", err)
+	}
+
+	value, err = ds.GetStateFromDB("seq_alice")
+	if err != nil {
+		t.Fatalf("failed to get state: %v", err)
+	}
+	if string(value) != "1" {
+		t.Errorf("retrieved state doesn't match: got %s, expected 1", value)
+	}
+
+	err = ds.DeleteStateFromDB("seq_alice")
+	if err != nil {
+		t.Fatalf("failed to delete state: %v", err)
+	}
+
+	value, err = ds.GetStateFromDB("seq_alice")
+	if err != nil || value != nil {
+		t.Errorf("expected state to be deleted, got %s, %v", value, err)
+	}
+}
+
+func TestSaveAndGetChunk(t *testing.T) {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+
+	// Инициализируем хранилище данных
+	ds, cleanupDB := setupDataStore(t)
+	defer cleanupDB()
+
+	// Отсутствующий фрагмент возвращает ErrKeyNotFound
+	_, err := ds.GetChunk("missing")
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+
+	// Фрагмент хранится без изменений, в виде байтов
+	chunk := []byte{0x00, 0xff, 0x10}
+	err = ds.SaveChunk("hash", chunk)
+	if err != nil {
+		t.Fatalf("failed to save chunk: %v", err)
+	}
+
+	value, err := ds.GetChunk("hash")
+	if err != nil {
+		t.Fatalf("failed to get chunk: %v", err)
+	}
+	if string(value) != string(chunk) {
+		t.Errorf("chunk: got %x, expected %x", value, chunk)
+	}
+
+	// Удаленный фрагмент больше не возвращается
+	err = ds.DeleteChunk("hash")
+	if err != nil {
+		t.Fatalf("failed to delete chunk: %v", err)
+	}
+
+	_, err = ds.GetChunk("hash")
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound after delete, got %v", err)
+	}
+}
+
+func TestPruneBlockInDB(t *testing.T) {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+
+	// Инициализируем хранилище данных
+	ds, cleanupDB := setupDataStore(t)
+	defer cleanupDB()
+
+	// Сохраняем блок с сообщением
+	tx := &transaction.Transaction{
+		ID:      "message",
+		Outputs: []transaction.MessageOutput{{EncryptedData: []byte("ciphertext"), Recipient: "recipient"}},
+	}
+	block := &blockchain.Block{Index: 1, Hash: "hash", Transactions: []*transaction.Transaction{tx}}
+	err := ds.SaveBlockToDB(block)
+	if err != nil {
+		t.Fatalf("failed to save block: %v", err)
+	}
+
+	// Очищаем сообщение и перезаписываем блок
+	err = tx.Prune()
+	if err != nil {
+		t.Fatalf("failed to prune transaction: %v", err)
+	}
+
+	err = ds.PruneBlockInDB(block)
+	if err != nil {
+		t.Fatalf("failed to prune block: %v", err)
+	}
+
+	// Блок доступен по прежнему хэшу, но без содержимого сообщения
+	blockData, err := ds.GetBlockFromDB("hash")
+	if err != nil {
+		t.Fatalf("failed to get block: %v", err)
+	}
+
+	if bytes.Contains(blockData, []byte("ciphertext")) || bytes.Contains(blockData, []byte("Y2lwaGVydGV4dA")) {
+		t.Error("expected ciphertext to be removed from stored block")
+	}
+	if !bytes.Contains(blockData, []byte("recipient")) {
+		t.Error("expected message header to be kept in stored block")
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+
+	// Инициализируем хранилище данных
+	ds, cleanupDB := setupDataStore(t)
+	defer cleanupDB()
+
+	contacts := ds.Namespace("contacts_")
+	other := ds.Namespace("other_")
+
+	for _, key := range []string{"b", "a"} {
+		err := contacts.Put(key, key+" value")
+		if err != nil {
+			t.Fatalf("failed to put data in namespace: %v", err)
+		}
+	}
+	err := other.Put("c", "other value")
+	if err != nil {
+		t.Fatalf("failed to put data in namespace: %v", err)
+	}
+
+	// Значение хранится в общей БД под ключом с префиксом
+	var value string
+	_, err = ds.Get("contacts_a", &value)
+	if err != nil || value != "a value" {
+		t.Errorf("expected prefixed key in DataStore, got %q, %v", value, err)
+	}
+
+	// Ключи других пространств не видны
+	keys, err := contacts.Keys()
+	if err != nil {
+		t.Fatalf("failed to list namespace keys: %v", err)
+	}
+	if !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Errorf("keys: got %v, expected [a b]", keys)
+	}
+
+	err = contacts.Delete("a")
+	if err != nil {
+		t.Fatalf("failed to delete data from namespace: %v", err)
+	}
+
+	_, err = contacts.Get("a", &value)
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	// MemoryStore и Namespace взаимозаменяемы для подсистем узла
+	var _ KeyLister = &Namespace{}
+	var _ KeyLister = NewMemoryStore()
+	var _ KeyValueStore = &DataStore{}
+
+	ms := NewMemoryStore()
+	for _, key := range []string{"b", "a"} {
+		err := ms.Put(key, []string{key})
+		if err != nil {
+			t.Fatalf("failed to put data in memory store: %v", err)
+		}
+	}
+
+	var value []string
+	data, err := ms.Get("a", &value)
+	if err != nil {
+		t.Fatalf("failed to get data from memory store: %v", err)
+	}
+	if !reflect.DeepEqual(value, []string{"a"}) || string(data) != `["a"]` {
+		t.Errorf("expected JSON value [a], got %v (%s)", value, data)
+	}
+
+	keys, err := ms.Keys()
+	if err != nil {
+		t.Fatalf("failed to list memory store keys: %v", err)
+	}
+	if !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Errorf("keys: got %v, expected [a b]", keys)
+	}
+
+	err = ms.Delete("a")
+	if err != nil {
+		t.Fatalf("failed to delete data from memory store: %v", err)
+	}
+
+	_, err = ms.Get("a", &value)
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+}
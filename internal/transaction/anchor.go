@@ -0,0 +1,67 @@
+package transaction
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// TypeAnchor закрепление корня дерева Меркла сообщений, доставленных вне блокчейна
+const TypeAnchor = "anchor"
+
+var ErrInvalidAnchor = errors.New("invalid anchor")
+
+// Anchor корень дерева Меркла пакета сообщений и их количество. Порядок листьев
+// фиксирует порядок сообщений, а подпись транзакции - их отправителя.
+type Anchor struct {
+	Root  []byte
+	Count int
+}
+
+// Validate проверяет корень и размер пакета
+func (anchor *Anchor) Validate() error {
+	if len(anchor.Root) != sha256.Size {
+		return fmt.Errorf("%w: root must be %d bytes", ErrInvalidAnchor, sha256.Size)
+	}
+
+	if anchor.Count <= 0 {
+		return fmt.Errorf("%w: empty batch", ErrInvalidAnchor)
+	}
+
+	return nil
+}
+
+// NewAnchorTransaction создает транзакцию закрепления пакета сообщений
+func NewAnchorTransaction(anchor Anchor) (*Transaction, error) {
+	err := anchor.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(anchor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal anchor: %w", err)
+	}
+
+	return &Transaction{
+		ID:      generateTransactionID(),
+		Type:    TypeAnchor,
+		Payload: payload,
+	}, nil
+}
+
+// Anchor возвращает закрепленный пакет сообщений из транзакции
+func (tx *Transaction) Anchor() (*Anchor, error) {
+	if tx.Type != TypeAnchor {
+		return nil, fmt.Errorf("transaction %s is not an anchor", tx.ID)
+	}
+
+	var anchor Anchor
+	err := json.Unmarshal(tx.Payload, &anchor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal anchor: %w", err)
+	}
+
+	return &anchor, nil
+}
@@ -0,0 +1,184 @@
+package transaction
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	// TypeCreateChannel создание публичного канала, отправитель становится владельцем
+	TypeCreateChannel = "create_channel"
+	// TypeSetChannelTopic изменение темы канала владельцем
+	TypeSetChannelTopic = "set_channel_topic"
+	// TypeShareChannelKey выдача ключа закрытого канала подписчикам
+	TypeShareChannelKey = "share_channel_key"
+	// TypeChannelPost публикация в канале
+	TypeChannelPost = "channel_post"
+)
+
+var ErrChannelKeyRequired = errors.New("channel post is encrypted, channel key required")
+
+// ChannelAction создание канала, изменение темы или выдача ключа канала.
+// Канал идентифицируется именем, к которому применяются правила ValidateName.
+type ChannelAction struct {
+	Channel string
+	Topic   string
+	// Encrypted публикации канала шифруются ключом, который владелец выдает подписчикам
+	Encrypted bool
+	// Open публиковать в канале может любой пользователь, а не только владелец
+	Open      bool
+	KeyShares []GroupKeyShare
+}
+
+// ChannelPost публикация канала: открытый текст Content или шифртекст,
+// зашифрованный ключом канала
+type ChannelPost struct {
+	Channel    string
+	Content    []byte
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// NewChannelCreateTransaction создает канал с именем name
+func NewChannelCreateTransaction(name string, topic string, encrypted bool, open bool) (*Transaction, error) {
+	err := ValidateName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return newChannelTransaction(TypeCreateChannel, ChannelAction{
+		Channel:   name,
+		Topic:     topic,
+		Encrypted: encrypted,
+		Open:      open,
+	})
+}
+
+// NewChannelTopicTransaction изменяет тему канала
+func NewChannelTopicTransaction(name string, topic string) (*Transaction, error) {
+	return newChannelTransaction(TypeSetChannelTopic, ChannelAction{Channel: name, Topic: topic})
+}
+
+// NewChannelKeyTransaction выдает ключ закрытого канала подписчикам subscribers
+func NewChannelKeyTransaction(name string, channelKey []byte, subscribers []string, resolver KeyResolver) (*Transaction, error) {
+	shares, err := EncryptGroupKey(channelKey, subscribers, resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	return newChannelTransaction(TypeShareChannelKey, ChannelAction{Channel: name, KeyShares: shares})
+}
+
+func newChannelTransaction(txType string, action ChannelAction) (*Transaction, error) {
+	payload, err := json.Marshal(action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal channel action: %w", err)
+	}
+
+	return &Transaction{
+		ID:      generateTransactionID(),
+		Type:    txType,
+		Payload: payload,
+	}, nil
+}
+
+// ChannelAction возвращает изменение канала из транзакции
+func (tx *Transaction) ChannelAction() (*ChannelAction, error) {
+	switch tx.Type {
+	case TypeCreateChannel, TypeSetChannelTopic, TypeShareChannelKey:
+	default:
+		return nil, fmt.Errorf("transaction %s is not a channel action", tx.ID)
+	}
+
+	var action ChannelAction
+	err := json.Unmarshal(tx.Payload, &action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal channel action: %w", err)
+	}
+
+	return &action, nil
+}
+
+// NewChannelPostTransaction создает открытую публикацию в канале
+func NewChannelPostTransaction(name string, content []byte) (*Transaction, error) {
+	return newChannelPostTransaction(ChannelPost{Channel: name, Content: content})
+}
+
+// NewEncryptedChannelPostTransaction создает публикацию, зашифрованную ключом канала
+func NewEncryptedChannelPostTransaction(name string, channelKey []byte, content []byte) (*Transaction, error) {
+	aead, err := newGroupCipher(channelKey)
+	if err != nil {
+		return nil, err
+	}
+
+	post := ChannelPost{
+		Channel: name,
+		Nonce:   make([]byte, aead.NonceSize()),
+	}
+
+	_, err = rand.Read(post.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	post.Ciphertext = aead.Seal(nil, post.Nonce, content, []byte(name))
+
+	return newChannelPostTransaction(post)
+}
+
+func newChannelPostTransaction(post ChannelPost) (*Transaction, error) {
+	payload, err := json.Marshal(post)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal channel post: %w", err)
+	}
+
+	return &Transaction{
+		ID:      generateTransactionID(),
+		Type:    TypeChannelPost,
+		Payload: payload,
+	}, nil
+}
+
+// ChannelPost возвращает публикацию канала из транзакции
+func (tx *Transaction) ChannelPost() (*ChannelPost, error) {
+	if tx.Type != TypeChannelPost {
+		return nil, fmt.Errorf("transaction %s is not a channel post", tx.ID)
+	}
+
+	var post ChannelPost
+	err := json.Unmarshal(tx.Payload, &post)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal channel post: %w", err)
+	}
+
+	return &post, nil
+}
+
+// Encrypted проверяет, зашифрована ли публикация ключом канала
+func (post *ChannelPost) Encrypted() bool {
+	return post.Ciphertext != nil
+}
+
+// Read возвращает текст публикации. Для открытых публикаций channelKey не нужен.
+func (post *ChannelPost) Read(channelKey []byte) ([]byte, error) {
+	if !post.Encrypted() {
+		return post.Content, nil
+	}
+
+	if channelKey == nil {
+		return nil, ErrChannelKeyRequired
+	}
+
+	aead, err := newGroupCipher(channelKey)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := aead.Open(nil, post.Nonce, post.Ciphertext, []byte(post.Channel))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt channel post: %w", err)
+	}
+
+	return content, nil
+}
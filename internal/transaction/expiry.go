@@ -0,0 +1,61 @@
+package transaction
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Expired проверяет, истек ли срок хранения сообщения к блоку height
+func (meta *MessageMeta) Expired(height int64) bool {
+	return meta.ExpiresAt != 0 && height >= meta.ExpiresAt
+}
+
+// Pruned проверяет, удалено ли из транзакции содержимое истекшего сообщения
+func (tx *Transaction) Pruned() bool {
+	return tx.PrunedHash != nil
+}
+
+// Prune удаляет из сообщения зашифрованное содержимое, оставляя заголовок:
+// отправителя, получателей, ссылки на другие сообщения и подпись. Хэш исходной
+// транзакции сохраняется в PrunedHash, поэтому доказательство работы блока
+// остается проверяемым, а подпись очищенной транзакции - нет.
+func (tx *Transaction) Prune() error {
+	if !tx.IsMessage() {
+		return fmt.Errorf("transaction %s is not a message", tx.ID)
+	}
+
+	if tx.Pruned() {
+		return nil
+	}
+
+	hash, err := tx.Hash()
+	if err != nil {
+		return fmt.Errorf("failed to hash transaction %s: %w", tx.ID, err)
+	}
+
+	payload := tx.Payload
+	if tx.Type == TypeGroupMessage {
+		message, err := tx.GroupMessage()
+		if err != nil {
+			return err
+		}
+
+		message.Nonce = nil
+		message.Ciphertext = nil
+		payload, err = json.Marshal(message)
+		if err != nil {
+			return fmt.Errorf("failed to marshal group message: %w", err)
+		}
+	}
+
+	for i := range tx.Inputs {
+		tx.Inputs[i].EncryptedData = nil
+	}
+	for i := range tx.Outputs {
+		tx.Outputs[i].EncryptedData = nil
+	}
+
+	tx.Payload = payload
+	tx.PrunedHash = hash
+	return nil
+}
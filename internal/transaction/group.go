@@ -0,0 +1,295 @@
+package transaction
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const (
+	// TypeCreateGroup создание группы, отправитель становится ее владельцем
+	TypeCreateGroup = "create_group"
+	// TypeInviteMember добавление участников группы владельцем
+	TypeInviteMember = "invite_member"
+	// TypeRemoveMember исключение участников владельцем со сменой ключа группы
+	TypeRemoveMember = "remove_member"
+	// TypeLeaveGroup выход отправителя из группы
+	TypeLeaveGroup = "leave_group"
+	// TypeRekeyGroup смена ключа группы участником, например после выхода другого участника
+	TypeRekeyGroup = "rekey_group"
+	// TypeGroupMessage сообщение, зашифрованное ключом группы
+	TypeGroupMessage = "group_message"
+
+	// GroupKeyLength длина симметричного ключа группы
+	GroupKeyLength = 32
+)
+
+var ErrInvalidGroupKey = errors.New("invalid group key")
+
+// GroupKeyShare ключ группы, зашифрованный ключом участника
+type GroupKeyShare struct {
+	Member       string
+	Scheme       string
+	EncryptedKey []byte
+}
+
+// GroupAction изменение состава или ключа группы. Значение Members зависит от
+// типа транзакции: все участники при создании, добавляемые при приглашении,
+// исключаемые при исключении. KeyShares содержит ключ эпохи Epoch для участников,
+// которым он выдается этой транзакцией.
+type GroupAction struct {
+	GroupID   string
+	Name      string
+	Members   []string
+	Epoch     uint64
+	KeyShares []GroupKeyShare
+}
+
+// GroupMessage сообщение группы, зашифрованное ключом эпохи Epoch.
+// Ссылки на другие сообщения группы не шифруются, чтобы их могли проверить узлы.
+type GroupMessage struct {
+	GroupID    string
+	Epoch      uint64
+	Nonce      []byte
+	Ciphertext []byte
+	MessageMeta
+}
+
+// NewGroupKey создает случайный ключ группы
+func NewGroupKey() ([]byte, error) {
+	key := make([]byte, GroupKeyLength)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate group key: %w", err)
+	}
+
+	return key, nil
+}
+
+// EncryptGroupKey шифрует ключ группы для каждого из участников members
+func EncryptGroupKey(groupKey []byte, members []string, resolver KeyResolver) ([]GroupKeyShare, error) {
+	shares := make([]GroupKeyShare, 0, len(members))
+	for _, member := range members {
+		publicKey, err := resolver.ResolveEncryptionKey(member)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load public key for member %s: %w", member, err)
+		}
+
+		scheme, encryptedKey, err := encryptFor(publicKey, groupKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt group key for member %s: %w", member, err)
+		}
+
+		shares = append(shares, GroupKeyShare{Member: member, Scheme: scheme, EncryptedKey: encryptedKey})
+	}
+
+	return shares, nil
+}
+
+// DecryptGroupKey расшифровывает ключ группы закрытым ключом участника
+func DecryptGroupKey(share GroupKeyShare, privateKey crypto.PrivateKey) ([]byte, error) {
+	groupKey, err := decryptFor(share.Scheme, share.EncryptedKey, privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt group key: %w", err)
+	}
+
+	if len(groupKey) != GroupKeyLength {
+		return nil, ErrInvalidGroupKey
+	}
+
+	return groupKey, nil
+}
+
+// NewGroupCreateTransaction создает группу с участниками members, которые
+// должны включать адрес создателя. Ключ первой эпохи шифруется для всех участников.
+func NewGroupCreateTransaction(name string, members []string, groupKey []byte, resolver KeyResolver) (*Transaction, error) {
+	shares, err := EncryptGroupKey(groupKey, members, resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	return newGroupTransaction(TypeCreateGroup, GroupAction{
+		GroupID:   generateTransactionID(),
+		Name:      name,
+		Members:   members,
+		Epoch:     1,
+		KeyShares: shares,
+	})
+}
+
+// NewGroupInviteTransaction добавляет участников members и выдает им текущий ключ группы
+func NewGroupInviteTransaction(groupID string, epoch uint64, members []string, groupKey []byte, resolver KeyResolver) (*Transaction, error) {
+	shares, err := EncryptGroupKey(groupKey, members, resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	return newGroupTransaction(TypeInviteMember, GroupAction{
+		GroupID:   groupID,
+		Members:   members,
+		Epoch:     epoch,
+		KeyShares: shares,
+	})
+}
+
+// NewGroupRemoveTransaction исключает участников removed и выдает новый ключ
+// эпохи epoch оставшимся участникам remaining
+func NewGroupRemoveTransaction(groupID string, removed []string, epoch uint64, remaining []string, groupKey []byte, resolver KeyResolver) (*Transaction, error) {
+	shares, err := EncryptGroupKey(groupKey, remaining, resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	return newGroupTransaction(TypeRemoveMember, GroupAction{
+		GroupID:   groupID,
+		Members:   removed,
+		Epoch:     epoch,
+		KeyShares: shares,
+	})
+}
+
+// NewGroupLeaveTransaction создает транзакцию выхода отправителя из группы
+func NewGroupLeaveTransaction(groupID string) (*Transaction, error) {
+	return newGroupTransaction(TypeLeaveGroup, GroupAction{GroupID: groupID})
+}
+
+// NewGroupRekeyTransaction выдает новый ключ эпохи epoch всем участникам members
+func NewGroupRekeyTransaction(groupID string, epoch uint64, members []string, groupKey []byte, resolver KeyResolver) (*Transaction, error) {
+	shares, err := EncryptGroupKey(groupKey, members, resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	return newGroupTransaction(TypeRekeyGroup, GroupAction{
+		GroupID:   groupID,
+		Epoch:     epoch,
+		KeyShares: shares,
+	})
+}
+
+func newGroupTransaction(txType string, action GroupAction) (*Transaction, error) {
+	payload, err := json.Marshal(action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal group action: %w", err)
+	}
+
+	return &Transaction{
+		ID:      generateTransactionID(),
+		Type:    txType,
+		Payload: payload,
+	}, nil
+}
+
+// IsGroupAction проверяет, изменяет ли транзакция состав или ключ группы
+func (tx *Transaction) IsGroupAction() bool {
+	switch tx.Type {
+	case TypeCreateGroup, TypeInviteMember, TypeRemoveMember, TypeLeaveGroup, TypeRekeyGroup:
+		return true
+	default:
+		return false
+	}
+}
+
+// GroupAction возвращает изменение группы из транзакции
+func (tx *Transaction) GroupAction() (*GroupAction, error) {
+	if !tx.IsGroupAction() {
+		return nil, fmt.Errorf("transaction %s is not a group action", tx.ID)
+	}
+
+	var action GroupAction
+	err := json.Unmarshal(tx.Payload, &action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal group action: %w", err)
+	}
+
+	return &action, nil
+}
+
+// NewGroupMessageTransaction шифрует сообщение ключом группы эпохи epoch
+func NewGroupMessageTransaction(groupID string, epoch uint64, groupKey []byte, plaintext []byte) (*Transaction, error) {
+	aead, err := newGroupCipher(groupKey)
+	if err != nil {
+		return nil, err
+	}
+
+	message := GroupMessage{
+		GroupID: groupID,
+		Epoch:   epoch,
+		Nonce:   make([]byte, aead.NonceSize()),
+	}
+
+	_, err = rand.Read(message.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	message.Ciphertext = aead.Seal(nil, message.Nonce, plaintext, message.additionalData())
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal group message: %w", err)
+	}
+
+	return &Transaction{
+		ID:      generateTransactionID(),
+		Type:    TypeGroupMessage,
+		Payload: payload,
+	}, nil
+}
+
+// GroupMessage возвращает сообщение группы из транзакции
+func (tx *Transaction) GroupMessage() (*GroupMessage, error) {
+	if tx.Type != TypeGroupMessage {
+		return nil, fmt.Errorf("transaction %s is not a group message", tx.ID)
+	}
+
+	var message GroupMessage
+	err := json.Unmarshal(tx.Payload, &message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal group message: %w", err)
+	}
+
+	return &message, nil
+}
+
+// Decrypt расшифровывает сообщение ключом группы его эпохи
+func (message *GroupMessage) Decrypt(groupKey []byte) ([]byte, error) {
+	aead, err := newGroupCipher(groupKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, message.Nonce, message.Ciphertext, message.additionalData())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt group message: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+// additionalData связывает шифртекст с группой и эпохой ключа
+func (message *GroupMessage) additionalData() []byte {
+	return []byte(message.GroupID + ":" + strconv.FormatUint(message.Epoch, 10))
+}
+
+func newGroupCipher(groupKey []byte) (cipher.AEAD, error) {
+	if len(groupKey) != GroupKeyLength {
+		return nil, ErrInvalidGroupKey
+	}
+
+	block, err := aes.NewCipher(groupKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	return aead, nil
+}
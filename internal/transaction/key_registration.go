@@ -0,0 +1,188 @@
+package transaction
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var ErrSigningKeyMismatch = errors.New("registered signing key does not match transaction key")
+
+// KeyRegistration ключи пользователя, публикуемые в блокчейне
+type KeyRegistration struct {
+	// EncryptionKey устаревший публичный RSA ключ в формате PKCS#1 DER.
+	// Если ключ не указан, сообщения шифруются ключом подписи (ECIES).
+	EncryptionKey []byte
+	// SigningKey публичный ключ подписи отправителя транзакции
+	SigningKey []byte
+	// Prekey публичный X25519 ключ для установления сессий с прямой секретностью
+	Prekey []byte
+}
+
+// NewKeyRegistrationTransaction создает транзакцию публикации ключей.
+// Транзакцию необходимо подписать ключом, указанным в SigningKey.
+func NewKeyRegistrationTransaction(registration KeyRegistration) (*Transaction, error) {
+	payload, err := json.Marshal(registration)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal key registration: %w", err)
+	}
+
+	return &Transaction{
+		ID:      generateTransactionID(),
+		Type:    TypeRegisterKey,
+		Payload: payload,
+	}, nil
+}
+
+// KeyRegistration возвращает ключи из транзакции публикации ключей
+func (tx *Transaction) KeyRegistration() (*KeyRegistration, error) {
+	if tx.Type != TypeRegisterKey {
+		return nil, fmt.Errorf("transaction %s is not a key registration", tx.ID)
+	}
+
+	var registration KeyRegistration
+	err := json.Unmarshal(tx.Payload, &registration)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal key registration: %w", err)
+	}
+
+	return &registration, nil
+}
+
+// Validate проверяет формат ключей и их соответствие ключу подписи транзакции
+func (registration *KeyRegistration) Validate(txPublicKey []byte) error {
+	if !bytes.Equal(registration.SigningKey, txPublicKey) {
+		return ErrSigningKeyMismatch
+	}
+
+	if registration.EncryptionKey != nil {
+		_, err := x509.ParsePKCS1PublicKey(registration.EncryptionKey)
+		if err != nil {
+			return fmt.Errorf("failed to parse encryption key: %w", err)
+		}
+	}
+
+	if registration.Prekey != nil {
+		_, err := ecdh.X25519().NewPublicKey(registration.Prekey)
+		if err != nil {
+			return fmt.Errorf("failed to parse prekey: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// KeyRotation новые ключи пользователя. Транзакция подписывается старым ключом
+// подписи. Если ключ подписи меняется, Proof подтверждает владение новым ключом.
+type KeyRotation struct {
+	EncryptionKey []byte
+	SigningKey    []byte
+	Prekey        []byte
+	Proof         []byte
+}
+
+// KeyRevocation отзыв ключей пользователя
+type KeyRevocation struct {
+	Reason string
+}
+
+// NewKeyRotationTransaction создает транзакцию замены ключей пользователя с
+// адресом oldAddress. newSigningKey передается, если меняется ключ подписи.
+func NewKeyRotationTransaction(oldAddress string, rotation KeyRotation, newSigningKey *ecdsa.PrivateKey) (*Transaction, error) {
+	if newSigningKey != nil {
+		rotation.SigningKey = elliptic.Marshal(elliptic.P256(), newSigningKey.PublicKey.X, newSigningKey.PublicKey.Y)
+
+		proof, err := ecdsa.SignASN1(rand.Reader, newSigningKey, rotation.proofHash(oldAddress))
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign rotation proof: %w", err)
+		}
+		rotation.Proof = proof
+	}
+
+	payload, err := json.Marshal(rotation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal key rotation: %w", err)
+	}
+
+	return &Transaction{
+		ID:      generateTransactionID(),
+		Type:    TypeRotateKey,
+		Payload: payload,
+	}, nil
+}
+
+// KeyRotation возвращает новые ключи из транзакции замены ключей
+func (tx *Transaction) KeyRotation() (*KeyRotation, error) {
+	if tx.Type != TypeRotateKey {
+		return nil, fmt.Errorf("transaction %s is not a key rotation", tx.ID)
+	}
+
+	var rotation KeyRotation
+	err := json.Unmarshal(tx.Payload, &rotation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal key rotation: %w", err)
+	}
+
+	return &rotation, nil
+}
+
+// ChangesSigningKey проверяет, заменяет ли ротация ключ подписи txPublicKey
+func (rotation *KeyRotation) ChangesSigningKey(txPublicKey []byte) bool {
+	return rotation.SigningKey != nil && !bytes.Equal(rotation.SigningKey, txPublicKey)
+}
+
+// Validate проверяет формат новых ключей и подтверждение владения новым ключом подписи
+func (rotation *KeyRotation) Validate(oldAddress string, txPublicKey []byte) error {
+	registration := KeyRegistration{
+		EncryptionKey: rotation.EncryptionKey,
+		SigningKey:    txPublicKey,
+		Prekey:        rotation.Prekey,
+	}
+
+	err := registration.Validate(txPublicKey)
+	if err != nil {
+		return err
+	}
+
+	if !rotation.ChangesSigningKey(txPublicKey) {
+		return nil
+	}
+
+	err = verifyECDSA(rotation.SigningKey, rotation.proofHash(oldAddress), rotation.Proof)
+	if err != nil {
+		return fmt.Errorf("invalid rotation proof: %w", err)
+	}
+
+	return nil
+}
+
+// proofHash данные, подписываемые новым ключом при ротации ключа подписи
+func (rotation *KeyRotation) proofHash(oldAddress string) []byte {
+	h := sha256.New()
+	h.Write([]byte("rotate_key:" + oldAddress))
+	h.Write(rotation.SigningKey)
+	h.Write(rotation.EncryptionKey)
+	h.Write(rotation.Prekey)
+	return h.Sum(nil)
+}
+
+// NewKeyRevocationTransaction создает транзакцию отзыва ключей отправителя
+func NewKeyRevocationTransaction(revocation KeyRevocation) (*Transaction, error) {
+	payload, err := json.Marshal(revocation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal key revocation: %w", err)
+	}
+
+	return &Transaction{
+		ID:      generateTransactionID(),
+		Type:    TypeRevokeKey,
+		Payload: payload,
+	}, nil
+}
@@ -0,0 +1,135 @@
+package transaction
+
+import (
+	"blockchainStorage/internal/attachment"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// MaxAttachments максимальное количество вложений в одном сообщении
+const MaxAttachments = 16
+
+var (
+	ErrConflictingMeta    = errors.New("message can only reply, edit or delete, not several at once")
+	ErrTooManyAttachments = errors.New("too many attachments")
+	ErrInvalidExpiry      = errors.New("message expiry height is negative")
+)
+
+// MessageMeta ссылки сообщения на предыдущие транзакции. Все поля необязательны.
+type MessageMeta struct {
+	// ReplyTo идентификатор сообщения, на которое отвечает это сообщение
+	ReplyTo string `json:",omitempty"`
+	// Edits идентификатор исходного сообщения, новой версией которого является это сообщение
+	Edits string `json:",omitempty"`
+	// Deletes идентификатор сообщения, которое удаляется этой транзакцией
+	Deletes string `json:",omitempty"`
+	// Attachments хэши манифестов вложений. Файлы хранятся узлами вне блоков,
+	// ключи вложений передаются получателям в зашифрованном сообщении.
+	Attachments []string `json:",omitempty"`
+	// ExpiresAt индекс блока, начиная с которого узлы удаляют содержимое
+	// сообщения, а клиенты - его расшифрованный текст. 0 - бессрочно.
+	ExpiresAt int64 `json:",omitempty"`
+}
+
+// Empty проверяет, что у сообщения нет ссылок на другие транзакции и срока хранения
+func (meta *MessageMeta) Empty() bool {
+	return meta.ReplyTo == "" && meta.Edits == "" && meta.Deletes == "" && len(meta.Attachments) == 0 &&
+		meta.ExpiresAt == 0
+}
+
+// Validate проверяет, что сообщение ссылается не более чем на одно сообщение.
+// Правка сохраняет место исходного сообщения в беседе, поэтому не содержит ReplyTo.
+func (meta *MessageMeta) Validate() error {
+	references := 0
+	for _, reference := range []string{meta.ReplyTo, meta.Edits, meta.Deletes} {
+		if reference != "" {
+			references++
+		}
+	}
+
+	if references > 1 {
+		return ErrConflictingMeta
+	}
+
+	if meta.ExpiresAt < 0 {
+		return ErrInvalidExpiry
+	}
+
+	if len(meta.Attachments) > MaxAttachments {
+		return fmt.Errorf("%w: %d, maximum %d", ErrTooManyAttachments, len(meta.Attachments), MaxAttachments)
+	}
+
+	for _, manifest := range meta.Attachments {
+		err := attachment.ValidateHash(manifest)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IsMessage проверяет, является ли транзакция сообщением, к которому применимы
+// ответы, правки и удаления
+func (tx *Transaction) IsMessage() bool {
+	return tx.Type == TypeMessage || tx.Type == TypeGroupMessage
+}
+
+// SetMessageMeta добавляет ссылки на предыдущие сообщения. Вызывается до подписи транзакции.
+func (tx *Transaction) SetMessageMeta(meta MessageMeta) error {
+	err := meta.Validate()
+	if err != nil {
+		return err
+	}
+
+	switch tx.Type {
+	case TypeMessage:
+		payload, err := json.Marshal(meta)
+		if err != nil {
+			return fmt.Errorf("failed to marshal message meta: %w", err)
+		}
+		tx.Payload = payload
+	case TypeGroupMessage:
+		message, err := tx.GroupMessage()
+		if err != nil {
+			return err
+		}
+
+		message.MessageMeta = meta
+		payload, err := json.Marshal(message)
+		if err != nil {
+			return fmt.Errorf("failed to marshal group message: %w", err)
+		}
+		tx.Payload = payload
+	default:
+		return fmt.Errorf("transaction %s is not a message", tx.ID)
+	}
+
+	return nil
+}
+
+// MessageMeta возвращает ссылки сообщения на предыдущие транзакции
+func (tx *Transaction) MessageMeta() (*MessageMeta, error) {
+	switch tx.Type {
+	case TypeMessage:
+		var meta MessageMeta
+		if len(tx.Payload) == 0 {
+			return &meta, nil
+		}
+
+		err := json.Unmarshal(tx.Payload, &meta)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal message meta: %w", err)
+		}
+		return &meta, nil
+	case TypeGroupMessage:
+		message, err := tx.GroupMessage()
+		if err != nil {
+			return nil, err
+		}
+		return &message.MessageMeta, nil
+	default:
+		return nil, fmt.Errorf("transaction %s is not a message", tx.ID)
+	}
+}
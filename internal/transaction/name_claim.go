@@ -0,0 +1,88 @@
+package transaction
+
+import (
+	"blockchainStorage/internal/address"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// HandlePrefix признак имени пользователя в поле получателя, например "@alice"
+const HandlePrefix = "@"
+
+var (
+	ErrInvalidName = errors.New("invalid name")
+
+	namePattern = regexp.MustCompile(`^[a-z0-9_]{3,32}$`)
+)
+
+// NameClaim заявка на имя пользователя, привязываемое к ключу отправителя
+type NameClaim struct {
+	Name string
+	// Duration срок регистрации в блоках, 0 - бессрочно
+	Duration int64
+}
+
+// NewNameClaimTransaction создает транзакцию регистрации или продления имени
+func NewNameClaimTransaction(claim NameClaim) (*Transaction, error) {
+	err := ValidateName(claim.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(claim)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal name claim: %w", err)
+	}
+
+	return &Transaction{
+		ID:      generateTransactionID(),
+		Type:    TypeClaimName,
+		Payload: payload,
+	}, nil
+}
+
+// NameClaim возвращает заявку на имя из транзакции
+func (tx *Transaction) NameClaim() (*NameClaim, error) {
+	if tx.Type != TypeClaimName {
+		return nil, fmt.Errorf("transaction %s is not a name claim", tx.ID)
+	}
+
+	var claim NameClaim
+	err := json.Unmarshal(tx.Payload, &claim)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal name claim: %w", err)
+	}
+
+	return &claim, nil
+}
+
+// ValidateName проверяет, что имя состоит из 3-32 строчных латинских букв, цифр и "_"
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+
+	return nil
+}
+
+// ValidateRecipient проверяет, что получатель указан адресом или именем вида "@name"
+func ValidateRecipient(recipient string) error {
+	name, isHandle := ParseHandle(recipient)
+	if isHandle {
+		return ValidateName(name)
+	}
+
+	return address.Validate(recipient)
+}
+
+// ParseHandle возвращает имя пользователя, если получатель указан в виде "@name"
+func ParseHandle(recipient string) (string, bool) {
+	if !strings.HasPrefix(recipient, HandlePrefix) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(recipient, HandlePrefix), true
+}
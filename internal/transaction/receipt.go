@@ -0,0 +1,161 @@
+package transaction
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	// TypeReceipt закрепление квитанции получателя в блокчейне
+	TypeReceipt = "receipt"
+
+	// ReceiptDelivered сообщение получено узлом получателя
+	ReceiptDelivered = "delivered"
+	// ReceiptRead сообщение прочитано получателем
+	ReceiptRead = "read"
+
+	// receiptDomain отделяет подписи квитанций от подписей транзакций
+	receiptDomain = "blockchainChat receipt"
+)
+
+var ErrInvalidReceipt = errors.New("invalid receipt")
+
+// receiptRanks порядок статусов: квитанция с большим рангом заменяет предыдущую
+var receiptRanks = map[string]int{
+	ReceiptDelivered: 1,
+	ReceiptRead:      2,
+}
+
+// Receipt квитанция получателя о выходе OutputIndex сообщения TxID
+type Receipt struct {
+	TxID        string
+	OutputIndex int
+	Status      string
+	Timestamp   int64
+}
+
+// SignedReceipt квитанция, подписанная ключом получателя. Передается узлу
+// отправителя по сети вне блокчейна.
+type SignedReceipt struct {
+	Receipt
+	PublicKey []byte
+	Signature []byte
+}
+
+// Validate проверяет статус и ссылку квитанции на сообщение
+func (r *Receipt) Validate() error {
+	if _, known := receiptRanks[r.Status]; !known {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidReceipt, r.Status)
+	}
+
+	if r.TxID == "" || r.OutputIndex < 0 {
+		return fmt.Errorf("%w: invalid message reference", ErrInvalidReceipt)
+	}
+
+	return nil
+}
+
+// Supersedes проверяет, сообщает ли квитанция о более позднем статусе, чем other
+func (r *Receipt) Supersedes(other *Receipt) bool {
+	return receiptRanks[r.Status] > receiptRanks[other.Status]
+}
+
+// Sign подписывает квитанцию ключом получателя
+func (r *Receipt) Sign(privateKey *ecdsa.PrivateKey) (*SignedReceipt, error) {
+	err := r.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := r.signingHash()
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := ecdsa.SignASN1(rand.Reader, privateKey, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign receipt: %w", err)
+	}
+
+	return &SignedReceipt{
+		Receipt:   *r,
+		PublicKey: elliptic.Marshal(elliptic.P256(), privateKey.PublicKey.X, privateKey.PublicKey.Y),
+		Signature: signature,
+	}, nil
+}
+
+// Recipient возвращает адрес получателя, подписавшего квитанцию
+func (r *SignedReceipt) Recipient() string {
+	return SenderFromPublicKey(r.PublicKey)
+}
+
+// Verify проверяет квитанцию и подпись получателя. Проверка того, что
+// получатель действительно является адресатом сообщения, выполняется по
+// состоянию цепочки (см. blockchain.LookupOutput).
+func (r *SignedReceipt) Verify() error {
+	err := r.Validate()
+	if err != nil {
+		return err
+	}
+
+	if len(r.Signature) == 0 || len(r.PublicKey) == 0 {
+		return fmt.Errorf("%w: receipt is not signed", ErrInvalidReceipt)
+	}
+
+	hash, err := r.signingHash()
+	if err != nil {
+		return err
+	}
+
+	return verifyECDSA(r.PublicKey, hash, r.Signature)
+}
+
+func (r *Receipt) signingHash() ([]byte, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal receipt: %w", err)
+	}
+
+	hash := sha256.Sum256(append([]byte(receiptDomain), data...))
+	return hash[:], nil
+}
+
+// NewReceiptTransaction создает транзакцию, закрепляющую квитанцию в блокчейне.
+// Транзакцию подписывает получатель, поэтому отдельная подпись квитанции не нужна.
+func NewReceiptTransaction(receipt Receipt) (*Transaction, error) {
+	err := receipt.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(receipt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal receipt: %w", err)
+	}
+
+	return &Transaction{
+		ID:      generateTransactionID(),
+		Type:    TypeReceipt,
+		Payload: payload,
+	}, nil
+}
+
+// Receipt возвращает квитанцию, закрепленную транзакцией
+func (tx *Transaction) Receipt() (*Receipt, error) {
+	if tx.Type != TypeReceipt {
+		return nil, fmt.Errorf("transaction %s is not a receipt", tx.ID)
+	}
+
+	var receipt Receipt
+	err := json.Unmarshal(tx.Payload, &receipt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal receipt: %w", err)
+	}
+
+	return &receipt, nil
+}
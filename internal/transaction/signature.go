@@ -0,0 +1,108 @@
+package transaction
+
+import (
+	"blockchainStorage/internal/address"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnsigned         = errors.New("transaction is not signed")
+	ErrInvalidSignature = errors.New("invalid transaction signature")
+	ErrSenderMismatch   = errors.New("transaction sender does not match public key")
+)
+
+// SenderFromPublicKey возвращает адрес отправителя для публичного ключа подписи
+func SenderFromPublicKey(publicKey []byte) string {
+	return address.FromPublicKey(publicKey)
+}
+
+// SigningHash вычисляет хэш содержимого транзакции без подписи
+func (tx *Transaction) SigningHash() ([]byte, error) {
+	unsigned := *tx
+	unsigned.Signature = nil
+
+	data, err := json.Marshal(&unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal transaction: %w", err)
+	}
+
+	hash := sha256.Sum256(data)
+	return hash[:], nil
+}
+
+// StampHash вычисляет хэш содержимого транзакции, оплачиваемого штампом:
+// все поля, кроме самого штампа, ключа и подписи. Отправитель входит в хэш и
+// связан с ключом проверкой подписи, поэтому штамп можно вычислить до Sign.
+func (tx *Transaction) StampHash() ([]byte, error) {
+	content := *tx
+	content.Stamp = 0
+	content.PublicKey = nil
+	content.Signature = nil
+	content.PrunedHash = nil
+
+	data, err := json.Marshal(&content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal transaction: %w", err)
+	}
+
+	hash := sha256.Sum256(data)
+	return hash[:], nil
+}
+
+// Sign подписывает транзакцию ключом отправителя и устанавливает отправителя
+func (tx *Transaction) Sign(privateKey *ecdsa.PrivateKey) error {
+	tx.PublicKey = elliptic.Marshal(elliptic.P256(), privateKey.PublicKey.X, privateKey.PublicKey.Y)
+	tx.Sender = SenderFromPublicKey(tx.PublicKey)
+
+	hash, err := tx.SigningHash()
+	if err != nil {
+		return err
+	}
+
+	signature, err := ecdsa.SignASN1(rand.Reader, privateKey, hash)
+	if err != nil {
+		return fmt.Errorf("failed to sign transaction %s: %w", tx.ID, err)
+	}
+
+	tx.Signature = signature
+	return nil
+}
+
+// VerifySignature проверяет подпись транзакции и соответствие отправителя ключу
+func (tx *Transaction) VerifySignature() error {
+	if len(tx.Signature) == 0 || len(tx.PublicKey) == 0 {
+		return ErrUnsigned
+	}
+
+	if tx.Sender != SenderFromPublicKey(tx.PublicKey) {
+		return ErrSenderMismatch
+	}
+
+	hash, err := tx.SigningHash()
+	if err != nil {
+		return err
+	}
+
+	return verifyECDSA(tx.PublicKey, hash, tx.Signature)
+}
+
+// verifyECDSA проверяет подпись hash ключом P-256 в несжатом формате
+func verifyECDSA(publicKeyData []byte, hash []byte, signature []byte) error {
+	x, y := elliptic.Unmarshal(elliptic.P256(), publicKeyData)
+	if x == nil {
+		return errors.New("failed to parse signing public key")
+	}
+
+	publicKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+	if !ecdsa.VerifyASN1(publicKey, hash, signature) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package transaction
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransaction_SignAndVerify(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	tx := &Transaction{
+		ID:       "transaction_id",
+		Sequence: 1,
+		Outputs: []MessageOutput{
+			{
+				EncryptedData: []byte("encrypted_data"),
+				Recipient:     "recipient_1",
+			},
+		},
+	}
+
+	// Неподписанная транзакция не проходит проверку
+	assert.ErrorIs(t, tx.VerifySignature(), ErrUnsigned)
+
+	err = tx.Sign(privateKey)
+	assert.NoError(t, err)
+	assert.Equal(t, SenderFromPublicKey(tx.PublicKey), tx.Sender)
+	assert.NoError(t, tx.VerifySignature())
+
+	// Изменение номера последовательности делает подпись недействительной
+	tx.Sequence = 2
+	assert.ErrorIs(t, tx.VerifySignature(), ErrInvalidSignature)
+	tx.Sequence = 1
+
+	// Подмена отправителя обнаруживается
+	tx.Sender = "someone_else"
+	assert.ErrorIs(t, tx.VerifySignature(), ErrSenderMismatch)
+}
@@ -1,6 +1,9 @@
 package transaction
 
 import (
+	"blockchainStorage/internal/identity"
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -13,32 +16,93 @@ import (
 	"os"
 )
 
+// MessageInput ссылка на выход полученного сообщения. Получатель расходует выход,
+// отвечая на сообщение, и может сделать это только один раз.
 type MessageInput struct {
 	TransactionID string
 	OutputIndex   int
 	EncryptedData []byte
 }
 
+// MessageOutput сообщение одному получателю. После подтверждения выход может
+// израсходовать только его получатель (см. MessageInput).
 type MessageOutput struct {
 	EncryptedData []byte
 	Recipient     string
+	Amount        int64
+	Scheme        string
 }
 
+const (
+	// SchemeRSAOAEP шифрование долгосрочным RSA ключом получателя (устаревшая схема).
+	// Выходы с этой схемой шифруются ключом, который возвращает KeyResolver.
+	SchemeRSAOAEP = ""
+	// SchemeECIES шифрование ключом личности получателя P-256 (см. пакет identity)
+	SchemeECIES = "ecies-p256"
+	// SchemeRatchet шифрование сессией с прямой секретностью (см. пакет session)
+	SchemeRatchet = "x25519-ratchet"
+)
+
+const (
+	// TypeMessage обычная транзакция с сообщениями (значение по умолчанию)
+	TypeMessage = ""
+	// TypeCoinbase транзакция вознаграждения майнера, создается только майнером блока
+	TypeCoinbase = "coinbase"
+	// TypeRegisterKey публикация ключей пользователя в блокчейне
+	TypeRegisterKey = "register_key"
+	// TypeClaimName регистрация или продление имени пользователя
+	TypeClaimName = "claim_name"
+	// TypeRotateKey замена ключей пользователя, подписанная старым ключом
+	TypeRotateKey = "rotate_key"
+	// TypeRevokeKey отзыв ключей пользователя
+	TypeRevokeKey = "revoke_key"
+)
+
 type Transaction struct {
-	ID      string
-	Inputs  []MessageInput
-	Outputs []MessageOutput
+	ID        string
+	Type      string
+	Sender    string
+	Sequence  uint64
+	Fee       int64
+	Stamp     int64
+	Inputs    []MessageInput
+	Outputs   []MessageOutput
+	Payload   []byte
+	PublicKey []byte
+	Signature []byte
+	// PrunedHash хэш транзакции до удаления содержимого истекшего сообщения (см. Prune)
+	PrunedHash []byte `json:",omitempty"`
+}
+
+// KeyResolver находит публичный ключ шифрования получателя: *ecdsa.PublicKey
+// ключа личности или *rsa.PublicKey для получателей с устаревшим RSA ключом
+type KeyResolver interface {
+	ResolveEncryptionKey(recipient string) (crypto.PublicKey, error)
+}
+
+// FileKeyResolver находит ключ получателя в локальном PEM-файле, путь к
+// которому указан в качестве получателя
+type FileKeyResolver struct{}
+
+func (FileKeyResolver) ResolveEncryptionKey(recipient string) (crypto.PublicKey, error) {
+	return LoadRecipientKey(recipient)
 }
 
 // NewTransaction создает новую транзакцию с зашифрованными сообщениями
 func NewTransaction(inputs []MessageInput, outputs []MessageOutput) (*Transaction, error) {
+	return NewTransactionWithResolver(inputs, outputs, FileKeyResolver{})
+}
+
+// NewTransactionWithResolver создает новую транзакцию, получая ключи
+// получателей через resolver, например из состояния блокчейна
+func NewTransactionWithResolver(inputs []MessageInput, outputs []MessageOutput, resolver KeyResolver) (*Transaction, error) {
 	tx := &Transaction{
 		ID:      generateTransactionID(),
 		Inputs:  inputs,
 		Outputs: outputs,
 	}
 
-	err := tx.EncryptMessages()
+	err := tx.EncryptMessagesWith(resolver)
 	if err != nil {
 		return nil, err
 	}
@@ -46,43 +110,149 @@ func NewTransaction(inputs []MessageInput, outputs []MessageOutput) (*Transactio
 	return tx, nil
 }
 
+// NewCoinbaseTransaction создает транзакцию вознаграждения майнера блока
+func NewCoinbaseTransaction(minerAddress string, amount int64) *Transaction {
+	return &Transaction{
+		ID:   generateTransactionID(),
+		Type: TypeCoinbase,
+		Outputs: []MessageOutput{
+			{
+				Recipient: minerAddress,
+				Amount:    amount,
+			},
+		},
+	}
+}
+
+// IsCoinbase проверяет, является ли транзакция вознаграждением майнера
+func (tx *Transaction) IsCoinbase() bool {
+	return tx.Type == TypeCoinbase
+}
+
 // generateTransactionID генерирует уникальный идентификатор транзакции
 func generateTransactionID() string {
 	id := uuid.New()
 	return id.String()
 }
 
-// EncryptMessages шифрует сообщения в транзакции с помощью публичных ключей получателей
+// EncryptMessages шифрует сообщения в транзакции с помощью публичных ключей получателей.
+// Выходы, уже зашифрованные другой схемой, не изменяются.
 func (tx *Transaction) EncryptMessages() error {
+	return tx.EncryptMessagesWith(FileKeyResolver{})
+}
+
+// EncryptMessagesWith шифрует сообщения ключами получателей, найденными через resolver
+func (tx *Transaction) EncryptMessagesWith(resolver KeyResolver) error {
 	for i := range tx.Outputs {
-		recipientPublicKey, err := LoadPublicKey(tx.Outputs[i].Recipient)
+		if tx.Outputs[i].Scheme != SchemeRSAOAEP {
+			continue
+		}
+
+		recipientPublicKey, err := resolver.ResolveEncryptionKey(tx.Outputs[i].Recipient)
 		if err != nil {
 			return fmt.Errorf("failed to load public key for recipient %s: %w", tx.Outputs[i].Recipient, err)
 		}
 
-		encryptedData, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, recipientPublicKey, tx.Outputs[i].EncryptedData, nil)
+		scheme, encryptedData, err := encryptFor(recipientPublicKey, tx.Outputs[i].EncryptedData)
 		if err != nil {
 			return fmt.Errorf("failed to encrypt message data for recipient %s: %w", tx.Outputs[i].Recipient, err)
 		}
 
 		tx.Outputs[i].EncryptedData = encryptedData
+		tx.Outputs[i].Scheme = scheme
 	}
 
 	return nil
 }
 
-// DecryptMessages расшифровывает сообщения в транзакции с помощью приватного ключа получателя
-func (tx *Transaction) DecryptMessages(privateKey *rsa.PrivateKey) error {
-	for i := range tx.Inputs {
-		decryptedData, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, tx.Inputs[i].EncryptedData, nil)
+// encryptFor шифрует данные ключом получателя и возвращает использованную схему
+func encryptFor(publicKey crypto.PublicKey, plaintext []byte) (string, []byte, error) {
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		ciphertext, err := identity.Encrypt(key, plaintext)
+		return SchemeECIES, ciphertext, err
+	case *rsa.PublicKey:
+		ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, key, plaintext, nil)
+		return SchemeRSAOAEP, ciphertext, err
+	default:
+		return "", nil, fmt.Errorf("unsupported recipient key type %T", publicKey)
+	}
+}
+
+// DecryptOutput расшифровывает сообщение выхода index ключом получателя:
+// *identity.Identity или *ecdsa.PrivateKey для ECIES, *rsa.PrivateKey для RSA-OAEP
+func (tx *Transaction) DecryptOutput(index int, privateKey crypto.PrivateKey) ([]byte, error) {
+	if index < 0 || index >= len(tx.Outputs) {
+		return nil, fmt.Errorf("transaction %s has no output %d", tx.ID, index)
+	}
+
+	plaintext, err := decryptFor(tx.Outputs[index].Scheme, tx.Outputs[index].EncryptedData, privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt output %d of transaction %s: %w", index, tx.ID, err)
+	}
+
+	return plaintext, nil
+}
+
+// decryptFor расшифровывает данные, зашифрованные функцией encryptFor по схеме scheme
+func decryptFor(scheme string, ciphertext []byte, privateKey crypto.PrivateKey) ([]byte, error) {
+	if key, ok := privateKey.(*ecdsa.PrivateKey); ok {
+		id, err := identity.FromPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		privateKey = id
+	}
+
+	switch key := privateKey.(type) {
+	case *identity.Identity:
+		if scheme != SchemeECIES {
+			return nil, fmt.Errorf("data is not encrypted with %s", SchemeECIES)
+		}
+		return key.Decrypt(ciphertext)
+	case *rsa.PrivateKey:
+		if scheme != SchemeRSAOAEP {
+			return nil, errors.New("data is not encrypted with RSA")
+		}
+		return rsa.DecryptOAEP(sha256.New(), rand.Reader, key, ciphertext, nil)
+	default:
+		return nil, fmt.Errorf("unsupported recipient key type %T", privateKey)
+	}
+}
+
+// DecryptedMessage расшифрованное сообщение выхода транзакции
+type DecryptedMessage struct {
+	OutputIndex int
+	Recipient   string
+	Amount      int64
+	Plaintext   []byte
+}
+
+// DecryptMessages расшифровывает выходы транзакции, адресованные recipient, ключом
+// privateKey (см. DecryptOutput) и возвращает их в порядке выходов. Выходы других
+// получателей, выходы без данных и выходы сессий с прямой секретностью, которые
+// расшифровывает session.Manager, пропускаются. Транзакция не изменяется.
+func (tx *Transaction) DecryptMessages(recipient string, privateKey crypto.PrivateKey) ([]DecryptedMessage, error) {
+	var messages []DecryptedMessage
+	for i, output := range tx.Outputs {
+		if output.Recipient != recipient || len(output.EncryptedData) == 0 || output.Scheme == SchemeRatchet {
+			continue
+		}
+
+		plaintext, err := tx.DecryptOutput(i, privateKey)
 		if err != nil {
-			return fmt.Errorf("failed to decrypt message data for transaction %s: %w", tx.ID, err)
+			return nil, err
 		}
 
-		tx.Inputs[i].EncryptedData = decryptedData
+		messages = append(messages, DecryptedMessage{
+			OutputIndex: i,
+			Recipient:   output.Recipient,
+			Amount:      output.Amount,
+			Plaintext:   plaintext,
+		})
 	}
 
-	return nil
+	return messages, nil
 }
 
 // Serialize сериализует транзакцию в байтовый массив
@@ -90,6 +260,22 @@ func (tx *Transaction) Serialize() ([]byte, error) {
 	return json.Marshal(tx)
 }
 
+// Hash вычисляет хэш транзакции, который входит в доказательство работы блока.
+// Для очищенной транзакции возвращается хэш, сохраненный до очистки.
+func (tx *Transaction) Hash() ([]byte, error) {
+	if tx.Pruned() {
+		return tx.PrunedHash, nil
+	}
+
+	serialized, err := tx.Serialize()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(serialized)
+	return hash[:], nil
+}
+
 // DeserializeTransaction десериализует транзакцию из байтового массива
 func DeserializeTransaction(data []byte) (*Transaction, error) {
 	var tx Transaction
@@ -100,19 +286,41 @@ func DeserializeTransaction(data []byte) (*Transaction, error) {
 	return &tx, nil
 }
 
-// LoadPublicKey загружает публичный ключ получателя из PEM-кодированного файла
+// LoadPublicKey загружает RSA ключ получателя из PEM-кодированного файла в
+// формате PKIX или PKCS#1
 func LoadPublicKey(publicKeyFile string) (*rsa.PublicKey, error) {
+	publicKey, err := LoadRecipientKey(publicKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := publicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key in %s is not an RSA key", publicKeyFile)
+	}
+
+	return rsaKey, nil
+}
+
+// LoadRecipientKey загружает публичный ключ получателя из PEM-файла: ключ личности
+// P-256 (PKIX, как его экспортирует хранилище ключей) или устаревший RSA ключ
+func LoadRecipientKey(publicKeyFile string) (crypto.PublicKey, error) {
 	publicKeyData, err := ReadPEMFile(publicKeyFile)
 	if err != nil {
 		return nil, err
 	}
 
-	publicKey, err := x509.ParsePKCS1PublicKey(publicKeyData)
+	publicKey, err := x509.ParsePKIXPublicKey(publicKeyData)
+	if err == nil {
+		return publicKey, nil
+	}
+
+	rsaKey, err := x509.ParsePKCS1PublicKey(publicKeyData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse public key: %w", err)
 	}
 
-	return publicKey, nil
+	return rsaKey, nil
 }
 
 // ReadPEMFile читает PEM-кодированные данные из файла
@@ -1,6 +1,8 @@
 package transaction
 
 import (
+	"blockchainStorage/internal/identity"
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	_ "crypto/sha256"
@@ -8,10 +10,14 @@ import (
 	_ "encoding/json"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewTransaction(t *testing.T) {
@@ -29,21 +35,32 @@ func TestNewTransaction(t *testing.T) {
 		},
 	}
 
+	// Ключи получателей читаются из PEM-файлов, пути к которым указаны как получатели
+	dir := t.TempDir()
+	recipients := make([]string, 2)
+	for i := range recipients {
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+
+		recipients[i] = filepath.Join(dir, fmt.Sprintf("recipient_%d.pem", i+1))
+		require.NoError(t, SavePublicKey(recipients[i], &privateKey.PublicKey))
+	}
+
 	outputs := []MessageOutput{
 		{
 			EncryptedData: []byte("encrypted_data_3"),
-			Recipient:     "recipient_1",
+			Recipient:     recipients[0],
 		},
 		{
 			EncryptedData: []byte("encrypted_data_4"),
-			Recipient:     "recipient_2",
+			Recipient:     recipients[1],
 		},
 	}
 
 	// Создание новой транзакции
 	tx, err := NewTransaction(inputs, outputs)
 	assert.NoError(t, err)
-	assert.NotNil(t, tx)
+	require.NotNil(t, tx)
 	assert.NotEmpty(t, tx.ID)
 	assert.Len(t, tx.Inputs, len(inputs))
 	assert.Len(t, tx.Outputs, len(outputs))
@@ -58,10 +75,15 @@ func TestTransaction_EncryptMessages(t *testing.T) {
 			EncryptedData: []byte("unencrypted_data_1"),
 		},
 	}
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	recipient := filepath.Join(t.TempDir(), "recipient_1.pem")
+	require.NoError(t, SavePublicKey(recipient, &privateKey.PublicKey))
+
 	outputs := []MessageOutput{
 		{
 			EncryptedData: []byte("unencrypted_data_2"),
-			Recipient:     "recipient_1",
+			Recipient:     recipient,
 		},
 	}
 
@@ -73,7 +95,7 @@ func TestTransaction_EncryptMessages(t *testing.T) {
 	}
 
 	// Шифрование сообщений
-	err := tx.EncryptMessages()
+	err = tx.EncryptMessages()
 	assert.NoError(t, err)
 
 	// Проверка, что данные сообщений были зашифрованы
@@ -88,45 +110,62 @@ func TestTransaction_EncryptMessages(t *testing.T) {
 }
 
 func TestTransaction_DecryptMessages(t *testing.T) {
-	// Создание тестовых входных данных
-	inputs := []MessageInput{
+	// Генерация ключей получателей
+	recipient, err := identity.Generate()
+	assert.NoError(t, err)
+	legacyKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	resolver := mapResolver{
+		"identity": &recipient.PrivateKey().PublicKey,
+		"legacy":   &legacyKey.PublicKey,
+	}
+
+	// Создание транзакции с выходами для нескольких получателей
+	tx, err := NewTransactionWithResolver([]MessageInput{
 		{
 			TransactionID: "transaction_id_1",
 			OutputIndex:   0,
 			EncryptedData: []byte("encrypted_data_1"),
 		},
-	}
-	outputs := []MessageOutput{
-		{
-			EncryptedData: []byte("encrypted_data_2"),
-			Recipient:     "recipient_1",
-		},
-	}
+	}, []MessageOutput{
+		{EncryptedData: []byte("first"), Recipient: "identity", Amount: 5},
+		{EncryptedData: []byte("to legacy"), Recipient: "legacy"},
+		{EncryptedData: []byte("second"), Recipient: "identity"},
+	}, resolver)
+	assert.NoError(t, err)
 
-	// Создание новой транзакции
-	tx := &Transaction{
-		ID:      "transaction_id",
-		Inputs:  inputs,
-		Outputs: outputs,
+	encrypted := make([][]byte, len(tx.Outputs))
+	for i, output := range tx.Outputs {
+		encrypted[i] = output.EncryptedData
 	}
 
-	// Генерация и загрузка приватного ключа получателя
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	// Расшифровываются только выходы, адресованные получателю
+	messages, err := tx.DecryptMessages("identity", recipient)
 	assert.NoError(t, err)
+	assert.Equal(t, []DecryptedMessage{
+		{OutputIndex: 0, Recipient: "identity", Amount: 5, Plaintext: []byte("first")},
+		{OutputIndex: 2, Recipient: "identity", Plaintext: []byte("second")},
+	}, messages)
 
-	// Расшифровка сообщений
-	err = tx.DecryptMessages(privateKey)
+	messages, err = tx.DecryptMessages("legacy", legacyKey)
 	assert.NoError(t, err)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, []byte("to legacy"), messages[0].Plaintext)
 
-	// Проверка, что данные сообщений были расшифрованы
-	for _, input := range tx.Inputs {
-		assert.NotEqual(t, []byte("encrypted_data_1"), input.EncryptedData)
+	// Проверка, что транзакция не изменилась
+	assert.Equal(t, []byte("encrypted_data_1"), tx.Inputs[0].EncryptedData)
+	for i, output := range tx.Outputs {
+		assert.Equal(t, encrypted[i], output.EncryptedData)
 	}
 
-	// Проверка, что данные сообщений не изменились для выходов
-	for _, output := range tx.Outputs {
-		assert.Equal(t, []byte("encrypted_data_2"), output.EncryptedData)
-	}
+	// Получатель без выходов получает пустой результат, а чужой ключ - ошибку
+	messages, err = tx.DecryptMessages("nobody", recipient)
+	assert.NoError(t, err)
+	assert.Empty(t, messages)
+
+	_, err = tx.DecryptMessages("identity", legacyKey)
+	assert.Error(t, err)
 }
 
 func TestSerializeDeserializeTransaction(t *testing.T) {
@@ -171,14 +210,14 @@ func TestLoadPublicKey(t *testing.T) {
 	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
 	assert.NoError(t, err)
 
-	publicKeyFile := "public.pem"
+	publicKeyFile := filepath.Join(t.TempDir(), "public.pem")
 	err = SavePublicKey(publicKeyFile, &privateKey.PublicKey)
 	assert.NoError(t, err)
 
 	// Загрузка публичного ключа из файла
 	publicKey, err := LoadPublicKey(publicKeyFile)
 	assert.NoError(t, err)
-	assert.NotNil(t, publicKey)
+	require.NotNil(t, publicKey)
 
 	// Проверка, что загруженный публичный ключ соответствует ожидаемому
 	assert.Equal(t, privateKey.PublicKey.N, publicKey.N)
@@ -208,3 +247,144 @@ func SavePublicKey(filename string, publicKey *rsa.PublicKey) error {
 
 	return nil
 }
+
+// mapResolver возвращает ключи получателей из памяти
+type mapResolver map[string]crypto.PublicKey
+
+func (r mapResolver) ResolveEncryptionKey(recipient string) (crypto.PublicKey, error) {
+	publicKey, ok := r[recipient]
+	if !ok {
+		return nil, errors.New("recipient not found")
+	}
+	return publicKey, nil
+}
+
+func TestTransaction_EncryptWithIdentity(t *testing.T) {
+	// Один ключ личности используется и для подписи, и для получения сообщений
+	recipient, err := identity.Generate()
+	assert.NoError(t, err)
+
+	legacyKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+
+	resolver := mapResolver{
+		"identity": &recipient.PrivateKey().PublicKey,
+		"legacy":   &legacyKey.PublicKey,
+	}
+
+	tx, err := NewTransactionWithResolver(nil, []MessageOutput{
+		{EncryptedData: []byte("to identity"), Recipient: "identity"},
+		{EncryptedData: []byte("to legacy"), Recipient: "legacy"},
+	}, resolver)
+	assert.NoError(t, err)
+	assert.Equal(t, SchemeECIES, tx.Outputs[0].Scheme)
+	assert.Equal(t, SchemeRSAOAEP, tx.Outputs[1].Scheme)
+
+	plaintext, err := tx.DecryptOutput(0, recipient.PrivateKey())
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("to identity"), plaintext)
+
+	plaintext, err = tx.DecryptOutput(1, legacyKey)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("to legacy"), plaintext)
+
+	// Ключ другой схемы не подходит для выхода
+	_, err = tx.DecryptOutput(1, recipient)
+	assert.Error(t, err)
+
+	// Тот же ключ подписывает транзакцию
+	err = tx.Sign(recipient.PrivateKey())
+	assert.NoError(t, err)
+	assert.Equal(t, recipient.Address(), tx.Sender)
+	assert.NoError(t, tx.VerifySignature())
+}
+
+func TestTransaction_MessageMeta(t *testing.T) {
+	tx := &Transaction{ID: "transaction_id"}
+
+	// Сообщение без ссылок
+	meta, err := tx.MessageMeta()
+	assert.NoError(t, err)
+	assert.True(t, meta.Empty())
+
+	err = tx.SetMessageMeta(MessageMeta{ReplyTo: "parent"})
+	assert.NoError(t, err)
+
+	meta, err = tx.MessageMeta()
+	assert.NoError(t, err)
+	assert.Equal(t, "parent", meta.ReplyTo)
+
+	// Сообщение не может одновременно отвечать и править
+	err = tx.SetMessageMeta(MessageMeta{ReplyTo: "parent", Edits: "original"})
+	assert.ErrorIs(t, err, ErrConflictingMeta)
+
+	// Ссылки сообщения группы хранятся рядом с шифртекстом
+	groupKey, err := NewGroupKey()
+	assert.NoError(t, err)
+
+	groupTx, err := NewGroupMessageTransaction("group", 1, groupKey, []byte("edited"))
+	assert.NoError(t, err)
+	assert.NoError(t, groupTx.SetMessageMeta(MessageMeta{Edits: "original"}))
+
+	message, err := groupTx.GroupMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, "original", message.Edits)
+
+	plaintext, err := message.Decrypt(groupKey)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("edited"), plaintext)
+}
+
+func TestTransaction_MessageMetaAttachments(t *testing.T) {
+	tx := &Transaction{ID: "transaction_id"}
+
+	// В сообщение попадает только хэш манифеста вложения
+	manifest := strings.Repeat("ab", 32)
+	assert.NoError(t, tx.SetMessageMeta(MessageMeta{Attachments: []string{manifest}}))
+
+	meta, err := tx.MessageMeta()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{manifest}, meta.Attachments)
+
+	// Некорректный хэш отклоняется
+	err = tx.SetMessageMeta(MessageMeta{Attachments: []string{"not-a-hash"}})
+	assert.Error(t, err)
+
+	tooMany := make([]string, MaxAttachments+1)
+	for i := range tooMany {
+		tooMany[i] = manifest
+	}
+	err = tx.SetMessageMeta(MessageMeta{Attachments: tooMany})
+	assert.ErrorIs(t, err, ErrTooManyAttachments)
+}
+
+func TestTransaction_Prune(t *testing.T) {
+	groupKey, err := NewGroupKey()
+	assert.NoError(t, err)
+
+	tx, err := NewGroupMessageTransaction("group", 1, groupKey, []byte("secret"))
+	assert.NoError(t, err)
+	assert.NoError(t, tx.SetMessageMeta(MessageMeta{ReplyTo: "root", ExpiresAt: 10}))
+
+	hash, err := tx.Hash()
+	assert.NoError(t, err)
+
+	// Содержимое удаляется, заголовок и хэш исходной транзакции сохраняются
+	assert.NoError(t, tx.Prune())
+	assert.True(t, tx.Pruned())
+
+	pruned, err := tx.Hash()
+	assert.NoError(t, err)
+	assert.Equal(t, hash, pruned)
+
+	message, err := tx.GroupMessage()
+	assert.NoError(t, err)
+	assert.Nil(t, message.Ciphertext)
+	assert.Equal(t, "group", message.GroupID)
+	assert.Equal(t, "root", message.ReplyTo)
+
+	// Транзакции других типов не очищаются
+	claim, err := NewNameClaimTransaction(NameClaim{Name: "alice"})
+	assert.NoError(t, err)
+	assert.Error(t, claim.Prune())
+}